@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// the sort values the audit log can be listed by, the newest events come first by default
+var auditSortSafelist = []string{"occurred_at", "-occurred_at"}
+
+// listAuditEventsHandler lists the changes recorded in the audit log, filtered by resource, actor, action and time range
+func (app *application) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	// hold the expected values from the query string
+	var input struct {
+		data.AuditFilter
+		data.Filters
+	}
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	input.ResourceType = app.readString(qs, "resource_type", "")
+	input.ResourceID = int64(app.readInt(qs, "resource_id", 0, v))
+	input.Actor = app.readString(qs, "actor", "")
+	input.Action = app.readString(qs, "action", "")
+	input.Since = app.readTime(qs, "since", v)
+	input.Until = app.readTime(qs, "until", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-occurred_at")
+	input.Filters.SortSafelist = auditSortSafelist
+
+	data.ValidateAuditFilter(v, input.AuditFilter)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	events, metadata, err := app.models.Audit.GetAll(input.AuditFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"audit_events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListAuditEventsValidation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{"?resource_type=review", map[string]string{"resource_type": "must be one of movie"}},
+		{"?action=rename", map[string]string{"action": "must be one of create, update, delete"}},
+		{"?resource_id=-1", map[string]string{"resource_id": "must be a positive integer"}},
+		{"?since=yesterday", map[string]string{"since": "must be an RFC 3339 timestamp, e.g. 2024-01-02T15:04:05Z"}},
+		{"?since=2024-02-01T00:00:00Z&until=2024-01-01T00:00:00Z", map[string]string{"until": "must be greater than since"}},
+		{"?sort=actor", map[string]string{"sort": "must be one of occurred_at, -occurred_at"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			app, db := newTestApp(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/admin/audit"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			app.listAuditEventsHandler(rr, r)
+
+			if got := validationErrors(t, rr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+			if db.ran("audit_events") {
+				t.Error("the audit log was read")
+			}
+		})
+	}
+}
+
+func TestListAuditEventsFilter(t *testing.T) {
+	app, db := newTestApp(t, fakeResult{match: "FROM audit_events", columns: []string{"count"}})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/admin/audit?resource_type=movie&resource_id=3&actor=alice&action=update&since=2024-01-01T00:00:00Z&page=2&page_size=10", nil)
+	rr := httptest.NewRecorder()
+	app.listAuditEventsHandler(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+
+	// an unset until is NULL, so that it matches every event
+	want := []driver.Value{"movie", int64(3), "alice", "update", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), nil, int64(10), int64(10)}
+	if got := db.argsOf("FROM audit_events"); !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %#v, want %#v", got, want)
+	}
+}
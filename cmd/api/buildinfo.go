@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+const version = "1.0.0"
+
+// set at build time with -ldflags, e.g.
+// go build -ldflags "-X main.buildCommit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+// see the build/api target in the Makefile
+var (
+	buildCommit string
+	buildTime   string
+)
+
+// buildInfo returns the version along with the commit and time of the build
+// when they were not set with -ldflags, the values the Go toolchain recorded from version control are used instead
+func buildInfo() map[string]string {
+	info := map[string]string{
+		"version":    version,
+		"commit":     buildCommit,
+		"build_time": buildTime,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["go_version"] = bi.GoVersion
+
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info["commit"] == "":
+				info["commit"] = setting.Value
+			case setting.Key == "vcs.time" && info["build_time"] == "":
+				info["build_time"] = setting.Value
+			case setting.Key == "vcs.modified" && setting.Value == "true":
+				info["modified"] = "true"
+			}
+		}
+	}
+
+	for key, value := range info {
+		if value == "" {
+			info[key] = "unknown"
+		}
+	}
+
+	return info
+}
+
+// printVersion writes the version and build information for -version
+func printVersion(w io.Writer) {
+	info := buildInfo()
+
+	fmt.Fprintf(w, "greenlight version %s\n", info["version"])
+	for _, key := range sortedKeys(info) {
+		if key != "version" {
+			fmt.Fprintf(w, "%s: %s\n", key, info[key])
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// an encoder is a compressing writer which can be flushed part way through a response and reused for the next one
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// the content codings we support, in the order we prefer them when the client has no preference
+var encodings = []string{"br", "zstd", "gzip"}
+
+// encoders are expensive to set up, so each coding keeps a pool of them
+// the levels favour speed, as the responses are compressed on every request rather than once ahead of time
+var encoderPools = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, 4)
+	}},
+	"zstd": {New: func() any {
+		enc, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			panic(err)
+		}
+		return enc
+	}},
+	"gzip": {New: func() any {
+		enc, err := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		if err != nil {
+			panic(err)
+		}
+		return enc
+	}},
+}
+
+// negotiateEncoding picks a content coding from the Accept-Encoding header, or returns "" to send the response as it is
+// a coding the client does not mention is only used if it accepts "*"
+func negotiateEncoding(header string) string {
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+
+		qualities[name] = q
+	}
+
+	var best string
+	var bestQ float64
+	for _, encoding := range encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+
+		// ties go to the coding we prefer, as the encodings are in order
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// compressible reports whether a content type is worth compressing
+// images, audio, video and archives are already compressed and only get bigger
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return false
+	case mediaType == "application/zip", mediaType == "application/gzip", mediaType == "application/zstd":
+		return false
+	}
+
+	return true
+}
+
+// a compressWriter holds back the start of a response until it knows whether to compress it
+// the body is compressed once it reaches minBytes, or when the handler flushes it so that streams are not held up
+// anything smaller, or that is not compressible, is passed through unchanged
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minBytes int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+
+	// informational responses are sent straight away and the real status follows
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	cw.status = status
+
+	// these never have a body to compress
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.passThrough()
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		if !cw.wantsCompression() {
+			cw.passThrough()
+		} else {
+			cw.buf = append(cw.buf, b...)
+			if len(cw.buf) >= cw.minBytes {
+				if err := cw.startCompression(); err != nil {
+					return 0, err
+				}
+			}
+			return len(b), nil
+		}
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far, starting compression early if need be
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.WriteHeader(http.StatusOK)
+		}
+
+		if cw.wantsCompression() {
+			if err := cw.startCompression(); err != nil {
+				return
+			}
+		} else {
+			cw.passThrough()
+		}
+	}
+
+	if cw.enc != nil {
+		if err := cw.enc.Flush(); err != nil {
+			return
+		}
+	}
+
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection over to the handler, e.g. for a WebSocket upgrade, which is never compressed
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// wantsCompression looks at the headers the handler has set to decide whether the response can be compressed
+func (cw *compressWriter) wantsCompression() bool {
+	h := cw.Header()
+	return h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type"))
+}
+
+// passThrough sends the status and anything buffered without compressing it
+func (cw *compressWriter) passThrough() {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) > 0 {
+		cw.ResponseWriter.Write(cw.buf)
+		cw.buf = nil
+	}
+}
+
+// startCompression switches the response over to the negotiated encoding and writes out what has been buffered
+func (cw *compressWriter) startCompression() error {
+	cw.decided = true
+
+	h := cw.Header()
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	// the compressed body is a different representation, so a strong validator no longer applies to it
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.enc = encoderPools[cw.encoding].Get().(encoder)
+	cw.enc.Reset(cw.ResponseWriter)
+
+	_, err := cw.enc.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+// close finishes the response once the handler has returned
+// a response that never reached minBytes is sent uncompressed, with its length
+func (cw *compressWriter) close() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			// the handler did not write anything at all, leave the response to the server
+			return nil
+		}
+		if cw.Header().Get("Content-Length") == "" {
+			cw.Header().Set("Content-Length", strconv.Itoa(len(cw.buf)))
+		}
+		cw.passThrough()
+	}
+
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+	// detach the encoder from this response before it goes back in the pool
+	cw.enc.Reset(io.Discard)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+
+	return err
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip, zstd", "zstd"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0.5, gzip;q=0.8, zstd;q=0.9", "zstd"},
+		{"gzip;q=1.0, br;q=1.0", "br"},
+		{"br;q=0, gzip", "gzip"},
+		{"br;q=0, zstd;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"*, br;q=0", "zstd"},
+		{"*;q=0", ""},
+		{"deflate", ""},
+		{"gzip;q=bogus, br;q=0.1", "br"},
+		{"gzip ; Q=0.2 , br;q=0.1", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := negotiateEncoding(tt.header)
+			if got != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tests := map[string]bool{
+		"":                                true,
+		"application/json":                true,
+		"application/problem+json":        true,
+		"text/event-stream":               true,
+		"text/html; charset=utf-8":        true,
+		"image/svg+xml":                   true,
+		"image/png":                       false,
+		"image/jpeg":                      false,
+		"video/mp4":                       false,
+		"audio/mpeg":                      false,
+		"application/zip":                 false,
+		"application/gzip":                false,
+		"application/zstd":                false,
+		"not a media type;;":              false,
+		"application/json; charset=utf-8": true,
+	}
+
+	for contentType, want := range tests {
+		if got := compressible(contentType); got != want {
+			t.Errorf("compressible(%q) = %t, want %t", contentType, got, want)
+		}
+	}
+}
+
+// decodeBody decompresses a response body with the given content coding
+func decodeBody(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		dec, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dec.Close()
+		r = dec
+	case "gzip":
+		dec, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = dec
+	default:
+		return body
+	}
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %s body: %v", encoding, err)
+	}
+
+	return decoded
+}
+
+func newCompressApp(minBytes int) *application {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	app.config.compression.minBytes = minBytes
+	return app
+}
+
+func TestCompress(t *testing.T) {
+	large := []byte(`{"movies":[` + strings.Repeat(`{"title":"Casablanca","year":1942},`, 100) + `{}]}`)
+	small := []byte(`{"status":"ok"}`)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           []byte
+		wantEncoding   string
+	}{
+		{"br", "br", "application/json", large, "br"},
+		{"zstd", "zstd", "application/json", large, "zstd"},
+		{"gzip", "gzip", "application/json", large, "gzip"},
+		{"preferred", "gzip, br, zstd", "application/json", large, "br"},
+		{"q-values", "br;q=0.1, gzip;q=0.9", "application/json", large, "gzip"},
+		{"not accepted", "deflate", "application/json", large, ""},
+		{"no header", "", "application/json", large, ""},
+		{"below threshold", "gzip", "application/json", small, ""},
+		{"incompressible type", "gzip", "image/png", large, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newCompressApp(1024).compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("ETag", `"abc"`)
+				// written in pieces, so that the threshold is crossed part way through
+				for chunk := range slices.Chunk(tt.body, 100) {
+					w.Write(chunk)
+				}
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			res := rr.Result()
+			if got := res.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := res.Header.Values("Vary"); len(got) != 1 || got[0] != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			body := rr.Body.Bytes()
+			if got := decodeBody(t, tt.wantEncoding, body); !bytes.Equal(got, tt.body) {
+				t.Errorf("decoded body is %d bytes, want the %d bytes written", len(got), len(tt.body))
+			}
+
+			if tt.wantEncoding != "" {
+				if got := res.Header.Get("Content-Length"); got != "" {
+					t.Errorf("Content-Length = %q on a compressed response, want none", got)
+				}
+				if len(body) >= len(tt.body) {
+					t.Errorf("compressed body is %d bytes, not smaller than the %d bytes written", len(body), len(tt.body))
+				}
+				if got := res.Header.Get("ETag"); got != `W/"abc"` {
+					t.Errorf(`ETag = %q, want W/"abc"`, got)
+				}
+				return
+			}
+
+			// a response held back below the threshold is sent with its length
+			if tt.acceptEncoding != "" && negotiateEncoding(tt.acceptEncoding) != "" && tt.contentType == "application/json" {
+				if got, want := res.Header.Get("Content-Length"), strconv.Itoa(len(tt.body)); got != want {
+					t.Errorf("Content-Length = %q, want %q", got, want)
+				}
+			}
+			if got := res.Header.Get("ETag"); got != `"abc"` {
+				t.Errorf(`ETag = %q, want "abc"`, got)
+			}
+		})
+	}
+}
+
+func TestCompressFlushBeforeThreshold(t *testing.T) {
+	handler := newCompressApp(1024).compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		// a stream flushes each event, it must not wait for the threshold
+		http.NewResponseController(w).Flush()
+
+		if got := w.(*compressWriter).decided; !got {
+			t.Error("compression was not decided on when the response was flushed")
+		}
+
+		w.Write([]byte("data: second\n\n"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/stream", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+
+	if !rr.Flushed {
+		t.Error("the response was not flushed")
+	}
+	if got := rr.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := string(decodeBody(t, "gzip", rr.Body.Bytes())); got != "data: first\n\ndata: second\n\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestCompressPassesThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		status  int
+		body    string
+	}{
+		{
+			name:   "head",
+			method: http.MethodHead,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "5000")
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:   "no content",
+			method: http.MethodDelete,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "already encoded",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(bytes.Repeat([]byte("x"), 2048))
+			},
+			status: http.StatusOK,
+			body:   strings.Repeat("x", 2048),
+		},
+		{
+			name:   "error status is kept",
+			method: http.MethodGet,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"not found"}`))
+			},
+			status: http.StatusNotFound,
+			body:   `{"error":"not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/v1/movies/1", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			rr := httptest.NewRecorder()
+			newCompressApp(1024).compress(tt.handler).ServeHTTP(rr, r)
+
+			if rr.Code != tt.status {
+				t.Errorf("status = %d, want %d", rr.Code, tt.status)
+			}
+			if tt.name != "already encoded" && rr.Header().Get("Content-Encoding") != "" {
+				t.Errorf("Content-Encoding = %q, want none", rr.Header().Get("Content-Encoding"))
+			}
+			if got := rr.Body.String(); got != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TaskMasterErnest/greenlight/internal/i18n"
+)
+
+// define a custom contextKey type, so that our keys cannot collide with keys set by other packages
+type contextKey string
+
+// the keys used for getting and setting the request's Localizer and request ID in the request context
+const (
+	localizerContextKey = contextKey("localizer")
+	requestIDContextKey = contextKey("requestID")
+)
+
+// contextSetLocalizer returns a new copy of the request with the Localizer added to its context
+func (app *application) contextSetLocalizer(r *http.Request, l *i18n.Localizer) *http.Request {
+	ctx := context.WithValue(r.Context(), localizerContextKey, l)
+	return r.WithContext(ctx)
+}
+
+// contextGetLocalizer retrieves the Localizer from the request context
+// requests that have not been through the localize middleware get the default locale
+func (app *application) contextGetLocalizer(r *http.Request) *i18n.Localizer {
+	l, ok := r.Context().Value(localizerContextKey).(*i18n.Localizer)
+	if !ok {
+		return i18n.New(i18n.DefaultLocale)
+	}
+
+	return l
+}
+
+// contextSetRequestID returns a new copy of the request with the request ID added to its context
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID retrieves the request ID from the request context
+// requests that have not been through the requestID middleware have an empty one
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
@@ -1,10 +1,55 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// stable, machine-readable error codes returned to the client
+// clients can branch on these instead of parsing the human-readable messages
+const (
+	errCodeServerError          = "server_error"
+	errCodeNotFound             = "not_found"
+	errCodeMethodNotAllowed     = "method_not_allowed"
+	errCodeBadRequest           = "bad_request"
+	errCodeFailedValidation     = "failed_validation"
+	errCodeNotAcceptable        = "not_acceptable"
+	errCodeUnsupportedMediaType = "unsupported_media_type"
+	errCodeContentTooLarge      = "content_too_large"
+	errCodeDuplicateMovie       = "duplicate_movie"
+	errCodeIdempotencyKeyReused = "idempotency_key_reused"
+	errCodeIdempotencyInFlight  = "idempotency_key_in_progress"
+	errCodeInvalidAdminToken    = "invalid_admin_token"
+	errCodeAdminDisabled        = "admin_disabled"
 )
 
+// problemTypePrefix is prepended to the error code to build the RFC 7807 "type" member
+const problemTypePrefix = "urn:greenlight:problem:"
+
+// invalidParam describes a single field that failed validation in a problem details response
+// the reason is translated into the client's language, the code is not
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Code   string `json:"code"`
+}
+
+// a failedValidationError carries a failed validation back from code that returns errors rather than writing
+// responses, such as the GraphQL resolvers and the gRPC methods, so that the invalid params can still be sent
+type failedValidationError struct {
+	v *validator.Validator
+}
+
+func (e failedValidationError) Error() string {
+	return "failed validation"
+}
+
 // the logError helper to log an error message with the method used and the URL requested
 func (app *application) logError(r *http.Request, err error) {
 	var (
@@ -12,56 +57,208 @@ func (app *application) logError(r *http.Request, err error) {
 		uri    = r.URL.RequestURI()
 	)
 
-	// log the error with the components
-	app.logger.Error(err.Error(), "method", method, "URI", uri)
+	// log the error with the components, and the request ID so that it can be matched up with what the client saw
+	app.logger.Error(err.Error(), "method", method, "URI", uri, "request_id", app.contextGetRequestID(r))
 }
 
-// errorResponse is a generic helper function that writes the error message to the user in JSON format
+// wantsProblem reports whether the error should be written as RFC 7807 problem details
+// this is either switched on for every request with the -problem-json flag, or asked for by the client in the Accept header
+// with application/problem+json or application/problem+xml
+func (app *application) wantsProblem(r *http.Request) bool {
+	if app.config.problemJSON {
+		return true
+	}
+
+	for _, entry := range parseAccept(r.Header.Get("Accept")) {
+		if entry.q > 0 && strings.HasPrefix(entry.mediaType, "application/problem+") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// errorResponse is a generic helper function that writes the error message to the user in the negotiated format
 // and with a given status code. The error message type is not strict to as to allow flexibility
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
+// the code is a stable identifier for the error that is used when the client asks for problem details
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code string, message any) {
 	// envelope the error message
 	env := envelope{"error": message}
+	var headers http.Header
+
+	// if the client wants RFC 7807 output, replace the envelope with a problem details object
+	// the problem is written in the negotiated format, falling back to JSON if nothing the client accepts is supported
+	if app.wantsProblem(r) {
+		f, err := negotiateFormat(r.Header.Get("Accept"))
+		if err != nil {
+			f = formatJSON
+		}
+
+		env = app.newProblem(r, status, code, message)
+		headers = http.Header{"Content-Type": []string{f.problemType}}
+	}
 
-	// convert to JSON and write to user
+	// encode and write to user
 	// if this fails, log a 500 error to the user
-	err := app.writeJSON(w, status, env, nil)
+	err := app.writeResponse(w, r, status, env, headers)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
 	}
 }
 
+// newProblem builds the problem details object for an error message
+// string messages become the detail, validation errors become the invalid_params array
+func (app *application) newProblem(r *http.Request, status int, code string, message any) envelope {
+	// the problem members sit at the top level of the document, so there is no "error" key here
+	problem := envelope{
+		"type":     problemTypePrefix + code,
+		"title":    http.StatusText(status),
+		"status":   status,
+		"instance": r.URL.RequestURI(),
+		"code":     code,
+	}
+
+	switch message := message.(type) {
+	case string:
+		problem["detail"] = message
+	case []invalidParam:
+		problem["detail"] = app.contextGetLocalizer(r).T("error.failed_validation")
+		problem["invalid_params"] = message
+	default:
+		problem["detail"] = fmt.Sprint(message)
+	}
+
+	return problem
+}
+
+// invalidParams lists every validation error in a stable order, with a field appearing once per message
+func invalidParams(v *validator.Validator) []invalidParam {
+	params := []invalidParam{}
+
+	for name, reasons := range v.FieldErrors {
+		for i, reason := range reasons {
+			params = append(params, invalidParam{Name: name, Reason: reason, Code: v.Codes[name][i]})
+		}
+	}
+
+	// sort the params so that the output is stable between requests
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+
+	return params
+}
+
 // a detailed serverErrorResponse() method to log server errors at runtime
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	// log the error gotten
 	app.logError(r, err)
 
-	// craft a message
-	message := "The server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	// craft a message in the client's language
+	message := app.contextGetLocalizer(r).T("error.server_error")
+	app.errorResponse(w, r, http.StatusInternalServerError, errCodeServerError, message)
 }
 
 // a detailed notFoundResponse
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 
-	message := "The requested resource could not be found"
+	message := app.contextGetLocalizer(r).T("error.not_found")
 
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, errCodeNotFound, message)
 }
 
 // a detailed methodNotAllowedResponse error response
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := fmt.Sprintf("The %s method is not supported for this resource", r.Method)
+	message := app.contextGetLocalizer(r).T("error.method_not_allowed", r.Method)
 
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, message)
 }
 
 // a badRequestResponse
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.errorResponse(w, r, http.StatusBadRequest, errCodeBadRequest, app.requestErrorMessage(r, err))
+}
+
+// requestErrorMessage describes a request that could not be read in the client's language,
+// other errors are written as they are
+func (app *application) requestErrorMessage(r *http.Request, err error) string {
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		return requestErr.message(app.contextGetLocalizer(r))
+	}
+
+	return err.Error()
 }
 
 // add a FailedValidationResponse error
-func (app *application) FailedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// the plain response keeps the first message per field, problem details list every message for every field
+func (app *application) FailedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	var message any = v.Errors
+	if app.wantsProblem(r) {
+		message = invalidParams(v)
+	}
+
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, errCodeFailedValidation, message)
+}
+
+// a notAcceptableResponse for when none of the media types in the Accept header can be produced
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.contextGetLocalizer(r).T("error.not_acceptable")
+	app.errorResponse(w, r, http.StatusNotAcceptable, errCodeNotAcceptable, message)
+}
+
+// an unsupportedMediaTypeResponse for request bodies in a format we cannot read
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.contextGetLocalizer(r).T("error.unsupported_media_type", r.Header.Get("Content-Type"))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType, message)
+}
+
+// a contentTooLargeResponse for request bodies, such as uploads, that are over the size limit
+func (app *application) contentTooLargeResponse(w http.ResponseWriter, r *http.Request, limit int64) {
+	message := app.contextGetLocalizer(r).T("error.content_too_large", limit)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, errCodeContentTooLarge, message)
+}
+
+// a duplicateMovieResponse for a movie that matches one already stored
+// the Location header and the message both point the client at the existing movie
+func (app *application) duplicateMovieResponse(w http.ResponseWriter, r *http.Request, err *data.DuplicateMovieError) {
+	location := fmt.Sprintf("/v1/movies/%d", err.ID)
+	w.Header().Set("Location", location)
+
+	l := app.contextGetLocalizer(r)
+	message := l.T("error.duplicate_movie_external_id", err.Field, location)
+	if err.Field == "title" {
+		message = l.T("error.duplicate_movie_title", location)
+	}
+
+	app.errorResponse(w, r, http.StatusConflict, errCodeDuplicateMovie, message)
+}
+
+// an idempotencyKeyReusedResponse for an Idempotency-Key that was first sent with a different request
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.contextGetLocalizer(r).T("error.idempotency_key_reused")
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, errCodeIdempotencyKeyReused, message)
+}
+
+// an idempotencyKeyInProgressResponse for a retry that arrives while the first request is still being handled
+func (app *application) idempotencyKeyInProgressResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+
+	message := app.contextGetLocalizer(r).T("error.idempotency_key_in_progress")
+	app.errorResponse(w, r, http.StatusConflict, errCodeIdempotencyInFlight, message)
+}
+
+// an invalidAdminTokenResponse for an admin route requested without the admin token
+func (app *application) invalidAdminTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := app.contextGetLocalizer(r).T("error.invalid_admin_token")
+	app.errorResponse(w, r, http.StatusUnauthorized, errCodeInvalidAdminToken, message)
+}
+
+// an adminDisabledResponse for an admin route on a server without an admin token
+func (app *application) adminDisabledResponse(w http.ResponseWriter, r *http.Request) {
+	message := app.contextGetLocalizer(r).T("error.admin_disabled")
+	app.errorResponse(w, r, http.StatusForbidden, errCodeAdminDisabled, message)
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/i18n"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+func TestWantsProblem(t *testing.T) {
+	tests := []struct {
+		accept      string
+		problemJSON bool
+		want        bool
+	}{
+		{"", false, false},
+		{"application/json", false, false},
+		{"*/*", false, false},
+		{"application/problem+json", false, true},
+		{"application/problem+xml", false, true},
+		{"application/json, application/problem+json;q=0.5", false, true},
+		{"application/problem+json;q=0", false, false},
+		// the flag turns problem details on for everyone
+		{"", true, true},
+		{"application/json", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			app := &application{}
+			app.config.problemJSON = tt.problemJSON
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			if got := app.wantsProblem(r); got != tt.want {
+				t.Errorf("wantsProblem() with Accept %q and -problem-json=%t = %t, want %t", tt.accept, tt.problemJSON, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		want        map[string]any
+	}{
+		{
+			accept:      "",
+			contentType: "application/json",
+			want:        map[string]any{"error": "The requested resource could not be found"},
+		},
+		{
+			accept:      "application/problem+json",
+			contentType: "application/problem+json",
+			want: map[string]any{
+				"type":     "urn:greenlight:problem:not_found",
+				"title":    "Not Found",
+				"status":   float64(http.StatusNotFound),
+				"detail":   "The requested resource could not be found",
+				"instance": "/v1/movies/99?include=credits",
+				"code":     "not_found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/99?include=credits", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			app.notFoundResponse(rr, r)
+
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// problem details follow the negotiated format
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/99", nil)
+	r.Header.Set("Accept", "application/problem+xml")
+	rr := httptest.NewRecorder()
+	app.notFoundResponse(rr, r)
+
+	if got := rr.Header().Get("Content-Type"); got != "application/problem+xml" {
+		t.Errorf("Content-Type = %q, want application/problem+xml", got)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "<code>not_found</code>") || !strings.Contains(body, "<status>404</status>") {
+		t.Errorf("body is not an XML problem:\n%s", body)
+	}
+}
+
+func TestFailedValidationResponse(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	newValidator := func() *validator.Validator {
+		v := validator.New()
+		v.AddError("year", "validation.required")
+		v.AddError("title", "validation.required")
+		v.AddError("title", "validation.max_chars", "500")
+		return v
+	}
+
+	// the default envelope keeps the first message for each field
+	r := httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+	rr := httptest.NewRecorder()
+	app.FailedValidationResponse(rr, r, newValidator())
+
+	var plain struct {
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"title": "must be provided", "year": "must be provided"}; !reflect.DeepEqual(plain.Error, want) {
+		t.Errorf("error = %v, want %v", plain.Error, want)
+	}
+
+	// problem details list every message, sorted by field, with its code
+	r = httptest.NewRequest(http.MethodPost, "/v1/movies", nil)
+	r.Header.Set("Accept", "application/problem+json")
+	rr = httptest.NewRecorder()
+	app.FailedValidationResponse(rr, r, newValidator())
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+
+	var problem struct {
+		Type          string         `json:"type"`
+		Code          string         `json:"code"`
+		Detail        string         `json:"detail"`
+		InvalidParams []invalidParam `json:"invalid_params"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+
+	if problem.Type != "urn:greenlight:problem:failed_validation" || problem.Code != "failed_validation" {
+		t.Errorf("type = %q and code = %q, want failed_validation", problem.Type, problem.Code)
+	}
+	if problem.Detail == "" {
+		t.Error("detail is empty")
+	}
+
+	want := []invalidParam{
+		{Name: "title", Reason: "must be provided", Code: "required"},
+		{Name: "title", Reason: "must not be more than 500 characters long", Code: "max_chars"},
+		{Name: "year", Reason: "must be provided", Code: "required"},
+	}
+	if !reflect.DeepEqual(problem.InvalidParams, want) {
+		t.Errorf("invalid_params = %+v, want %+v", problem.InvalidParams, want)
+	}
+}
+
+func TestBadRequestResponse(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		locale      string
+		want        string
+	}{
+		{"empty", "application/json", "", "en", "body must not be empty"},
+		{"badly-formed", "application/json", `{"title": }`, "en", "body contains badly-formed JSON (at character 11)"},
+		{"cut short", "application/json", `{"title": "Up"`, "en", "body contains badly-formed JSON"},
+		{"wrong type", "application/json", `{"year": "1942"}`, "en", `body contains incorrect JSON type for field "year"`},
+		{"unknown key", "application/json", `{"rating": 5}`, "en", `body contains unknown key "rating"`},
+		{"two values", "application/json", `{} {}`, "en", "body must only contain a single JSON value"},
+		{"badly-formed YAML", "application/yaml", "title: [", "en", "body contains badly-formed YAML"},
+		{"French", "application/json", `{"rating": 5}`, "fr", `le corps contient la clé inconnue "rating"`},
+		{"German", "application/json", "", "de", "der Text darf nicht leer sein"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			r = app.contextSetLocalizer(r, i18n.New(tt.locale))
+
+			var input struct {
+				Title string `json:"title"`
+				Year  int32  `json:"year"`
+			}
+			rr := httptest.NewRecorder()
+			err := app.readRequest(rr, r, &input)
+			if err == nil {
+				t.Fatal("readRequest() did not return an error")
+			}
+			app.badRequestResponse(rr, r, err)
+
+			var got struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if rr.Code != http.StatusBadRequest || got.Error != tt.want {
+				t.Errorf("response = %d %q, want %d %q", rr.Code, got.Error, http.StatusBadRequest, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteMessageLocalized(t *testing.T) {
+	app, _ := newTestApp(t, fakeResult{match: "DELETE FROM webhooks", rowsAffected: 1})
+
+	r := withParams(httptest.NewRequest(http.MethodDelete, "/v1/admin/webhooks/1", nil), "id", "1")
+	r = app.contextSetLocalizer(r, i18n.New("fr"))
+	rr := httptest.NewRecorder()
+	app.deleteWebhookHandler(rr, r)
+
+	var got struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || got.Message != "webhook supprimé avec succès" {
+		t.Errorf("response = %d %q, want the French message", rr.Code, got.Message)
+	}
+}
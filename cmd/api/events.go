@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// listEventsHandler reads the change feed, the events that come after the ?after= cursor, oldest first
+// consumers keep the next_cursor from each response and send it back as ?after= to carry on, or start again from 0
+// to replay every change; an empty page means they are up to date, and they can poll again with the same cursor
+func (app *application) listEventsHandler(w http.ResponseWriter, r *http.Request) {
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	after := int64(app.readInt(qs, "after", 0, v))
+	limit := app.readInt(qs, "limit", data.EventsLimitDefault, v)
+
+	if data.ValidateEventsCursor(v, after, limit); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	events, err := app.models.Events.GetAfter(after, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the cursor stays where it is when there is nothing new
+	next := after
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+
+	env := envelope{"events": events, "next_cursor": next, "has_more": len(events) == limit}
+
+	err = app.writeResponse(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var eventColumns = []string{"id", "occurred_at", "event_type", "resource_id", "payload"}
+
+// eventRow is a change feed entry for a movie with the genres
+func eventRow(id int64, eventType string, movieID int64, genres string) []driver.Value {
+	payload := `{"id": ` + strconv.FormatInt(movieID, 10) + `, "title": "Casablanca", "genres": ` + genres + `}`
+	return []driver.Value{id, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), eventType, movieID, []byte(payload)}
+}
+
+func TestListEventsValidation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{"?after=-1", map[string]string{"after": "must be at least 0"}},
+		{"?after=abc", map[string]string{"after": "must be an integer value"}},
+		{"?limit=0", map[string]string{"limit": "must be at least 1"}},
+		{"?limit=1001", map[string]string{"limit": "must not be more than 1000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			app, db := newTestApp(t)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/events"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			app.listEventsHandler(rr, r)
+
+			if got := validationErrors(t, rr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+			if db.ran("outbox") {
+				t.Error("the change feed was read")
+			}
+		})
+	}
+}
+
+func TestListEventsCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		rows     [][]driver.Value
+		args     []driver.Value
+		cursor   int64
+		hasMore  bool
+		eventIDs []int64
+	}{
+		{
+			name:     "from the start",
+			query:    "",
+			rows:     [][]driver.Value{eventRow(1, "movie.created", 1, `["drama"]`), eventRow(2, "movie.updated", 1, `["drama"]`)},
+			args:     []driver.Value{int64(0), int64(100)},
+			cursor:   2,
+			eventIDs: []int64{1, 2},
+		},
+		{
+			name:     "a full page has more",
+			query:    "?after=5&limit=2",
+			rows:     [][]driver.Value{eventRow(6, "movie.created", 3, `[]`), eventRow(9, "movie.deleted", 2, `[]`)},
+			args:     []driver.Value{int64(5), int64(2)},
+			cursor:   9,
+			hasMore:  true,
+			eventIDs: []int64{6, 9},
+		},
+		{
+			// the cursor is kept, so the consumer can poll again with it
+			name:     "up to date",
+			query:    "?after=9",
+			args:     []driver.Value{int64(9), int64(100)},
+			cursor:   9,
+			eventIDs: []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t, fakeResult{match: "FROM outbox", columns: eventColumns, rows: tt.rows})
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/events"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			app.listEventsHandler(rr, r)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+			if got := db.argsOf("FROM outbox"); !reflect.DeepEqual(got, tt.args) {
+				t.Errorf("args = %v, want %v", got, tt.args)
+			}
+
+			var body struct {
+				Events []struct {
+					ID   int64           `json:"id"`
+					Type string          `json:"type"`
+					Data json.RawMessage `json:"data"`
+				} `json:"events"`
+				NextCursor *int64 `json:"next_cursor"`
+				HasMore    *bool  `json:"has_more"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.NextCursor == nil || *body.NextCursor != tt.cursor {
+				t.Errorf("next_cursor = %v, want %d", body.NextCursor, tt.cursor)
+			}
+			if body.HasMore == nil || *body.HasMore != tt.hasMore {
+				t.Errorf("has_more = %v, want %t", body.HasMore, tt.hasMore)
+			}
+
+			// events is always a list, and the payload is passed through as JSON
+			if body.Events == nil {
+				t.Fatal("events is missing or null, want a list")
+			}
+			ids := []int64{}
+			for _, event := range body.Events {
+				ids = append(ids, event.ID)
+				if !json.Valid(event.Data) || event.Data[0] != '{' {
+					t.Errorf("event %d data = %s, want the movie object", event.ID, event.Data)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.eventIDs) {
+				t.Errorf("event ids = %v, want %v", ids, tt.eventIDs)
+			}
+		})
+	}
+}
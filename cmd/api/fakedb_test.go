@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// a fakeResult is what the fake database answers to a query containing match
+// columns and rows are returned from queries, rowsAffected from statements
+type fakeResult struct {
+	match        string
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+}
+
+// a fakeDB is a database/sql connector that answers queries from a list of canned results
+// so that handlers can be tested without a PostgreSQL server
+// queries that match no result fail, which makes a test fail loudly when a handler reaches the database unexpectedly
+type fakeDB struct {
+	mu      sync.Mutex
+	results []fakeResult
+	queries []string
+	args    [][]driver.Value
+	// pingErr is returned by pings, for the readiness probe
+	pingErr error
+}
+
+// newTestApp returns an application whose models use a fakeDB answering with the results
+func newTestApp(t *testing.T, results ...fakeResult) (*application, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{results: results}
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+
+	app := &application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		db:     db,
+		models: data.NewModels(db),
+	}
+
+	return app, fake
+}
+
+// ran reports whether a query containing s was run
+func (f *fakeDB) ran(s string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, query := range f.queries {
+		if strings.Contains(query, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// argsOf returns the arguments of the last query containing s, or nil if there was none
+func (f *fakeDB) argsOf(s string) []driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.queries) - 1; i >= 0; i-- {
+		if strings.Contains(f.queries[i], s) {
+			return f.args[i]
+		}
+	}
+
+	return nil
+}
+
+// result finds the first result matching the query, and records that the query was run with the args
+func (f *fakeDB) result(query string, args []driver.Value) (fakeResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries = append(f.queries, query)
+	f.args = append(f.args, args)
+
+	for _, r := range f.results {
+		if strings.Contains(query, r.match) {
+			return r, r.err
+		}
+	}
+
+	return fakeResult{}, errors.New("fakedb: unexpected query: " + strings.Join(strings.Fields(query), " "))
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ f *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.f}, nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.f, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+func (c fakeConn) Ping(context.Context) error                { return c.f.pingErr }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	f     *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	r, err := s.f.result(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(r.rowsAffected), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	r, err := s.f.result(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{columns: r.columns, rows: r.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
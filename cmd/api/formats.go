@@ -0,0 +1,596 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// a format describes one of the representations that the API can read and write
+// every format is converted to and from JSON, so that custom JSON (un)marshalers like data.Runtime's
+// produce the same representation whatever the client asks for
+type format struct {
+	// name is used in the error messages sent back to the client, e.g. "body contains badly-formed XML"
+	name string
+	// mediaTypes lists the accepted media types, the first one is used as the response Content-Type
+	mediaTypes []string
+	// problemType is the Content-Type used for RFC 7807 problem details in this format
+	problemType string
+	// encode converts a JSON-compatible value (maps, slices, strings, float64s, bools and nil) to this format
+	encode func(value any) ([]byte, error)
+	// toJSON converts a request body in this format to JSON, using the destination type as a guide where needed
+	toJSON func(body []byte, dest reflect.Type) ([]byte, error)
+}
+
+// the supported formats, in order of preference when the client does not care
+var (
+	formatJSON = &format{
+		name:        "JSON",
+		mediaTypes:  []string{"application/json"},
+		problemType: "application/problem+json",
+	}
+	formatXML = &format{
+		name:        "XML",
+		mediaTypes:  []string{"application/xml", "text/xml"},
+		problemType: "application/problem+xml",
+		encode:      encodeXML,
+		toJSON:      xmlToJSON,
+	}
+	formatYAML = &format{
+		name:        "YAML",
+		mediaTypes:  []string{"application/yaml", "application/x-yaml", "text/yaml"},
+		problemType: "application/yaml",
+		encode:      yaml.Marshal,
+		toJSON:      yamlToJSON,
+	}
+	formatMsgPack = &format{
+		name:        "MessagePack",
+		mediaTypes:  []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		problemType: "application/msgpack",
+		encode:      msgpack.Marshal,
+		toJSON:      msgpackToJSON,
+	}
+
+	formats = []*format{formatJSON, formatXML, formatYAML, formatMsgPack}
+)
+
+// errNotAcceptable is returned when none of the media types in the Accept header can be produced
+var errNotAcceptable = errors.New("not acceptable")
+
+// contentType returns the canonical media type of the format
+func (f *format) contentType() string {
+	return f.mediaTypes[0]
+}
+
+// matches reports whether the format can produce the given media type
+func (f *format) matches(mediaType string) bool {
+	if mediaType == f.problemType {
+		return true
+	}
+
+	for _, mt := range f.mediaTypes {
+		if mediaType == mt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// marshal encodes the data in the format
+// compact only applies to JSON, which is otherwise indented to make it easier to read
+func (f *format) marshal(data any, compact bool) ([]byte, error) {
+	if f.encode == nil {
+		// marshal the data
+		var js []byte
+		var err error
+		if compact {
+			js, err = json.Marshal(data)
+		} else {
+			js, err = json.MarshalIndent(data, "", "    ") // 4 spaces for the indentation
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// add a newline nicety
+		return append(js, '\n'), nil
+	}
+
+	// convert the data to plain maps and slices first, so that every format matches the JSON output
+	value, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.encode(value)
+}
+
+// acceptEntry holds a single media range from an Accept header along with its quality value
+type acceptEntry struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept splits an Accept header into its media ranges, sorted with the most preferred first
+// entries with the same quality keep the order the client sent them in
+func parseAccept(header string) []acceptEntry {
+	var entries []acceptEntry
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		entries = append(entries, acceptEntry{mediaType: mediaType, q: q})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].q > entries[j].q
+	})
+
+	return entries
+}
+
+// negotiateFormat picks the response format from the Accept header
+// a missing header, */* and application/* all get JSON; errNotAcceptable is returned if nothing matches
+func negotiateFormat(header string) (*format, error) {
+	if strings.TrimSpace(header) == "" {
+		return formatJSON, nil
+	}
+
+	for _, entry := range parseAccept(header) {
+		if entry.q == 0 {
+			continue
+		}
+
+		if entry.mediaType == "*/*" || entry.mediaType == "application/*" {
+			return formatJSON, nil
+		}
+
+		// text/* can only be satisfied by the text based media types
+		if entry.mediaType == "text/*" {
+			return formatXML, nil
+		}
+
+		for _, f := range formats {
+			if f.matches(entry.mediaType) {
+				return f, nil
+			}
+		}
+	}
+
+	return nil, errNotAcceptable
+}
+
+// requestFormat returns the format of the request body based on its Content-Type header
+// a missing Content-Type is treated as JSON, so existing clients keep working
+func requestFormat(header string) (*format, bool) {
+	if header == "" {
+		return formatJSON, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, f := range formats {
+		if f.matches(mediaType) {
+			return f, true
+		}
+	}
+
+	return nil, false
+}
+
+// toGeneric converts a value into plain maps, slices and scalars by round-tripping it through JSON
+// this is what keeps the output of every format consistent with the JSON output
+func toGeneric(data any) (any, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return fromJSONNumbers(value), nil
+}
+
+// fromJSONNumbers replaces json.Number values with int64 or float64 values
+// so that the YAML and MessagePack encoders write them as numbers rather than strings
+func fromJSONNumbers(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, v := range value {
+			value[key] = fromJSONNumbers(v)
+		}
+	case []any:
+		for i, v := range value {
+			value[i] = fromJSONNumbers(v)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	}
+
+	return value
+}
+
+// encodeXML writes a generic value as an XML document with a <response> root element
+// map keys become elements, slice values are written as repeated <item> elements
+func encodeXML(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "    ")
+
+	if err := writeXMLElement(enc, "response", value); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeXMLElement writes a single element, recursing into maps and slices
+func writeXMLElement(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	// keys like "genres/2" are not valid element names, so fall back to an <entry key="..."> element
+	if !isXMLName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := writeXMLElement(enc, key, value[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			if err := writeXMLElement(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+		// a null value is written as an empty element
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(value))); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// isXMLName reports whether s can be used as an XML element name as-is
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && (r == '-' || r == '.' || (r >= '0' && r <= '9')):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// xmlNode is a parsed XML element
+type xmlNode struct {
+	name     string
+	text     string
+	children []*xmlNode
+}
+
+// xmlToJSON converts an XML request body to JSON
+// XML has no types of its own, so the destination type decides whether text becomes a string, number or list
+func xmlToJSON(body []byte, dest reflect.Type) ([]byte, error) {
+	root, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(xmlValue(root, dest))
+}
+
+// parseXML reads the XML document into a tree of nodes and returns the root element
+func parseXML(body []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	var (
+		root  *xmlNode
+		stack []*xmlNode
+	)
+
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if root != nil && len(stack) == 0 {
+				return nil, errors.New("multiple root elements")
+			}
+
+			node := &xmlNode{name: token.Name.Local}
+			// honour the <entry key="..."> form that the encoder writes for awkward keys
+			for _, attr := range token.Attr {
+				if node.name == "entry" && attr.Name.Local == "key" {
+					node.name = attr.Value
+				}
+			}
+
+			if len(stack) == 0 {
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, io.EOF
+	}
+
+	return root, nil
+}
+
+// jsonUnmarshalerType is used to spot types such as data.Runtime that parse their own JSON
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// xmlValue converts a node into a JSON-compatible value that matches the shape of t
+func xmlValue(node *xmlNode, t reflect.Type) any {
+	text := strings.TrimSpace(node.text)
+
+	if t == nil {
+		if len(node.children) > 0 {
+			return xmlValue(node, reflect.TypeFor[map[string]any]())
+		}
+		return text
+	}
+
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		// hand the text over as a JSON string, or as a number if it looks like one
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(text)
+		}
+		return text
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return xmlValue(node, t.Elem())
+	case reflect.Struct:
+		fields := jsonFields(t)
+		obj := make(map[string]any, len(node.children))
+		for _, child := range node.children {
+			// unknown elements are kept so that the JSON decoder can reject them as unknown keys
+			obj[child.name] = xmlValue(child, fields[child.name])
+		}
+		return obj
+	case reflect.Map:
+		obj := make(map[string]any, len(node.children))
+		for _, child := range node.children {
+			obj[child.name] = xmlValue(child, t.Elem())
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		list := make([]any, 0, len(node.children))
+		for _, child := range node.children {
+			list = append(list, xmlValue(child, t.Elem()))
+		}
+		return list
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// anything that is not a number is passed on as a string, giving the usual "incorrect type" error
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(text)
+		}
+		return text
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+		return text
+	case reflect.Interface:
+		return xmlValue(node, nil)
+	default:
+		return text
+	}
+}
+
+// jsonFields maps the JSON names of a struct's exported fields to their types
+// the fields of embedded structs are promoted the way encoding/json promotes them: a shallower field hides a deeper
+// one of the same name, a tagged field beats an untagged one at the same depth, and any other clash leaves the name out
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	candidates := make(map[string][]jsonField)
+	collectJSONFields(t, 0, map[reflect.Type]bool{}, candidates)
+
+	fields := make(map[string]reflect.Type, len(candidates))
+	for name, found := range candidates {
+		if field, ok := dominantJSONField(found); ok {
+			fields[name] = field.typ
+		}
+	}
+
+	return fields
+}
+
+// a jsonField is a field that may end up with a JSON name, along with how deeply it is embedded
+type jsonField struct {
+	typ    reflect.Type
+	depth  int
+	tagged bool
+}
+
+// collectJSONFields adds the fields of t to candidates, walking into embedded structs
+// seen holds the structs already being walked, so a struct which embeds a pointer to itself does not loop forever
+func collectJSONFields(t reflect.Type, depth int, seen map[reflect.Type]bool, candidates map[string][]jsonField) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tagName == "-" {
+			continue
+		}
+
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			// the exported fields of an embedded struct are promoted, even when the struct type itself is unexported
+			if tagName == "" && ft.Kind() == reflect.Struct {
+				collectJSONFields(ft, depth+1, seen, candidates)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+		} else if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tagName != "" {
+			name = tagName
+		}
+
+		candidates[name] = append(candidates[name], jsonField{typ: field.Type, depth: depth, tagged: tagName != ""})
+	}
+}
+
+// dominantJSONField picks the field that a JSON name refers to, reporting false when the name is ambiguous
+func dominantJSONField(found []jsonField) (jsonField, bool) {
+	shallowest := found[0].depth
+	for _, field := range found[1:] {
+		shallowest = min(shallowest, field.depth)
+	}
+
+	var all, tagged []jsonField
+	for _, field := range found {
+		if field.depth != shallowest {
+			continue
+		}
+		all = append(all, field)
+		if field.tagged {
+			tagged = append(tagged, field)
+		}
+	}
+
+	switch {
+	case len(all) == 1:
+		return all[0], true
+	case len(tagged) == 1:
+		return tagged[0], true
+	default:
+		return jsonField{}, false
+	}
+}
+
+// yamlToJSON converts a YAML request body containing a single document to JSON
+func yamlToJSON(body []byte, _ reflect.Type) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(body))
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if err := dec.Decode(new(any)); !errors.Is(err, io.EOF) {
+		return nil, errors.New("body must only contain a single YAML document")
+	}
+
+	return json.Marshal(value)
+}
+
+// msgpackToJSON converts a MessagePack request body containing a single value to JSON
+func msgpackToJSON(body []byte, _ reflect.Type) ([]byte, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(body))
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if err := dec.Decode(new(any)); !errors.Is(err, io.EOF) {
+		return nil, errors.New("body must only contain a single MessagePack value")
+	}
+
+	return json.Marshal(value)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *format
+	}{
+		{"", formatJSON},
+		{"application/json", formatJSON},
+		{"application/xml", formatXML},
+		{"text/xml", formatXML},
+		{"application/x-yaml", formatYAML},
+		{"application/vnd.msgpack", formatMsgPack},
+		{"application/problem+json", formatJSON},
+		{"application/problem+xml", formatXML},
+		{"application/xml; charset=utf-8", formatXML},
+		// wildcards
+		{"*/*", formatJSON},
+		{"application/*", formatJSON},
+		{"text/*", formatXML},
+		{"text/html, */*;q=0.1", formatJSON},
+		// quality values
+		{"application/json;q=0.5, application/xml", formatXML},
+		{"application/yaml;q=0.9, application/msgpack;q=0.9", formatYAML},
+		{"application/json;q=0, application/yaml", formatYAML},
+		{"text/html, application/xml;q=0.2", formatXML},
+		// entries that cannot be parsed are skipped
+		{"application/json;q=bogus, application/yaml;q=0.1", formatYAML},
+		{"not a media type, application/xml", formatXML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := negotiateFormat(tt.header)
+			if err != nil {
+				t.Fatalf("negotiateFormat(%q) error = %v", tt.header, err)
+			}
+			if got != tt.want {
+				t.Errorf("negotiateFormat(%q) = %s, want %s", tt.header, got.name, tt.want.name)
+			}
+		})
+	}
+}
+
+func TestNegotiateFormatNotAcceptable(t *testing.T) {
+	tests := []string{
+		"text/html",
+		"image/png, application/pdf",
+		"application/json;q=0",
+		"*/*;q=0",
+		"text/*;q=0, application/xml;q=0",
+		"application/json;q=bogus",
+	}
+
+	for _, header := range tests {
+		t.Run(header, func(t *testing.T) {
+			got, err := negotiateFormat(header)
+			if !errors.Is(err, errNotAcceptable) {
+				t.Errorf("negotiateFormat(%q) = %v, %v, want errNotAcceptable", header, got, err)
+			}
+		})
+	}
+}
+
+func TestNegotiateContent(t *testing.T) {
+	app := &application{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	handler := app.negotiateContent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{name: "no headers", status: http.StatusNoContent},
+		{name: "supported", accept: "application/yaml", contentType: "application/xml", body: "<movie/>", status: http.StatusNoContent},
+		{name: "not acceptable", accept: "text/html", status: http.StatusNotAcceptable},
+		{name: "only refused types", accept: "application/json;q=0", status: http.StatusNotAcceptable},
+		{name: "unsupported body", contentType: "text/csv", body: "title,year", status: http.StatusUnsupportedMediaType},
+		// the Content-Type is only checked when there is a body
+		{name: "no body", contentType: "text/csv", status: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = bytes.NewBufferString(tt.body)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", body)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, r)
+
+			if rr.Code != tt.status {
+				t.Errorf("status = %d, want %d", rr.Code, tt.status)
+			}
+			// the error itself is always written in a format the client can read
+			if tt.status == http.StatusNotAcceptable && rr.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	movie := data.Movie{
+		ID:            1,
+		Title:         "Casablanca",
+		Year:          1942,
+		Runtime:       102,
+		Genres:        []string{"drama", "romance", "war"},
+		Version:       3,
+		ExternalIDs:   data.ExternalIDs{"imdb": "tt0034583", "tmdb": "289"},
+		AverageRating: 4.5,
+		RatingCount:   12,
+		Poster: &data.Poster{
+			ContentType: "image/png",
+			Width:       600,
+			Height:      900,
+			Size:        48213,
+			UpdatedAt:   time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+			URL:         "/v1/movies/1/poster",
+			Thumbnails:  map[string]string{"small": "/v1/movies/1/poster?size=small"},
+		},
+	}
+
+	app := &application{}
+
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			// write the movie out as a response in the format
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1", nil)
+			r.Header.Set("Accept", f.contentType())
+			rr := httptest.NewRecorder()
+
+			err := app.writeResponse(rr, r, http.StatusOK, envelope{"movie": movie}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rr.Header().Get("Content-Type"); got != f.contentType() {
+				t.Errorf("Content-Type = %q, want %q", got, f.contentType())
+			}
+
+			// and read it back in as a request body
+			r = httptest.NewRequest(http.MethodPut, "/v1/movies/1", bytes.NewReader(rr.Body.Bytes()))
+			r.Header.Set("Content-Type", f.contentType())
+
+			var input struct {
+				Movie data.Movie `json:"movie"`
+			}
+			err = app.readRequest(httptest.NewRecorder(), r, &input)
+			if err != nil {
+				t.Fatalf("readRequest() error = %v, body:\n%s", err, rr.Body.String())
+			}
+
+			if !reflect.DeepEqual(input.Movie, movie) {
+				t.Errorf("movie = %+v, want %+v", input.Movie, movie)
+			}
+		})
+	}
+}
+
+type jsonBase struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Comment string
+}
+
+type jsonAudit struct {
+	Name    string `json:"name"`
+	Version int32  `json:"version"`
+	Comment string
+}
+
+type jsonTagged struct {
+	Comment bool `json:"Comment"`
+}
+
+type jsonSelf struct {
+	*jsonSelf
+	Depth int `json:"depth"`
+}
+
+type jsonEmbedding struct {
+	jsonBase
+	*jsonAudit
+	jsonTagged
+	Named   jsonBase `json:"named"`
+	Version string   `json:"version"`
+	Self    jsonSelf `json:"self"`
+}
+
+func TestJSONFields(t *testing.T) {
+	got := jsonFields(reflect.TypeFor[jsonEmbedding]())
+
+	want := map[string]reflect.Type{
+		// promoted from the embedded structs, including the unexported and pointer ones
+		"id": reflect.TypeFor[int64](),
+		// the outer field hides the embedded one
+		"version": reflect.TypeFor[string](),
+		// the tagged field wins over the untagged ones at the same depth
+		"Comment": reflect.TypeFor[bool](),
+		// a struct embedded under a name is not flattened
+		"named": reflect.TypeFor[jsonBase](),
+		"self":  reflect.TypeFor[jsonSelf](),
+		// name is in both jsonBase and jsonAudit at the same depth, both tagged, so encoding/json leaves it out
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("jsonFields() = %v, want %v", got, want)
+	}
+
+	// the names match the ones encoding/json writes
+	js, err := json.Marshal(jsonEmbedding{jsonAudit: &jsonAudit{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written map[string]any
+	if err := json.Unmarshal(js, &written); err != nil {
+		t.Fatal(err)
+	}
+	for name := range written {
+		if _, ok := got[name]; !ok {
+			t.Errorf("encoding/json writes %q, which jsonFields does not have", name)
+		}
+	}
+	if len(written) != len(got) {
+		t.Errorf("encoding/json writes %d fields, jsonFields has %d", len(written), len(got))
+	}
+
+	// a struct that embeds a pointer to itself does not loop
+	if got := jsonFields(reflect.TypeFor[jsonSelf]()); len(got) != 1 || got["depth"] != reflect.TypeFor[int]() {
+		t.Errorf("jsonFields(jsonSelf) = %v, want depth", got)
+	}
+}
+
+func TestXMLEmbeddedFields(t *testing.T) {
+	// the embedded fields are typed from the embedded struct, so the number stays a number
+	body := `<input><id>7</id><version>v2</version><Comment>true</Comment></input>`
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/movies", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/xml")
+
+	var input jsonEmbedding
+	app := &application{}
+	if err := app.readRequest(httptest.NewRecorder(), r, &input); err != nil {
+		t.Fatal(err)
+	}
+
+	if input.ID != 7 || input.Version != "v2" || !input.jsonTagged.Comment {
+		t.Errorf("input = %+v, want id 7, version v2 and Comment set", input)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// listGenresHandler returns the genre vocabulary along with how many movies use each genre
+func (app *application) listGenresHandler(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genres.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// genreInput is the body of a request to add a genre
+// the slug is optional, it is generated from the name when left out
+type genreInput struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// createGenreHandler adds a genre to the vocabulary
+func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
+	var input genreInput
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	genre := &data.Genre{
+		Name: input.Name,
+		Slug: input.Slug,
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateGenre(v, genre); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Genres.Insert(genre)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateGenre):
+			v.AddError("slug", "validation.duplicate_genre_slug")
+			app.FailedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"genre": genre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
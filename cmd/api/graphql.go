@@ -0,0 +1,378 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// the error codes that are particular to /v1/graphql
+const (
+	errCodeQueryTooDeep    = "query_too_deep"
+	errCodeQueryTooComplex = "query_too_complex"
+)
+
+// how many items a list in a query is counted as when working out its complexity, unless a page size is given
+const graphqlDefaultListSize = 20
+
+// graphqlRequest is the body of a POST to /v1/graphql, or the query string of a GET
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	// sent by some clients, e.g. for persisted queries, which we do not support, so it is ignored
+	Extensions map[string]any `json:"extensions"`
+}
+
+// graphqlHandler runs a GraphQL query or mutation against the movies
+// GraphQL always answers in JSON and reads JSON bodies, so it is on the media router and does its own checks;
+// errors are sent as GraphQL errors, with the same codes as the rest of the API in their extensions
+// queries can be sent with GET or POST, mutations only with POST
+func (app *application) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var input graphqlRequest
+
+	if r.Method == http.MethodGet {
+		qs := r.URL.Query()
+		input.Query = qs.Get("query")
+		input.OperationName = qs.Get("operationName")
+
+		if s := qs.Get("variables"); s != "" {
+			err := json.Unmarshal([]byte(s), &input.Variables)
+			if err != nil {
+				app.graphqlErrorResponse(w, r, http.StatusBadRequest, errCodeBadRequest, app.contextGetLocalizer(r).T("error.graphql_variables"))
+				return
+			}
+		}
+	} else {
+		if f, ok := requestFormat(r.Header.Get("Content-Type")); !ok || f != formatJSON {
+			app.graphqlErrorResponse(w, r, http.StatusUnsupportedMediaType, errCodeUnsupportedMediaType, app.contextGetLocalizer(r).T("error.graphql_json_only"))
+			return
+		}
+
+		err := decodeJSON(http.MaxBytesReader(w, r.Body, maxRequestBytes), &input, formatJSON.name)
+		if err != nil {
+			app.graphqlErrorResponse(w, r, http.StatusBadRequest, errCodeBadRequest, app.requestErrorMessage(r, err))
+			return
+		}
+	}
+
+	if strings.TrimSpace(input.Query) == "" {
+		app.graphqlErrorResponse(w, r, http.StatusBadRequest, errCodeBadRequest, app.contextGetLocalizer(r).T("error.graphql_query_required"))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(input.Query), Name: "GraphQL request"})})
+	if err != nil {
+		app.writeGraphQL(w, r, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	validation := graphql.ValidateDocument(&app.graphqlSchema, doc, nil)
+	if !validation.IsValid {
+		app.writeGraphQL(w, r, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	// an unknown or missing operation name is left for Execute to report
+	if op := findOperation(doc, input.OperationName); op != nil {
+		// a GET must not change anything, as it can be sent again by caches, crawlers and prefetching
+		if r.Method == http.MethodGet && op.Operation != ast.OperationTypeQuery {
+			w.Header().Set("Allow", http.MethodPost)
+			message := app.contextGetLocalizer(r).T("error.method_not_allowed", r.Method)
+			app.graphqlErrorResponse(w, r, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, message)
+			return
+		}
+
+		cost := queryCost{schema: &app.graphqlSchema, fragments: fragments(doc), variables: input.Variables}
+		root := app.graphqlSchema.QueryType()
+		if op.Operation == ast.OperationTypeMutation {
+			root = app.graphqlSchema.MutationType()
+		}
+
+		depth, complexity := cost.measure(op.SelectionSet, root, 0, 0)
+		l := app.contextGetLocalizer(r)
+		switch {
+		case depth > app.config.graphql.maxDepth:
+			message := l.T("error.graphql_too_deep", depth, app.config.graphql.maxDepth)
+			app.graphqlErrorResponse(w, r, http.StatusBadRequest, errCodeQueryTooDeep, message)
+			return
+		case complexity > app.config.graphql.maxComplexity:
+			message := l.T("error.graphql_too_complex", complexity, app.config.graphql.maxComplexity)
+			app.graphqlErrorResponse(w, r, http.StatusBadRequest, errCodeQueryTooComplex, message)
+			return
+		}
+	}
+
+	// the request is the root value, so that the resolvers can validate in the client's language and audit the changes
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        app.graphqlSchema,
+		Root:          r,
+		AST:           doc,
+		OperationName: input.OperationName,
+		Args:          input.Variables,
+		Context:       r.Context(),
+	})
+
+	for i := range result.Errors {
+		result.Errors[i] = app.graphqlError(r, result.Errors[i])
+	}
+
+	app.writeGraphQL(w, r, http.StatusOK, result)
+}
+
+// graphqlError gives an error returned by a resolver its code, and a message in the client's language
+// errors from the data layer are mapped the same way as in the rest of the API, anything unexpected is logged and
+// sent as a server error, so that nothing about the internals leaks out
+func (app *application) graphqlError(r *http.Request, formatted gqlerrors.FormattedError) gqlerrors.FormattedError {
+	located, ok := formatted.OriginalError().(*gqlerrors.Error)
+	if !ok || located.OriginalError == nil {
+		return formatted
+	}
+	err := located.OriginalError
+
+	l := app.contextGetLocalizer(r)
+	var validationErr failedValidationError
+	var duplicateErr *data.DuplicateMovieError
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		formatted.Message = l.T("error.not_found")
+		formatted.Extensions = map[string]any{"code": errCodeNotFound}
+	case errors.As(err, &validationErr):
+		formatted.Message = l.T("error.failed_validation")
+		formatted.Extensions = map[string]any{"code": errCodeFailedValidation, "invalid_params": invalidParams(validationErr.v)}
+	case errors.As(err, &duplicateErr):
+		location := fmt.Sprintf("/v1/movies/%d", duplicateErr.ID)
+		formatted.Message = l.T("error.duplicate_movie_external_id", duplicateErr.Field, location)
+		if duplicateErr.Field == "title" {
+			formatted.Message = l.T("error.duplicate_movie_title", location)
+		}
+		formatted.Extensions = map[string]any{"code": errCodeDuplicateMovie, "location": location}
+	default:
+		app.logError(r, err)
+		formatted.Message = l.T("error.server_error")
+		formatted.Extensions = map[string]any{"code": errCodeServerError}
+	}
+
+	return formatted
+}
+
+// graphqlErrorResponse sends a single GraphQL error, for requests that are turned away before they are run
+func (app *application) graphqlErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	formatted := gqlerrors.NewFormattedError(message)
+	formatted.Extensions = map[string]any{"code": code}
+
+	app.writeGraphQL(w, r, status, &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}})
+}
+
+// writeGraphQL writes a GraphQL response as JSON
+// data is left out when the request was not run, and errors when there were none, as the GraphQL spec asks
+func (app *application) writeGraphQL(w http.ResponseWriter, r *http.Request, status int, result *graphql.Result) {
+	env := envelope{}
+	if result.Data != nil || status == http.StatusOK {
+		env["data"] = result.Data
+	}
+	if len(result.Errors) > 0 {
+		env["errors"] = result.Errors
+	}
+
+	body, err := formatJSON.marshal(env, app.config.jsonCompact)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", formatJSON.contentType())
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// findOperation returns the operation with the given name, or the only one in the document when no name is given
+func findOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "":
+			if found != nil {
+				return nil
+			}
+			found = op
+		case op.Name != nil && op.Name.Value == name:
+			return op
+		}
+	}
+
+	return found
+}
+
+// fragments returns the fragments defined in the document by name
+func fragments(doc *ast.Document) map[string]*ast.FragmentDefinition {
+	defs := make(map[string]*ast.FragmentDefinition)
+
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			defs[frag.Name.Value] = frag
+		}
+	}
+
+	return defs
+}
+
+// queryCost works out how deep and how complex a query is before it is run, so that expensive ones can be turned away
+// every field costs 1, and the fields inside a list cost as many times over as the page size asked for by the field
+// holding the list, or graphqlDefaultListSize when there is none; introspection is not counted, so tools still work
+// the document must have passed validation, which rules out fragment cycles
+type queryCost struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// measure returns the depth and complexity of the selections made on the parent type
+// depth is how deep the parent is, and pageSize the page size asked for above it that no list has used up yet
+func (c queryCost) measure(set *ast.SelectionSet, parent graphql.Type, depth, pageSize int) (int, int) {
+	maxDepth, complexity := depth, 0
+	if set == nil {
+		return maxDepth, complexity
+	}
+
+	add := func(d, cost int) {
+		maxDepth = max(maxDepth, d)
+		complexity += cost
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+
+			var fieldType graphql.Type
+			if obj, ok := parent.(*graphql.Object); ok {
+				if field, ok := obj.Fields()[selection.Name.Value]; ok {
+					fieldType = field.Type
+				}
+			}
+
+			size := pageSize
+			if n, ok := c.pageSize(selection); ok {
+				size = n
+			}
+
+			// a list uses up the page size, the lists inside it are counted at the default size again
+			times := 1
+			if isListType(fieldType) {
+				times = cmp.Or(size, graphqlDefaultListSize)
+				size = 0
+			}
+
+			d, cost := c.measure(selection.SelectionSet, namedType(fieldType), depth+1, size)
+			add(d, 1+times*cost)
+		case *ast.InlineFragment:
+			add(c.measure(selection.SelectionSet, c.typeCondition(selection.TypeCondition, parent), depth, pageSize))
+		case *ast.FragmentSpread:
+			if frag, ok := c.fragments[selection.Name.Value]; ok {
+				add(c.measure(frag.SelectionSet, c.typeCondition(frag.TypeCondition, parent), depth, pageSize))
+			}
+		}
+	}
+
+	return maxDepth, complexity
+}
+
+// typeCondition returns the type a fragment applies to, or the parent type when it does not say
+func (c queryCost) typeCondition(named *ast.Named, parent graphql.Type) graphql.Type {
+	if named == nil {
+		return parent
+	}
+
+	if t := c.schema.Type(named.Name.Value); t != nil {
+		return t
+	}
+
+	return parent
+}
+
+// pageSize reads the pageSize from the field's page argument, whether it is given inline or in a variable
+func (c queryCost) pageSize(field *ast.Field) (int, bool) {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "page" {
+			continue
+		}
+
+		page, _ := c.value(arg.Value).(map[string]any)
+		switch n := page["pageSize"].(type) {
+		case int:
+			return n, true
+		case float64:
+			return int(n), true
+		}
+	}
+
+	return 0, false
+}
+
+// value returns the Go value of an argument, looking up variables, for the kinds of values pageSize needs
+func (c queryCost) value(v ast.Value) any {
+	switch v := v.(type) {
+	case *ast.Variable:
+		return c.variables[v.Name.Value]
+	case *ast.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		if err != nil {
+			return nil
+		}
+		return n
+	case *ast.ObjectValue:
+		obj := make(map[string]any, len(v.Fields))
+		for _, field := range v.Fields {
+			obj[field.Name.Value] = c.value(field.Value)
+		}
+		return obj
+	}
+
+	return nil
+}
+
+// isListType reports whether the type is a list, whether or not it is non-null
+func isListType(t graphql.Type) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+
+	_, ok := t.(*graphql.List)
+	return ok
+}
+
+// namedType returns the type inside any lists and non-nulls
+func namedType(t graphql.Type) graphql.Type {
+	for {
+		switch wrapper := t.(type) {
+		case *graphql.NonNull:
+			t = wrapper.OfType
+		case *graphql.List:
+			t = wrapper.OfType
+		default:
+			return t
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// newGraphQLApp returns an application with the GraphQL schema and the given limits
+func newGraphQLApp(t *testing.T, maxDepth, maxComplexity int) *application {
+	t.Helper()
+
+	app, _ := newTestApp(t)
+	app.config.graphql.maxDepth = maxDepth
+	app.config.graphql.maxComplexity = maxComplexity
+	app.graphqlSchema = app.mustBuildGraphQLSchema()
+
+	return app
+}
+
+func TestQueryCost(t *testing.T) {
+	app := newGraphQLApp(t, 10, 1000)
+
+	tests := []struct {
+		name       string
+		query      string
+		variables  map[string]any
+		depth      int
+		complexity int
+	}{
+		{
+			name:       "single movie",
+			query:      `{ movie(id: 1) { title year } }`,
+			depth:      2,
+			complexity: 3,
+		},
+		{
+			name:  "list at the default page size",
+			query: `{ movies { items { title } metadata { totalRecords } } }`,
+			depth: 3,
+			// movies 1, items 1 + 20 * title, metadata 1 + totalRecords
+			complexity: 1 + (1 + 20*1) + (1 + 1),
+		},
+		{
+			name:       "list with a page size",
+			query:      `{ movies(page: {pageSize: 5}) { items { title } } }`,
+			depth:      3,
+			complexity: 1 + (1 + 5*1),
+		},
+		{
+			name: "nested lists",
+			query: `{ movies(page: {pageSize: 5}) { items { title
+				reviews(page: {pageSize: 3}) { items { score } } } } }`,
+			depth:      5,
+			complexity: 1 + (1 + 5*(1+(1+(1+3*1)))),
+		},
+		{
+			name:  "nested list at the default page size",
+			query: `{ movies(page: {pageSize: 5}) { items { reviews { items { score } } } } }`,
+			depth: 5,
+			// the page size is used up by the outer list, so the inner one is counted at the default
+			complexity: 1 + (1 + 5*(1+(1+20*1))),
+		},
+		{
+			name:       "named fragment",
+			query:      `{ movies(page: {pageSize: 5}) { items { ...details } } } fragment details on Movie { title year }`,
+			depth:      3,
+			complexity: 1 + (1 + 5*2),
+		},
+		{
+			name:       "inline fragment",
+			query:      `{ movies(page: {pageSize: 5}) { items { ... on Movie { title genres } } } }`,
+			depth:      3,
+			complexity: 1 + (1 + 5*2),
+		},
+		{
+			name:       "fragment with a nested list",
+			query:      `{ movie(id: 1) { ...withReviews } } fragment withReviews on Movie { reviews(page: {pageSize: 2}) { items { score body } } }`,
+			depth:      4,
+			complexity: 1 + (1 + (1 + 2*2)),
+		},
+		{
+			name:      "page in a variable",
+			query:     `query ($page: PageInput) { movies(page: $page) { items { title } } }`,
+			variables: map[string]any{"page": map[string]any{"pageSize": float64(7)}},
+			// variables are decoded from JSON, so numbers are float64s
+			depth:      3,
+			complexity: 1 + (1 + 7*1),
+		},
+		{
+			name:       "page size in a variable",
+			query:      `query ($size: Int) { movies(page: {pageSize: $size}) { items { title } } }`,
+			variables:  map[string]any{"size": float64(4)},
+			depth:      3,
+			complexity: 1 + (1 + 4*1),
+		},
+		{
+			name:       "variable left out",
+			query:      `query ($page: PageInput) { movies(page: $page) { items { title } } }`,
+			depth:      3,
+			complexity: 1 + (1 + 20*1),
+		},
+		{
+			name:       "introspection",
+			query:      `{ __schema { types { name fields { name } } } }`,
+			depth:      0,
+			complexity: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			op := findOperation(doc, "")
+			cost := queryCost{schema: &app.graphqlSchema, fragments: fragments(doc), variables: tt.variables}
+
+			depth, complexity := cost.measure(op.SelectionSet, app.graphqlSchema.QueryType(), 0, 0)
+			if depth != tt.depth || complexity != tt.complexity {
+				t.Errorf("measure() = depth %d, complexity %d, want depth %d, complexity %d", depth, complexity, tt.depth, tt.complexity)
+			}
+		})
+	}
+}
+
+func TestGraphQLHandlerLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		query   string
+		status  int
+		code    string
+		message string
+	}{
+		{
+			name:    "too deep",
+			method:  http.MethodPost,
+			query:   `{ movies { items { reviews { items { score } } } } }`,
+			status:  http.StatusBadRequest,
+			code:    errCodeQueryTooDeep,
+			message: "The query is nested 5 levels deep, the most allowed is 4",
+		},
+		{
+			name:    "too complex",
+			method:  http.MethodPost,
+			query:   `{ movies(page: {pageSize: 100}) { items { id title year } } }`,
+			status:  http.StatusBadRequest,
+			code:    errCodeQueryTooComplex,
+			message: "The query has a complexity of 302, the most allowed is 100",
+		},
+		{
+			name:    "too complex with GET",
+			method:  http.MethodGet,
+			query:   `{ movies(page: {pageSize: 100}) { items { id title year } } }`,
+			status:  http.StatusBadRequest,
+			code:    errCodeQueryTooComplex,
+			message: "The query has a complexity of 302, the most allowed is 100",
+		},
+		{
+			name:    "mutation with GET",
+			method:  http.MethodGet,
+			query:   `mutation { deleteMovie(id: 1) }`,
+			status:  http.StatusMethodNotAllowed,
+			code:    errCodeMethodNotAllowed,
+			message: "The GET method is not supported for this resource",
+		},
+		{
+			name:    "missing query",
+			method:  http.MethodPost,
+			query:   "",
+			status:  http.StatusBadRequest,
+			code:    errCodeBadRequest,
+			message: "query must be provided",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newGraphQLApp(t, 4, 100)
+
+			var r *http.Request
+			if tt.method == http.MethodGet {
+				r = httptest.NewRequest(http.MethodGet, "/v1/graphql?query="+url.QueryEscape(tt.query), nil)
+			} else {
+				body, _ := json.Marshal(map[string]string{"query": tt.query})
+				r = httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+				r.Header.Set("Content-Type", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			app.graphqlHandler(rr, r)
+
+			if rr.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body: %s", rr.Code, tt.status, rr.Body)
+			}
+
+			var body struct {
+				Data   json.RawMessage `json:"data"`
+				Errors []struct {
+					Message    string         `json:"message"`
+					Extensions map[string]any `json:"extensions"`
+				} `json:"errors"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(body.Errors) != 1 || body.Errors[0].Extensions["code"] != tt.code || body.Errors[0].Message != tt.message {
+				t.Errorf("errors = %+v, want %s: %q", body.Errors, tt.code, tt.message)
+			}
+			// the request was not run, so there is no data
+			if body.Data != nil {
+				t.Errorf("data = %s, want none", body.Data)
+			}
+		})
+	}
+
+	// a mutation sent with GET is turned away before it reaches the database
+	app, db := newTestApp(t)
+	app.config.graphql.maxDepth, app.config.graphql.maxComplexity = 10, 1000
+	app.graphqlSchema = app.mustBuildGraphQLSchema()
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/graphql?query="+url.QueryEscape(`mutation { deleteMovie(id: 1) }`), nil)
+	rr := httptest.NewRecorder()
+	app.graphqlHandler(rr, r)
+
+	if got := rr.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow = %q, want POST", got)
+	}
+	if db.ran("DELETE") {
+		t.Error("the mutation was run")
+	}
+
+	// a query within the limits is run
+	r = httptest.NewRequest(http.MethodGet, "/v1/graphql?query="+url.QueryEscape(`{ __typename }`), nil)
+	rr = httptest.NewRecorder()
+	app.graphqlHandler(rr, r)
+
+	var result struct {
+		Data map[string]any `json:"data"`
+	}
+	err := json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil || rr.Code != http.StatusOK || result.Data["__typename"] != "Query" {
+		t.Errorf("status = %d, body: %s, want the query's result", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/graphql-go/graphql"
+)
+
+// mustBuildGraphQLSchema builds the schema served at /v1/graphql
+// the types mirror the JSON representations, with the field names in camelCase as is usual for GraphQL
+// a schema that does not build is a programming error, so it panics like mustMarshalOpenAPI
+func (app *application) mustBuildGraphQLSchema() graphql.Schema {
+	metadataType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Metadata",
+		Description: "The pagination details of a page of records, which are all 0 when there are no records",
+		Fields: graphql.Fields{
+			"currentPage":  {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataField(func(m data.Metadata) int { return m.CurrentPage })},
+			"pageSize":     {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataField(func(m data.Metadata) int { return m.PageSize })},
+			"firstPage":    {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataField(func(m data.Metadata) int { return m.FirstPage })},
+			"lastPage":     {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataField(func(m data.Metadata) int { return m.LastPage })},
+			"totalRecords": {Type: graphql.NewNonNull(graphql.Int), Resolve: metadataField(func(m data.Metadata) int { return m.TotalRecords })},
+		},
+	})
+
+	externalIDType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "ExternalId",
+		Description: "The ID of a movie in another database, e.g. IMDb",
+		Fields: graphql.Fields{
+			"source": {Type: graphql.NewNonNull(graphql.String)},
+			"id":     {Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	creditType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Credit",
+		Description: "A person credited on a movie in a particular role",
+		Fields: graphql.Fields{
+			"id":   {Type: graphql.NewNonNull(graphql.ID)},
+			"name": {Type: graphql.NewNonNull(graphql.String)},
+			"personId": {Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.Credit).PersonID, nil
+			}},
+			"role":      {Type: graphql.NewNonNull(graphql.String), Description: "One of director, actor or writer"},
+			"character": {Type: graphql.String},
+			"billingOrder": {Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.Credit).BillingOrder, nil
+			}},
+		},
+	})
+
+	reviewType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Review",
+		Description: "A single reviewer's score and write-up of a movie",
+		Fields: graphql.Fields{
+			"id":       {Type: graphql.NewNonNull(graphql.ID)},
+			"reviewer": {Type: graphql.NewNonNull(graphql.String)},
+			"score":    {Type: graphql.NewNonNull(graphql.Int), Description: "From 1 to 10"},
+			"body":     {Type: graphql.String},
+			"createdAt": {Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.Review).CreatedAt, nil
+			}},
+			"updatedAt": {Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.Review).UpdatedAt, nil
+			}},
+			"version": {Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	pageInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "PageInput",
+		Description: "Which page of records to return, and how to sort them, the same as the page, page_size and sort query parameters",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"page":     {Type: graphql.Int, DefaultValue: 1},
+			"pageSize": {Type: graphql.Int, DefaultValue: 20},
+			"sort":     {Type: graphql.String, Description: "A field to sort by, with a - prefix for descending order"},
+		},
+	})
+
+	reviewPageType := newGraphQLPage("ReviewPage", reviewType, metadataType)
+
+	movieType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Movie",
+		Description: "A movie in the catalogue",
+		Fields: graphql.Fields{
+			"id":     {Type: graphql.NewNonNull(graphql.ID)},
+			"title":  {Type: graphql.NewNonNull(graphql.String)},
+			"year":   {Type: graphql.NewNonNull(graphql.Int)},
+			"genres": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"runtime": {Type: graphql.NewNonNull(graphql.Int), Description: "The runtime in minutes", Resolve: func(p graphql.ResolveParams) (any, error) {
+				return int(p.Source.(*data.Movie).Runtime), nil
+			}},
+			"externalIds": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(externalIDType))), Resolve: func(p graphql.ResolveParams) (any, error) {
+				ids := []map[string]any{}
+				for _, source := range sortedKeys(p.Source.(*data.Movie).ExternalIDs) {
+					ids = append(ids, map[string]any{"source": source, "id": p.Source.(*data.Movie).ExternalIDs[source]})
+				}
+				return ids, nil
+			}},
+			"averageRating": {Type: graphql.NewNonNull(graphql.Float), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.Movie).AverageRating, nil
+			}},
+			"ratingCount": {Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*data.Movie).RatingCount, nil
+			}},
+			"version": {Type: graphql.NewNonNull(graphql.Int)},
+			"credits": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(creditType))), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return app.models.People.GetCreditsForMovie(p.Source.(*data.Movie).ID)
+			}},
+			"reviews": {
+				Type: graphql.NewNonNull(reviewPageType),
+				Args: graphql.FieldConfigArgument{"page": {Type: pageInput}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					filters := graphqlFilters(p.Args, "-created_at", reviewSortSafelist)
+
+					v := app.newValidator(graphqlRequestOf(p))
+					if data.ValidateFilters(v, filters); !v.Valid() {
+						return nil, failedValidationError{v}
+					}
+
+					reviews, metadata, err := app.models.Reviews.GetAllForMovie(p.Source.(*data.Movie).ID, filters)
+					if err != nil {
+						return nil, err
+					}
+
+					return map[string]any{"items": reviews, "metadata": metadata}, nil
+				},
+			},
+		},
+	})
+
+	moviePageType := newGraphQLPage("MoviePage", movieType, metadataType)
+
+	movieFilterInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "MovieFilter",
+		Description: "Which movies to return, the same as the title and genres query parameters",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":  {Type: graphql.String, Description: "Words that must all appear in the title"},
+			"genres": {Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Genres that the movies must all have"},
+		},
+	})
+
+	externalIDInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ExternalIdInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"source": {Type: graphql.NewNonNull(graphql.String), Description: "The database the ID is from, e.g. imdb"},
+			"id":     {Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	movieInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "MovieInput",
+		Description: "A new movie, checked with the same rules as POST /v1/movies",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       {Type: graphql.NewNonNull(graphql.String)},
+			"year":        {Type: graphql.NewNonNull(graphql.Int)},
+			"runtime":     {Type: graphql.NewNonNull(graphql.Int), Description: "The runtime in minutes"},
+			"genres":      {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"externalIds": {Type: graphql.NewList(graphql.NewNonNull(externalIDInput))},
+		},
+	})
+
+	movieUpdateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "MovieUpdateInput",
+		Description: "Changes to a movie, the fields left out keep their current values",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       {Type: graphql.String},
+			"year":        {Type: graphql.Int},
+			"runtime":     {Type: graphql.Int, Description: "The runtime in minutes"},
+			"genres":      {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"externalIds": {Type: graphql.NewList(graphql.NewNonNull(externalIDInput))},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"movie": {
+				Type: movieType,
+				Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := graphqlID(p.Args["id"])
+					if err != nil {
+						return nil, err
+					}
+
+					return app.models.Movies.Get(id)
+				},
+			},
+			"movies": {
+				Type: graphql.NewNonNull(moviePageType),
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: movieFilterInput},
+					"page":   {Type: pageInput},
+				},
+				Resolve: app.resolveMovies,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createMovie": {
+				Type:    graphql.NewNonNull(movieType),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(movieInput)}},
+				Resolve: app.resolveCreateMovie,
+			},
+			"updateMovie": {
+				Type: graphql.NewNonNull(movieType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(movieUpdateInput)},
+				},
+				Resolve: app.resolveUpdateMovie,
+			},
+			"deleteMovie": {
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Deletes a movie and its poster, and returns its ID",
+				Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     app.resolveDeleteMovie,
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil {
+		panic(err)
+	}
+
+	return schema
+}
+
+// resolveMovies returns a page of movies, like GET /v1/movies
+func (app *application) resolveMovies(p graphql.ResolveParams) (any, error) {
+	filter, _ := p.Args["filter"].(map[string]any)
+	title, _ := filter["title"].(string)
+	genres := graphqlStrings(filter["genres"])
+	filters := graphqlFilters(p.Args, "id", movieSortSafelist)
+
+	v := app.newValidator(graphqlRequestOf(p))
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, failedValidationError{v}
+	}
+
+	vocab, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		return nil, err
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(title, vocab.Canonical(genres), filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"items": movies, "metadata": metadata}, nil
+}
+
+// resolveCreateMovie adds a movie, like POST /v1/movies
+func (app *application) resolveCreateMovie(p graphql.ResolveParams) (any, error) {
+	movie := &data.Movie{}
+	setMovieFields(movie, p.Args["input"].(map[string]any))
+	if movie.ExternalIDs == nil {
+		movie.ExternalIDs = data.ExternalIDs{}
+	}
+
+	err := app.saveGraphQLMovie(graphqlRequestOf(p), movie, app.models.Movies.WithAudit(app.auditInfo(graphqlRequestOf(p))).Insert)
+	if err != nil {
+		return nil, err
+	}
+
+	return movie, nil
+}
+
+// resolveUpdateMovie changes the fields of a movie that are given, unlike PUT /v1/movies/:id which replaces them all
+func (app *application) resolveUpdateMovie(p graphql.ResolveParams) (any, error) {
+	id, err := graphqlID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	setMovieFields(movie, p.Args["input"].(map[string]any))
+
+	err = app.saveGraphQLMovie(graphqlRequestOf(p), movie, app.models.Movies.WithAudit(app.auditInfo(graphqlRequestOf(p))).Update)
+	if err != nil {
+		return nil, err
+	}
+
+	return movie, nil
+}
+
+// resolveDeleteMovie deletes a movie and its poster, like DELETE /v1/movies/:id
+func (app *application) resolveDeleteMovie(p graphql.ResolveParams) (any, error) {
+	r := graphqlRequestOf(p)
+
+	id, err := graphqlID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	poster, err := app.models.Posters.Get(id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	err = app.models.Movies.WithAudit(app.auditInfo(r)).Delete(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if poster != nil {
+		app.deletePosterBlobs(r, poster)
+	}
+
+	return id, nil
+}
+
+// saveGraphQLMovie validates a movie against the genre vocabulary, puts its genres under their display names and saves it
+func (app *application) saveGraphQLMovie(r *http.Request, movie *data.Movie, save func(*data.Movie) error) error {
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		return err
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		return failedValidationError{v}
+	}
+
+	movie.Genres = genres.Canonical(movie.Genres)
+
+	return save(movie)
+}
+
+// setMovieFields copies the fields given in a MovieInput or MovieUpdateInput onto the movie
+func setMovieFields(movie *data.Movie, input map[string]any) {
+	if title, ok := input["title"].(string); ok {
+		movie.Title = title
+	}
+	if year, ok := input["year"].(int); ok {
+		movie.Year = int32(year)
+	}
+	if runtime, ok := input["runtime"].(int); ok {
+		movie.Runtime = data.Runtime(runtime)
+	}
+	if genres, ok := input["genres"]; ok && genres != nil {
+		movie.Genres = graphqlStrings(genres)
+	}
+	if ids, ok := input["externalIds"].([]any); ok {
+		movie.ExternalIDs = data.ExternalIDs{}
+		for _, id := range ids {
+			id := id.(map[string]any)
+			movie.ExternalIDs[id["source"].(string)] = id["id"].(string)
+		}
+	}
+}
+
+// newGraphQLPage returns the type of a page of items along with its pagination details
+func newGraphQLPage(name string, item *graphql.Object, metadata *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: name,
+		Fields: graphql.Fields{
+			"items":    {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(item)))},
+			"metadata": {Type: graphql.NewNonNull(metadata)},
+		},
+	})
+}
+
+// metadataField resolves a field of the pagination details
+func metadataField(get func(data.Metadata) int) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(data.Metadata)), nil
+	}
+}
+
+// graphqlRequestOf returns the request being resolved, which is passed in as the root value
+func graphqlRequestOf(p graphql.ResolveParams) *http.Request {
+	return p.Info.RootValue.(*http.Request)
+}
+
+// graphqlFilters reads the page argument into Filters, the sort defaults to the one given
+func graphqlFilters(args map[string]any, sort string, safelist []string) data.Filters {
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: sort, SortSafelist: safelist}
+
+	page, _ := args["page"].(map[string]any)
+	if n, ok := page["page"].(int); ok {
+		filters.Page = n
+	}
+	if n, ok := page["pageSize"].(int); ok {
+		filters.PageSize = n
+	}
+	if s, ok := page["sort"].(string); ok {
+		filters.Sort = s
+	}
+
+	return filters
+}
+
+// graphqlID converts an ID argument to a record ID, an ID that is not a positive integer cannot be found
+func graphqlID(arg any) (int64, error) {
+	s, _ := arg.(string)
+
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id < 1 {
+		return 0, data.ErrRecordNotFound
+	}
+
+	return id, nil
+}
+
+// graphqlStrings converts a list argument of strings, which graphql-go passes as a []any
+func graphqlStrings(arg any) []string {
+	list, _ := arg.([]any)
+
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+
+	return strs
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/i18n"
+	"github.com/TaskMasterErnest/greenlight/pkg/greenlightpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// newGRPCServer returns the gRPC server for -grpc-port, with the movie service and reflection for tools like grpcurl
+// every call goes through the same steps as an HTTP request: it is given a request ID and a locale, logged,
+// and a panic in it is recovered and sent back as an internal error
+func (app *application) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(app.grpcUnaryContext, app.grpcUnaryLogging, app.grpcUnaryRecoverPanic),
+		grpc.ChainStreamInterceptor(app.grpcStreamContext, app.grpcStreamLogging, app.grpcStreamRecoverPanic),
+	)
+
+	greenlightpb.RegisterMovieServiceServer(server, &movieService{app: app})
+	reflection.Register(server)
+
+	return server
+}
+
+// listenGRPC opens the listener for -grpc-port
+// it is opened before the HTTP server starts, so that a port that cannot be used stops serve like it does for HTTP
+func (app *application) listenGRPC() (net.Listener, error) {
+	return net.Listen("tcp", fmt.Sprintf(":%d", app.config.grpcPort))
+}
+
+// serveGRPC serves gRPC on the listener until the server is stopped
+func (app *application) serveGRPC(server *grpc.Server, listener net.Listener) error {
+	app.logger.Info("starting gRPC server", "addr", listener.Addr().String())
+
+	return server.Serve(listener)
+}
+
+// stopGRPC lets the calls in flight finish, and cuts off any that are still going when the context is done
+// the movie streams are ended first, as they would otherwise keep going until they are cut off
+func (app *application) stopGRPC(ctx context.Context, server *grpc.Server) {
+	app.movieChanges.close()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+
+	app.logger.Info("stopped gRPC server")
+}
+
+// grpcContext gives a call its request ID and locale, in the same way as the requestID and localize middleware
+// the request ID is sent back in the x-request-id header
+func (app *application) grpcContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := firstMetadata(md, "x-request-id")
+	if !requestIDRX.MatchString(id) {
+		b := make([]byte, 16)
+		// crypto/rand.Read never returns an error
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+
+	ctx = context.WithValue(ctx, requestIDContextKey, id)
+	return context.WithValue(ctx, localizerContextKey, i18n.Negotiate(firstMetadata(md, "accept-language")))
+}
+
+func (app *application) grpcUnaryContext(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(app.grpcContext(ctx), req)
+}
+
+func (app *application) grpcStreamContext(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: app.grpcContext(ss.Context())})
+}
+
+// grpcLog logs a finished call, calls that failed with an internal error are logged as errors along with the cause,
+// which the client does not get to see
+func (app *application) grpcLog(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []any{"method", method, "code", code.String(), "duration", time.Since(start).String(), "request_id", grpcRequestID(ctx)}
+
+	if code == codes.Internal || code == codes.Unknown {
+		app.logger.Error(err.Error(), attrs...)
+		return
+	}
+
+	app.logger.Info("grpc call", attrs...)
+}
+
+func (app *application) grpcUnaryLogging(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	app.grpcLog(ctx, info.FullMethod, start, err)
+	return res, err
+}
+
+func (app *application) grpcStreamLogging(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	app.grpcLog(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+// grpcRecover turns a panic into an internal error, which the logging interceptor then logs
+func (app *application) grpcRecover(ctx context.Context, err *error) {
+	if p := recover(); p != nil {
+		*err = app.grpcServerError(ctx, fmt.Errorf("%s", p))
+	}
+}
+
+func (app *application) grpcUnaryRecoverPanic(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	defer app.grpcRecover(ctx, &err)
+	return handler(ctx, req)
+}
+
+func (app *application) grpcStreamRecoverPanic(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer app.grpcRecover(ss.Context(), &err)
+	return handler(srv, ss)
+}
+
+// a contextStream is a ServerStream with the context replaced, so that stream interceptors can add to it
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcError maps an error from the data layer to a status, in the same way as the HTTP error responses
+// anything unexpected becomes an internal error, which keeps the cause for the log but only sends the client the message
+func (app *application) grpcError(ctx context.Context, err error) error {
+	l := grpcLocalizer(ctx)
+	var validationErr failedValidationError
+	var duplicateErr *data.DuplicateMovieError
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return status.Error(codes.NotFound, l.T("error.not_found"))
+	case errors.As(err, &validationErr):
+		violations := []*errdetails.BadRequest_FieldViolation{}
+		for _, param := range invalidParams(validationErr.v) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       grpcFieldName(param.Name),
+				Description: param.Reason,
+				Reason:      param.Code,
+			})
+		}
+		return grpcStatusWithDetails(codes.InvalidArgument, l.T("error.failed_validation"), &errdetails.BadRequest{FieldViolations: violations})
+	case errors.As(err, &duplicateErr):
+		location := fmt.Sprintf("/v1/movies/%d", duplicateErr.ID)
+		message := l.T("error.duplicate_movie_external_id", duplicateErr.Field, location)
+		if duplicateErr.Field == "title" {
+			message = l.T("error.duplicate_movie_title", location)
+		}
+		return grpcStatusWithDetails(codes.AlreadyExists, message, &errdetails.ResourceInfo{ResourceType: "movie", ResourceName: location})
+	default:
+		return app.grpcServerError(ctx, err)
+	}
+}
+
+// an internalError is sent to the client as its status, an internal error with a generic message,
+// while the cause is kept for the logging interceptor
+type internalError struct {
+	status *status.Status
+	cause  error
+}
+
+func (e internalError) Error() string {
+	return e.cause.Error()
+}
+
+func (e internalError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// grpcServerError wraps an unexpected error as an internal error, in the client's language
+func (app *application) grpcServerError(ctx context.Context, err error) error {
+	return internalError{status: status.New(codes.Internal, grpcLocalizer(ctx).T("error.server_error")), cause: err}
+}
+
+// grpcStatusWithDetails returns a status error with the details attached
+// the details are only left out if they cannot be encoded, which would be a programming error
+func grpcStatusWithDetails(code codes.Code, message string, details ...protoadapt.MessageV1) error {
+	st := status.New(code, message)
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// grpcFieldName converts a field path from the validator into the name of the request field, e.g. runtime is
+// runtime_minutes in the protobuf messages
+func grpcFieldName(name string) string {
+	first, rest, found := strings.Cut(name, "/")
+	if first == "runtime" {
+		first = "runtime_minutes"
+	}
+
+	if !found {
+		return first
+	}
+
+	return first + "/" + rest
+}
+
+// grpcRequestID returns the call's request ID, set by grpcContext
+func grpcRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// grpcLocalizer returns the call's Localizer, set by grpcContext, or the default locale
+func grpcLocalizer(ctx context.Context) *i18n.Localizer {
+	l, ok := ctx.Value(localizerContextKey).(*i18n.Localizer)
+	if !ok {
+		return i18n.New(i18n.DefaultLocale)
+	}
+
+	return l
+}
+
+// firstMetadata returns the first value of a metadata key, or an empty string
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// grpcAuditInfo says who is making the call, for the audit log, like auditInfo does for HTTP requests
+func (app *application) grpcAuditInfo(ctx context.Context) data.AuditInfo {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	actor := firstMetadata(md, app.config.actorHeader)
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	return data.AuditInfo{Actor: actor, ClientIP: ip, RequestID: grpcRequestID(ctx)}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/pkg/greenlightpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// syncBuffer is a bytes.Buffer that the server's goroutines can log to while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newGRPCClient serves the app's gRPC server over an in-memory connection and returns a client for it
+func newGRPCClient(t *testing.T, app *application) greenlightpb.MovieServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := app.newGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return greenlightpb.NewMovieServiceClient(conn)
+}
+
+func TestGRPCErrors(t *testing.T) {
+	noMovie := fakeResult{match: "FROM movies", columns: []string{"id"}}
+
+	tests := []struct {
+		name    string
+		results []fakeResult
+		call    func(context.Context, greenlightpb.MovieServiceClient) error
+		lang    string
+		code    codes.Code
+		message string
+	}{
+		{
+			name: "not found without a query",
+			call: func(ctx context.Context, c greenlightpb.MovieServiceClient) error {
+				_, err := c.Get(ctx, &greenlightpb.GetMovieRequest{Id: 0})
+				return err
+			},
+			code:    codes.NotFound,
+			message: "The requested resource could not be found",
+		},
+		{
+			name:    "not found",
+			results: []fakeResult{noMovie},
+			call: func(ctx context.Context, c greenlightpb.MovieServiceClient) error {
+				_, err := c.Get(ctx, &greenlightpb.GetMovieRequest{Id: 99})
+				return err
+			},
+			code:    codes.NotFound,
+			message: "The requested resource could not be found",
+		},
+		{
+			name:    "not found in French",
+			results: []fakeResult{noMovie},
+			call: func(ctx context.Context, c greenlightpb.MovieServiceClient) error {
+				_, err := c.Get(ctx, &greenlightpb.GetMovieRequest{Id: 99})
+				return err
+			},
+			lang:    "fr-CA, en;q=0.5",
+			code:    codes.NotFound,
+			message: "La ressource demandée est introuvable",
+		},
+		{
+			name:    "database error",
+			results: []fakeResult{{match: "FROM movies", err: errors.New("connection reset by peer")}},
+			call: func(ctx context.Context, c greenlightpb.MovieServiceClient) error {
+				_, err := c.Get(ctx, &greenlightpb.GetMovieRequest{Id: 1})
+				return err
+			},
+			// the cause is logged but not sent to the client
+			code:    codes.Internal,
+			message: "The server encountered a problem and could not process your request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp(t, tt.results...)
+			logs := &syncBuffer{}
+			app.logger = slog.New(slog.NewTextHandler(logs, nil))
+			client := newGRPCClient(t, app)
+
+			ctx := context.Background()
+			if tt.lang != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "accept-language", tt.lang)
+			}
+
+			err := tt.call(ctx, client)
+
+			st := status.Convert(err)
+			if st.Code() != tt.code || st.Message() != tt.message {
+				t.Errorf("status = %s: %q, want %s: %q", st.Code(), st.Message(), tt.code, tt.message)
+			}
+
+			if tt.code == codes.Internal && !strings.Contains(logs.String(), "connection reset by peer") {
+				t.Errorf("the cause was not logged:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestGRPCValidationError(t *testing.T) {
+	app, db := newTestApp(t)
+	client := newGRPCClient(t, app)
+
+	_, err := client.Update(context.Background(), &greenlightpb.UpdateMovieRequest{
+		Movie:      &greenlightpb.Movie{Id: 1, Title: "Casablanca"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title", "director"}},
+	})
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || st.Message() != "One or more fields failed validation" {
+		t.Fatalf("status = %s: %q, want InvalidArgument", st.Code(), st.Message())
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			violations = br.GetFieldViolations()
+		}
+	}
+	if len(violations) != 1 || violations[0].GetField() != "update_mask" || violations[0].GetReason() != "one_of" {
+		t.Errorf("violations = %v, want one for update_mask", violations)
+	}
+
+	if db.ran("movies") {
+		t.Error("the movie was looked up before the update mask was checked")
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	app, _ := newTestApp(t)
+	ctx := context.Background()
+
+	// runtime is called runtime_minutes in the messages
+	v := grpcValidator(ctx)
+	v.AddError("runtime", "validation.required")
+	v.AddError("external_ids/imdb", "validation.required")
+
+	st := status.Convert(app.grpcError(ctx, failedValidationError{v}))
+	var fields []string
+	for _, detail := range st.Details() {
+		for _, violation := range detail.(*errdetails.BadRequest).GetFieldViolations() {
+			fields = append(fields, violation.GetField())
+		}
+	}
+	if strings.Join(fields, " ") != "external_ids/imdb runtime_minutes" {
+		t.Errorf("fields = %v, want external_ids/imdb and runtime_minutes", fields)
+	}
+
+	// a duplicate names the movie it clashes with
+	st = status.Convert(app.grpcError(ctx, &data.DuplicateMovieError{ID: 7, Field: "imdb"}))
+	if st.Code() != codes.AlreadyExists || !strings.Contains(st.Message(), "/v1/movies/7") {
+		t.Errorf("status = %s: %q, want AlreadyExists naming /v1/movies/7", st.Code(), st.Message())
+	}
+	if len(st.Details()) != 1 || st.Details()[0].(*errdetails.ResourceInfo).GetResourceName() != "/v1/movies/7" {
+		t.Errorf("details = %v, want the movie's location", st.Details())
+	}
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	// models without a database make the lookup panic, which must come back as an internal error
+	app, _ := newTestApp(t)
+	app.models = data.NewModels(nil)
+	logs := &syncBuffer{}
+	app.logger = slog.New(slog.NewTextHandler(logs, nil))
+	client := newGRPCClient(t, app)
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "abc-123")
+	_, err := client.Get(ctx, &greenlightpb.GetMovieRequest{Id: 1}, grpc.Header(&header))
+
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "The server encountered a problem and could not process your request" {
+		t.Errorf("status = %s: %q, want Internal", st.Code(), st.Message())
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "abc-123" {
+		t.Errorf("x-request-id = %v, want the client's ID sent back", got)
+	}
+
+	// the panic is logged as an error along with the method and request ID
+	for _, want := range []string{"level=ERROR", "nil pointer", "method=/greenlight.v1.MovieService/Get", "code=Internal", "request_id=abc-123"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log does not contain %q:\n%s", want, logs)
+		}
+	}
+
+	// a request ID that is not safe to log is replaced
+	app, _ = newTestApp(t, fakeResult{match: "FROM movies", columns: []string{"id"}})
+	client = newGRPCClient(t, app)
+
+	header = nil
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "bad id")
+	client.Get(ctx, &greenlightpb.GetMovieRequest{Id: 1}, grpc.Header(&header))
+
+	if got := header.Get("x-request-id"); len(got) != 1 || len(got[0]) != 32 {
+		t.Errorf("x-request-id = %v, want a new 32 character ID", got)
+	}
+}
+
+func TestServeGRPCListenError(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	app, _ := newTestApp(t)
+	app.config.grpcPort = busy.Addr().(*net.TCPAddr).Port
+	app.config.port = -1
+
+	// serve returns before it starts the HTTP server, as the gRPC port is taken
+	err = app.serve()
+	if err == nil || !strings.Contains(err.Error(), "address already in use") {
+		t.Errorf("serve() error = %v, want the gRPC listen error", err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"github.com/TaskMasterErnest/greenlight/pkg/greenlightpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// the movie event types in the change feed, as they are sent by Watch
+var movieEventTypes = map[string]greenlightpb.MovieEvent_Type{
+	data.EventMovieCreated: greenlightpb.MovieEvent_TYPE_CREATED,
+	data.EventMovieUpdated: greenlightpb.MovieEvent_TYPE_UPDATED,
+	data.EventMovieDeleted: greenlightpb.MovieEvent_TYPE_DELETED,
+}
+
+// movieService implements the MovieService in proto/greenlight/v1/movies.proto, on the same models and checks as
+// the REST movie handlers
+type movieService struct {
+	greenlightpb.UnimplementedMovieServiceServer
+	app *application
+}
+
+// Get returns a movie by its ID
+func (s *movieService) Get(ctx context.Context, req *greenlightpb.GetMovieRequest) (*greenlightpb.Movie, error) {
+	movie, err := s.app.models.Movies.Get(req.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	return movieToProto(movie), nil
+}
+
+// List returns a page of movies, the defaults are the same as for GET /v1/movies
+func (s *movieService) List(ctx context.Context, req *greenlightpb.ListMoviesRequest) (*greenlightpb.ListMoviesResponse, error) {
+	filters := data.Filters{
+		Page:         cmp.Or(int(req.GetPage()), 1),
+		PageSize:     cmp.Or(int(req.GetPageSize()), 20),
+		Sort:         cmp.Or(req.GetSort(), "id"),
+		SortSafelist: movieSortSafelist,
+	}
+
+	v := grpcValidator(ctx)
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, s.app.grpcError(ctx, failedValidationError{v})
+	}
+
+	genres, err := s.app.models.Genres.Vocabulary()
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	movies, metadata, err := s.app.models.Movies.GetAll(req.GetTitle(), genres.Canonical(req.GetGenres()), filters)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	res := &greenlightpb.ListMoviesResponse{
+		Movies: make([]*greenlightpb.Movie, 0, len(movies)),
+		Metadata: &greenlightpb.Metadata{
+			CurrentPage:  int32(metadata.CurrentPage),
+			PageSize:     int32(metadata.PageSize),
+			FirstPage:    int32(metadata.FirstPage),
+			LastPage:     int32(metadata.LastPage),
+			TotalRecords: int32(metadata.TotalRecords),
+		},
+	}
+	for _, movie := range movies {
+		res.Movies = append(res.Movies, movieToProto(movie))
+	}
+
+	return res, nil
+}
+
+// Create adds a movie
+func (s *movieService) Create(ctx context.Context, req *greenlightpb.CreateMovieRequest) (*greenlightpb.Movie, error) {
+	movie := &data.Movie{
+		Title:       req.GetTitle(),
+		Year:        req.GetYear(),
+		Runtime:     data.Runtime(req.GetRuntimeMinutes()),
+		Genres:      req.GetGenres(),
+		ExternalIDs: data.ExternalIDs(req.GetExternalIds()),
+	}
+	if movie.ExternalIDs == nil {
+		movie.ExternalIDs = data.ExternalIDs{}
+	}
+
+	err := s.save(ctx, movie, s.app.models.Movies.WithAudit(s.app.grpcAuditInfo(ctx)).Insert)
+	if err != nil {
+		return nil, err
+	}
+
+	return movieToProto(movie), nil
+}
+
+// Update changes the fields of a movie named in the update mask, or replaces the movie like PUT does without one
+func (s *movieService) Update(ctx context.Context, req *greenlightpb.UpdateMovieRequest) (*greenlightpb.Movie, error) {
+	input := req.GetMovie()
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = []string{"title", "year", "runtime_minutes", "genres"}
+		if len(input.GetExternalIds()) > 0 {
+			paths = append(paths, "external_ids")
+		}
+	}
+
+	// unknown paths are rejected up front, before the movie is looked up
+	v := grpcValidator(ctx)
+	for _, path := range paths {
+		v.Check(validator.PermittedValues(path, "title", "year", "runtime_minutes", "genres", "external_ids"), "update_mask", "validation.one_of",
+			"title, year, runtime_minutes, genres, external_ids")
+	}
+	if !v.Valid() {
+		return nil, s.app.grpcError(ctx, failedValidationError{v})
+	}
+
+	movie, err := s.app.models.Movies.Get(input.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "title":
+			movie.Title = input.GetTitle()
+		case "year":
+			movie.Year = input.GetYear()
+		case "runtime_minutes":
+			movie.Runtime = data.Runtime(input.GetRuntimeMinutes())
+		case "genres":
+			movie.Genres = input.GetGenres()
+		case "external_ids":
+			movie.ExternalIDs = data.ExternalIDs(input.GetExternalIds())
+			if movie.ExternalIDs == nil {
+				movie.ExternalIDs = data.ExternalIDs{}
+			}
+		}
+	}
+
+	err = s.save(ctx, movie, s.app.models.Movies.WithAudit(s.app.grpcAuditInfo(ctx)).Update)
+	if err != nil {
+		return nil, err
+	}
+
+	return movieToProto(movie), nil
+}
+
+// Delete removes a movie along with its poster, the poster blobs are removed once the movie is gone
+func (s *movieService) Delete(ctx context.Context, req *greenlightpb.DeleteMovieRequest) (*greenlightpb.DeleteMovieResponse, error) {
+	poster, err := s.app.models.Posters.Get(req.GetId())
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	err = s.app.models.Movies.WithAudit(s.app.grpcAuditInfo(ctx)).Delete(req.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	if poster != nil {
+		for _, key := range poster.Keys() {
+			err := s.app.blobs.Delete(ctx, key)
+			if err != nil {
+				s.app.logger.Error(fmt.Sprintf("deleting poster blob %s: %s", key, err), "request_id", grpcRequestID(ctx))
+			}
+		}
+	}
+
+	return &greenlightpb.DeleteMovieResponse{}, nil
+}
+
+// Watch sends the movie changes as they happen, in the same way as GET /v1/movies/stream
+// the stream ends when the client goes away or the server shuts down
+func (s *movieService) Watch(req *greenlightpb.WatchMoviesRequest, stream grpc.ServerStreamingServer[greenlightpb.MovieEvent]) error {
+	ctx := stream.Context()
+
+	v := grpcValidator(ctx)
+	v.Check(req.AfterId == nil || req.GetAfterId() >= 0, "after_id", "validation.min_value", "0")
+	if !v.Valid() {
+		return s.app.grpcError(ctx, failedValidationError{v})
+	}
+
+	wanted := make(map[string]bool)
+	for _, genre := range req.GetGenres() {
+		wanted[data.Slugify(genre)] = true
+	}
+
+	// subscribe before reading where the feed is up to, so that no change falls in between
+	changes, unsubscribe := s.app.movieChanges.subscribe()
+	defer unsubscribe()
+
+	after := req.GetAfterId()
+	if req.AfterId == nil {
+		var err error
+		after, err = s.app.models.Events.LastID()
+		if err != nil {
+			return s.app.grpcError(ctx, err)
+		}
+	}
+
+	for {
+		var err error
+		after, err = s.sendNewEvents(stream, after, wanted)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.app.movieChanges.done:
+			return nil
+		case <-changes:
+		}
+	}
+}
+
+// sendNewEvents sends the events after the cursor that match the genres, and returns the new cursor
+func (s *movieService) sendNewEvents(stream grpc.ServerStreamingServer[greenlightpb.MovieEvent], after int64, genres map[string]bool) (int64, error) {
+	const batch = 100
+
+	for {
+		events, err := s.app.models.Events.GetAfter(after, batch)
+		if err != nil {
+			return after, s.app.grpcError(stream.Context(), err)
+		}
+
+		for _, event := range events {
+			after = event.ID
+
+			if !eventHasGenres(event, genres) {
+				continue
+			}
+
+			var movie data.Movie
+			err := json.Unmarshal(event.Data, &movie)
+			if err != nil {
+				return after, s.app.grpcError(stream.Context(), err)
+			}
+
+			err = stream.Send(&greenlightpb.MovieEvent{
+				Id:         event.ID,
+				OccurredAt: timestamppb.New(event.OccurredAt),
+				Type:       movieEventTypes[event.Type],
+				Movie:      movieToProto(&movie),
+			})
+			if err != nil {
+				return after, err
+			}
+		}
+
+		if len(events) < batch {
+			return after, nil
+		}
+	}
+}
+
+// save validates a movie against the genre vocabulary, puts its genres under their display names and saves it
+func (s *movieService) save(ctx context.Context, movie *data.Movie, save func(*data.Movie) error) error {
+	genres, err := s.app.models.Genres.Vocabulary()
+	if err != nil {
+		return s.app.grpcError(ctx, err)
+	}
+
+	v := grpcValidator(ctx)
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		return s.app.grpcError(ctx, failedValidationError{v})
+	}
+
+	movie.Genres = genres.Canonical(movie.Genres)
+
+	err = save(movie)
+	if err != nil {
+		return s.app.grpcError(ctx, err)
+	}
+
+	return nil
+}
+
+// movieToProto converts a movie to its protobuf message
+func movieToProto(movie *data.Movie) *greenlightpb.Movie {
+	return &greenlightpb.Movie{
+		Id:             movie.ID,
+		Title:          movie.Title,
+		Year:           movie.Year,
+		RuntimeMinutes: int32(movie.Runtime),
+		Genres:         movie.Genres,
+		ExternalIds:    movie.ExternalIDs,
+		AverageRating:  movie.AverageRating,
+		RatingCount:    movie.RatingCount,
+		Version:        movie.Version,
+	}
+}
+
+// grpcValidator returns a Validator which writes its messages in the call's locale
+func grpcValidator(ctx context.Context) *validator.Validator {
+	return validator.NewWithLocalizer(grpcLocalizer(ctx))
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/blob"
 )
 
 func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -14,11 +19,104 @@ func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Reques
 		},
 	}
 
-	// call the writeJSON helper method to convert data to JSON
-	err := app.writeJSON(w, http.StatusOK, env, nil)
+	// call the writeResponse helper method to encode the data in the negotiated format
+	err := app.writeResponse(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// livenessHandler reports that the process is up and able to serve requests
+// it does not look at any dependencies, a database outage should not get the process restarted
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	env := envelope{
+		"status":      "alive",
+		"environment": app.config.env,
+		"build":       buildInfo(),
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// a dependencyStatus is the result of checking one dependency for the readiness probe
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// readinessHandler reports whether the server should be sent traffic
+// every dependency is checked, each with its own timeout, and any that is down makes the whole response a 503
+// the response is also a 503 while the server is draining before shutdown
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(ctx context.Context) error{
+		"database": app.db.PingContext,
+	}
+	if pinger, ok := app.blobs.(blob.Pinger); ok {
+		checks["blob_store"] = pinger.Ping
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]dependencyStatus, len(checks))
+	ready := true
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), app.config.healthTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			result := dependencyStatus{
+				Status:    "up",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			// the error stays in the logs, as it may give away details of the infrastructure
+			if err != nil {
+				result.Status = "down"
+				app.logger.Error("readiness check failed", "dependency", name, "error", err.Error())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if err != nil {
+				ready = false
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	status := http.StatusOK
+	env := envelope{
+		"status":       "ready",
+		"dependencies": results,
+		"build":        buildInfo(),
+	}
+
+	switch {
+	case app.draining.Load():
+		status = http.StatusServiceUnavailable
+		env["status"] = "draining"
+	case !ready:
+		status = http.StatusServiceUnavailable
+		env["status"] = "unavailable"
+	}
+
+	// probes should always see the current state
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+
+	err := app.writeResponse(w, r, status, env, headers)
 	if err != nil {
-		// log the error
-		app.logger.Error(err.Error())
 		app.serverErrorResponse(w, r, err)
 	}
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/blob"
+)
+
+// a pingingStore is a blob store whose ping fails with err, or takes delay to answer
+type pingingStore struct {
+	blob.BlobStore
+	err   error
+	delay time.Duration
+}
+
+func (s pingingStore) Ping(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name         string
+		dbErr        error
+		blobs        blob.BlobStore
+		draining     bool
+		status       int
+		state        string
+		dependencies map[string]string
+	}{
+		{
+			name:         "ready",
+			blobs:        pingingStore{},
+			status:       http.StatusOK,
+			state:        "ready",
+			dependencies: map[string]string{"database": "up", "blob_store": "up"},
+		},
+		{
+			name:         "store without a ping",
+			status:       http.StatusOK,
+			state:        "ready",
+			dependencies: map[string]string{"database": "up"},
+		},
+		{
+			name:         "database down",
+			dbErr:        errors.New("dial tcp 10.0.0.5:5432: connection refused"),
+			blobs:        pingingStore{},
+			status:       http.StatusServiceUnavailable,
+			state:        "unavailable",
+			dependencies: map[string]string{"database": "down", "blob_store": "up"},
+		},
+		{
+			name:         "blob store down",
+			blobs:        pingingStore{err: errors.New("bucket posters-prod: access denied")},
+			status:       http.StatusServiceUnavailable,
+			state:        "unavailable",
+			dependencies: map[string]string{"database": "up", "blob_store": "down"},
+		},
+		{
+			name:         "blob store too slow",
+			blobs:        pingingStore{delay: time.Hour},
+			status:       http.StatusServiceUnavailable,
+			state:        "unavailable",
+			dependencies: map[string]string{"database": "up", "blob_store": "down"},
+		},
+		{
+			name:         "draining",
+			blobs:        pingingStore{},
+			draining:     true,
+			status:       http.StatusServiceUnavailable,
+			state:        "draining",
+			dependencies: map[string]string{"database": "up", "blob_store": "up"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			db.pingErr = tt.dbErr
+			app.blobs = tt.blobs
+			app.config.healthTimeout = 50 * time.Millisecond
+			app.draining.Store(tt.draining)
+			logs := &syncBuffer{}
+			app.logger = slog.New(slog.NewTextHandler(logs, nil))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthz/ready", nil)
+			rr := httptest.NewRecorder()
+			app.readinessHandler(rr, r)
+
+			if rr.Code != tt.status {
+				t.Errorf("status = %d, want %d", rr.Code, tt.status)
+			}
+			if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+
+			var body struct {
+				Status       string                      `json:"status"`
+				Dependencies map[string]dependencyStatus `json:"dependencies"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Status != tt.state {
+				t.Errorf("status = %q, want %q", body.Status, tt.state)
+			}
+			got := make(map[string]string)
+			for name, dep := range body.Dependencies {
+				got[name] = dep.Status
+			}
+			if !reflect.DeepEqual(got, tt.dependencies) {
+				t.Errorf("dependencies = %v, want %v", got, tt.dependencies)
+			}
+
+			// the reason a dependency is down is logged, but kept out of the response
+			if tt.dbErr != nil {
+				if strings.Contains(rr.Body.String(), "10.0.0.5") {
+					t.Errorf("the error was sent to the client: %s", rr.Body)
+				}
+				if !strings.Contains(logs.String(), "10.0.0.5") {
+					t.Errorf("the error was not logged:\n%s", logs)
+				}
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	// liveness does not depend on anything, so a draining server with its database down is still alive
+	app, db := newTestApp(t)
+	db.pingErr = errors.New("connection refused")
+	app.draining.Store(true)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/healthz/live", nil)
+	rr := httptest.NewRecorder()
+	app.livenessHandler(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var body struct {
+		Status string         `json:"status"`
+		Build  map[string]any `json:"build"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != "alive" || body.Build == nil {
+		t.Errorf("body = %s, want alive with the build info", rr.Body)
+	}
+}
@@ -1,45 +1,154 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/i18n"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
 
 // create an envelope type
 type envelope map[string]any
 
+// the largest request body we read, for any of the supported formats
+const maxRequestBytes = 1_048_567
+
 func (app *application) readIDParams(r *http.Request) (int64, error) {
+	return app.readInt64Param(r, "id")
+}
+
+// readInt64Param reads a named, positive integer parameter from the URL, e.g. the :credit_id in /v1/movies/:id/credits/:credit_id
+func (app *application) readInt64Param(r *http.Request, name string) (int64, error) {
 	// get the parameters from the context in the request URL
 	params := httprouter.ParamsFromContext(r.Context())
 
 	// convert the params into an int
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid ID parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
 	return id, nil
 }
 
-// a writeJSON helper to help with encoding data into JSON.
-// it takes in the responseWriter, the status code to send, the data to encode, any HTTP headers and returns an error
+// readString returns a string value from the query string, or the provided default value if no matching key is found
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// readCSV reads a comma-separated query string value into a slice, or returns the default value if the key is missing
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+	if csv == "" {
+		return defaultValue
+	}
+
+	return strings.Split(csv, ",")
+}
+
+// readInt reads an integer value from the query string, or returns the default value if the key is missing
+// if the value cannot be converted to an integer, the problem is recorded in the validator
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "validation.integer")
+		return defaultValue
+	}
+
+	return i
+}
+
+// auditInfo says who is making the request, for the audit log
+// the actor is the user the proxy in front of the API authenticated, and the client IP is the address the request came
+// from, which is the proxy's own address when there is one
+func (app *application) auditInfo(r *http.Request) data.AuditInfo {
+	actor := r.Header.Get(app.config.actorHeader)
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return data.AuditInfo{Actor: actor, ClientIP: ip, RequestID: app.contextGetRequestID(r)}
+}
+
+// readTime reads an RFC 3339 timestamp from the query string, or returns the zero time if the key is missing
+// if the value cannot be parsed, the problem is recorded in the validator
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "validation.timestamp")
+		return time.Time{}
+	}
+
+	return t
+}
+
+// newValidator returns a Validator which writes its messages in the request's locale
+func (app *application) newValidator(r *http.Request) *validator.Validator {
+	return validator.NewWithLocalizer(app.contextGetLocalizer(r))
+}
+
+// readRuntimeFormat reads the ?runtime_format= query parameter, used to choose how movie runtimes are written out
+// any problem with the value is recorded in the validator
+func (app *application) readRuntimeFormat(r *http.Request, v *validator.Validator) data.RuntimeFormat {
+	f := data.RuntimeFormat(app.readString(r.URL.Query(), "runtime_format", string(data.RuntimeFormatMinutes)))
+	data.ValidateRuntimeFormat(v, f)
+
+	return f
+}
+
+// a writeResponse helper to help with encoding data into the format the client asked for in the Accept header.
+// it takes in the responseWriter, the request, the status code to send, the data to encode, any HTTP headers and returns an error
 // modify the date to be of type envelope
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	// marshal the data
-	js, err := json.MarshalIndent(data, "", "    ") // 4 spaces for the indentation
+func (app *application) writeResponse(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	// pick the format from the Accept header, unsupported types are rejected up front by the negotiateContent middleware
+	// so falling back to JSON here only happens for error responses
+	f, err := negotiateFormat(r.Header.Get("Accept"))
+	if err != nil {
+		f = formatJSON
+	}
+
+	body, err := f.marshal(data, app.config.jsonCompact)
 	if err != nil {
 		return err
 	}
 
-	// add a newline nicety
-	js = append(js, '\n')
+	// add the content-type header to enable the parsing of the data by the client
+	// this is set before the extra headers so that callers can override it (e.g. application/problem+json)
+	w.Header().Set("Content-Type", f.contentType())
+	w.Header().Add("Vary", "Accept")
 
 	// if there are headers available, loop through each header in the header map
 	// add the headers to the responseWriter header map
@@ -47,23 +156,73 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
-	// add the content-type header to enable the parsing of the data as a JSON
-	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	w.Write([]byte(js))
+	w.Write(body)
 
 	return nil
 }
 
-// a readJSON helper function to help with reading JSON input
-// we use this to also triage errors regarding JSON input adn provide a suitable error message
-func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dest any) error {
+// a requestError is a request that could not be read, such as one with a badly-formed body
+// it is kept as a message key and its arguments, so that badRequestResponse can write it in the client's language
+type requestError struct {
+	key  string
+	args []any
+}
+
+// Error returns the message in the default locale, e.g. for the logs
+func (e *requestError) Error() string {
+	return e.message(nil)
+}
+
+// message translates the error, a nil Localizer uses the default locale
+func (e *requestError) message(l *i18n.Localizer) string {
+	return l.T(e.key, e.args...)
+}
+
+// a readRequest helper function to help with reading the request body in any of the supported formats
+// we use this to also triage errors regarding the input adn provide a suitable error message
+func (app *application) readRequest(w http.ResponseWriter, r *http.Request, dest any) error {
 	// limit the size of the request body to 1MB using maxBytesReader
-	maxBytes := 1_048_567
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+
+	// the negotiateContent middleware has already rejected any unsupported Content-Type
+	f, ok := requestFormat(r.Header.Get("Content-Type"))
+	if !ok {
+		return &requestError{"error.body_content_type", []any{r.Header.Get("Content-Type")}}
+	}
+
+	// JSON bodies are streamed straight into the decoder
+	if f == formatJSON {
+		return decodeJSON(r.Body, dest, f.name)
+	}
+
+	// every other format is read in full and converted to JSON, so that it goes through the same decoding and checks
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return &requestError{"error.body_too_large", []any{maxBytesError.Limit}}
+		}
+		return err
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &requestError{"error.body_empty", nil}
+	}
+
+	js, err := f.toJSON(body, reflect.TypeOf(dest).Elem())
+	if err != nil {
+		return &requestError{"error.body_malformed", []any{f.name}}
+	}
+
+	return decodeJSON(bytes.NewReader(js), dest, f.name)
+}
 
+// decodeJSON decodes a single JSON value into dest, translating the decoder errors into messages the client can act on
+// formatName is the format the client sent, so that the messages refer to XML, YAML, etc. where appropriate
+func decodeJSON(body io.Reader, dest any, formatName string) error {
 	// initialize the Decoder and call the DisallowUnknownFields() method on it before decoding
-	dec := json.NewDecoder(r.Body)
+	dec := json.NewDecoder(body)
 	dec.DisallowUnknownFields()
 
 	// decode request into the target destination
@@ -80,31 +239,31 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dest an
 		// check whether the error has the type json.SyntaxError
 		// return a plain-English error message which includes the location of the problem
 		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+			return &requestError{"error.body_malformed_at", []any{formatName, syntaxError.Offset}}
 
 			// in some circumstances, it may return an io.ErrUnexpectedEOF for syntax errors in the JSON
 		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+			return &requestError{"error.body_malformed", []any{formatName}}
 
 			// catch any UnmarshalTypeError when the JSON type is wrong for the target destination
 		case errors.As(err, &unMarshalTypeError):
 			if unMarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unMarshalTypeError.Field)
+				return &requestError{"error.body_wrong_type_for_field", []any{formatName, unMarshalTypeError.Field}}
 			}
-			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unMarshalTypeError.Offset)
+			return &requestError{"error.body_wrong_type_at", []any{formatName, unMarshalTypeError.Offset}}
 
 			// check if the request body is empty, this gives a io.EOF error
 		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+			return &requestError{"error.body_empty", nil}
 
 			// check if JSON contains a field name that cannot be mapped to a destination field, after calling Decode(), and return an error
 		case strings.HasPrefix(err.Error(), "json: unknown field"):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+			fieldName := strings.TrimSpace(strings.TrimPrefix(err.Error(), "json: unknown field"))
+			return &requestError{"error.body_unknown_key", []any{fieldName}}
 
 			// check if the request body has exceeded the max size limit
 		case errors.As(err, &maxBytesError):
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+			return &requestError{"error.body_too_large", []any{maxBytesError.Limit}}
 
 			// throw an InvalidUnmarshalError if we pass something that is not a non-nil pointer to Decode()
 			// catch this and panic instead of returning an error to the Handler
@@ -122,7 +281,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dest an
 	// if additional data is in the request body, we return our own custom error message
 	err = dec.Decode(&struct{}{})
 	if !errors.Is(err, io.EOF) {
-		return errors.New("body must only contain a single JSON value")
+		return &requestError{"error.body_single_value", []any{formatName}}
 	}
 
 	return nil
@@ -1,8 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/i18n"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
@@ -24,3 +37,235 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requestIDRX matches the request IDs that are accepted from clients and proxies, anything else is replaced
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID gives every request an ID, which is sent back in the X-Request-Id header, logged with errors and
+// recorded in the audit log, so that a report from a client can be matched up with what the server did
+// an ID set by the client or a proxy in front of the API is kept, so that it can be followed across services
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !requestIDRX.MatchString(id) {
+			b := make([]byte, 16)
+			// crypto/rand.Read never returns an error
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-Id", id)
+
+		next.ServeHTTP(w, app.contextSetRequestID(r, id))
+	})
+}
+
+// requireAdmin only lets through requests with the admin token as a bearer token in the Authorization header
+// the admin routes are switched off altogether when no token has been set with -admin-token
+func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.config.adminToken == "" {
+			app.adminDisabledResponse(w, r)
+			return
+		}
+
+		// the comparison takes the same time however much of the token matches, so it cannot be guessed piece by piece
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(app.config.adminToken)) != 1 {
+			app.invalidAdminTokenResponse(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// negotiateContent rejects requests that we cannot respond to in a format the client accepts (406)
+// and request bodies in a format we cannot read (415), before any handler does work on them
+func (app *application) negotiateContent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := negotiateFormat(r.Header.Get("Accept")); err != nil {
+			app.notAcceptableResponse(w, r)
+			return
+		}
+
+		// only check the Content-Type when there is a body to read
+		if r.ContentLength != 0 && r.Body != nil && r.Body != http.NoBody {
+			if _, ok := requestFormat(r.Header.Get("Content-Type")); !ok {
+				app.unsupportedMediaTypeResponse(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localize picks the locale for the request from the Accept-Language header and stores it in the request context
+// so that validation and error messages are written in the client's language
+func (app *application) localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := i18n.Negotiate(r.Header.Get("Accept-Language"))
+
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", l.Locale())
+
+		next.ServeHTTP(w, app.contextSetLocalizer(r, l))
+	})
+}
+
+// idempotencyLockTimeout is how long a request may hold its Idempotency-Key before a retry is allowed to take it over,
+// in case the server handling it went away without finishing
+const idempotencyLockTimeout = time.Minute
+
+// the response headers that are stored and replayed along with the body
+var idempotencyReplayHeaders = []string{"Content-Type", "Content-Language", "Location", "Vary"}
+
+// idempotent makes a handler safe to retry when the client sends an Idempotency-Key header
+// the first request with a key is handled as normal and its response stored, retries with the same key and body
+// get the stored response back without running the handler again
+// server errors are not stored, so the request can be retried once whatever went wrong is fixed
+func (app *application) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		if len(key) > 255 {
+			app.badRequestResponse(w, r, &requestError{"error.idempotency_key_too_long", []any{255}})
+			return
+		}
+
+		// read the body so that it can be fingerprinted, and put it back for the handler
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError):
+				app.badRequestResponse(w, r, &requestError{"error.body_too_large", []any{maxBytesError.Limit}})
+			default:
+				app.badRequestResponse(w, r, err)
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// the same key must always be sent with the same request
+		// the Accept headers are left out, so a retry gets the stored response in the format of the first request
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s\n%s\n%s\n", r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type"))
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		record, claim, err := app.models.Idempotency.Begin(key, fingerprint, app.config.idempotencyTTL, idempotencyLockTimeout)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrIdempotencyKeyMismatch):
+				app.idempotencyKeyReusedResponse(w, r)
+			case errors.Is(err, data.ErrIdempotencyKeyInProgress):
+				app.idempotencyKeyInProgressResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		// replay the stored response
+		if record != nil {
+			for name, values := range record.Headers {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.Status)
+			w.Write(record.Body)
+			return
+		}
+
+		// the key is ours, so handle the request while recording the response
+		rec := &responseRecorder{ResponseWriter: w}
+		completed := false
+
+		// give the key up if the request did not finish, including when the handler panics
+		defer func() {
+			if !completed {
+				err := app.models.Idempotency.Release(key, claim)
+				if err != nil {
+					app.logError(r, err)
+				}
+			}
+		}()
+
+		next(rec, r)
+
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		headers := make(map[string][]string)
+		for _, name := range idempotencyReplayHeaders {
+			if values := rec.Header().Values(name); len(values) > 0 {
+				headers[name] = values
+			}
+		}
+
+		err = app.models.Idempotency.Complete(key, claim, rec.status, headers, rec.body.Bytes())
+		if err != nil {
+			// the response has already been sent, so all we can do is log the problem
+			app.logError(r, err)
+			return
+		}
+		completed = true
+	}
+}
+
+// a responseRecorder passes a response through to the client while keeping a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// compress compresses response bodies with the best encoding the client accepts, see compressWriter for which ones qualify
+func (app *application) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// caches must keep the compressed and uncompressed responses apart, whichever one this request gets
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minBytes: app.config.compression.minBytes}
+		defer func() {
+			if err := cw.close(); err != nil {
+				app.logError(r, err)
+			}
+		}()
+
+		next.ServeHTTP(cw, r)
+	})
+}
@@ -4,11 +4,117 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/TaskMasterErnest/greenlight/internal/data"
 	"github.com/TaskMasterErnest/greenlight/internal/validator"
 )
 
+// the values the movies list can be sorted by, a "-" prefix sorts in descending order
+var movieSortSafelist = []string{
+	"id", "title", "year", "runtime", "average_rating", "rating_count",
+	"-id", "-title", "-year", "-runtime", "-average_rating", "-rating_count",
+}
+
+// listMoviesHandler
+func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	// hold the expected values from the query string
+	var input struct {
+		Title  string
+		Genres []string
+		data.Filters
+	}
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	input.Title = app.readString(qs, "title", "")
+	input.Genres = app.readCSV(qs, "genres", []string{})
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = movieSortSafelist
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	// movies store genres under their display names, so match the filter the same way
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movies, metadata, err := app.models.Movies.GetAll(input.Title, genres.Canonical(input.Genres), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, movie := range movies {
+		movie.SetRuntimeFormat(runtimeFormat)
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movies": movies, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// lookupMovieHandler finds a movie by its ID in another database, e.g. /v1/movies/lookup?imdb=tt0111161
+func (app *application) lookupMovieHandler(w http.ResponseWriter, r *http.Request) {
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	// exactly one source must be given
+	var source string
+	sources := make([]string, 0, len(data.ExternalIDSources))
+	ids := data.ExternalIDs{}
+	for name := range data.ExternalIDSources {
+		sources = append(sources, name)
+		if qs.Has(name) {
+			source = name
+			ids[name] = qs.Get(name)
+		}
+	}
+	slices.Sort(sources)
+
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	v.Check(len(ids) == 1, "external_id", "validation.lookup_one_of", strings.Join(sources, ", "))
+	data.ValidateExternalIDs(v, ids)
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	movie, err := app.models.Movies.GetByExternalID(source, ids[source])
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
+
+	movie.SetRuntimeFormat(runtimeFormat)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 // showMovieHandler
 func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	// get the ID params from the context
@@ -16,6 +122,17 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 	id, err := app.readIDParams(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
+		return
+	}
+
+	// read the optional runtime output format and related data to include, and reject unsupported values
+	v := app.newValidator(r)
+	runtimeFormat := app.readRuntimeFormat(r, v)
+	include := app.readCSV(r.URL.Query(), "include", []string{})
+	v.Check(validator.All(include, "credits"), "include", "validation.include", "credits")
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
 	}
 
 	// call the Get() method to fetch specific movie data, return errors
@@ -30,26 +147,39 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// fetch the credits if the client asked for them with ?include=credits
+	if slices.Contains(include, "credits") {
+		movie.Credits, err = app.models.People.GetCreditsForMovie(movie.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	// envelope the movie in the envelope type
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	movie.SetRuntimeFormat(runtimeFormat)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
-		app.logger.Error(err.Error())
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// movieInput is the body of a request to create or update a movie
+type movieInput struct {
+	Title       string           `json:"title"`
+	Year        int32            `json:"year"`
+	Runtime     data.Runtime     `json:"runtime"` // make this field a data.Runtime type
+	Genres      []string         `json:"genres"`
+	ExternalIDs data.ExternalIDs `json:"external_ids"`
+}
+
 // createMovieHandler
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	// create struct to hold movie data
-	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"` // make this field a data.Runtime type
-		Genres  []string     `json:"genres"`
-	}
+	var input movieInput
 
-	// initialize a json.Decoder instance to decode the data from client into the input struct
-	err := app.readJSON(w, r, &input)
+	// decode the data from client into the input struct, in whichever format it was sent
+	err := app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -57,25 +187,45 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	// copy movie values from the input struct to a new Movie struct
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:       input.Title,
+		Year:        input.Year,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+		ExternalIDs: input.ExternalIDs,
 	}
 
 	// initialize a new Validator instance for input Validation
-	v := validator.New()
+	// the runtime format used for the response is checked alongside the movie itself
+	v := app.newValidator(r)
+	runtimeFormat := app.readRuntimeFormat(r, v)
+
+	// load the genre vocabulary, so that unknown genres are rejected
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	// validate movie with the ValidateMovie function and return a response
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.FailedValidationResponse(w, r, v.Errors)
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
 		return
 	}
 
+	// store the genres under their display names, e.g. "sci-fi" becomes "Sci-Fi"
+	movie.Genres = genres.Canonical(movie.Genres)
+
 	// call the Insert method from the movies model, and pass in the pointer to the validated movie struct
-	err = app.models.Movies.Insert(movie)
+	// a movie that is already stored gets a 409 Conflict response pointing at the existing record
+	err = app.models.Movies.WithAudit(app.auditInfo(r)).Insert(movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		var duplicateErr *data.DuplicateMovieError
+		switch {
+		case errors.As(err, &duplicateErr):
+			app.duplicateMovieResponse(w, r, duplicateErr)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -85,15 +235,14 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", movie.ID))
 
-	// write a JSON response with a 201 Created status code, movie data in response body,
+	movie.SetRuntimeFormat(runtimeFormat)
+
+	// write a response with a 201 Created status code, movie data in response body,
 	// and the Location header
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
-
-	// respond with the contents of the input struct
-	fmt.Fprintf(w, "%+v\n", input)
 }
 
 // updateMovieHandler
@@ -118,15 +267,10 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// construct an input struct to hold expected new data
-	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
-	}
+	var input movieInput
 
-	// read the JSON request body into the input struct
-	err = app.readJSON(w, r, &input)
+	// read the request body into the input struct
+	err = app.readRequest(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -137,24 +281,47 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	movie.Year = input.Year
 	movie.Runtime = input.Runtime
 	movie.Genres = input.Genres
+	// the external IDs are kept as they are unless the client sends them
+	if input.ExternalIDs != nil {
+		movie.ExternalIDs = input.ExternalIDs
+	}
 
 	// validate the updated movie record, send a 422 Unprocessable Entity response of any checks fail
-	v := validator.New()
+	v := app.newValidator(r)
+	runtimeFormat := app.readRuntimeFormat(r, v)
 
-	if data.ValidateMovie(v, movie); !v.Valid() {
-		app.FailedValidationResponse(w, r, v.Errors)
+	genres, err := app.models.Genres.Vocabulary()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
 		return
 	}
 
+	movie.Genres = genres.Canonical(movie.Genres)
+
 	// pass the updated movie record to the new Update() record
-	err = app.models.Movies.Update(movie)
+	err = app.models.Movies.WithAudit(app.auditInfo(r)).Update(movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		var duplicateErr *data.DuplicateMovieError
+		switch {
+		// the movie was deleted after it was read above
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.As(err, &duplicateErr):
+			app.duplicateMovieResponse(w, r, duplicateErr)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	// write the updated movie record in a JSON response and send to client
-	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	// write the updated movie record in a response and send to client
+	movie.SetRuntimeFormat(runtimeFormat)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"movie": movie}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -169,8 +336,15 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// look up the poster first, its metadata goes with the movie but the blobs have to be removed separately
+	poster, err := app.models.Posters.Get(id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// delete the movie from the database, return a 404 error response if any errors occur
-	err = app.models.Movies.Delete(id)
+	err = app.models.Movies.WithAudit(app.auditInfo(r)).Delete(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -181,8 +355,12 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if poster != nil {
+		app.deletePosterBlobs(r, poster)
+	}
+
 	// write a successful delete message
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.movie_deleted")}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storedMovie is the row of a movie without a poster
+var storedMovie = fakeResult{
+	match: "FROM movies",
+	columns: []string{"id", "created_at", "title", "year", "runtime", "genres", "version", "average_rating", "rating_count",
+		"external_ids", "content_type", "width", "height", "size", "checksum", "updated_at"},
+	rows: [][]driver.Value{{
+		int64(1), time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), "Casablanca", int64(1942), int64(102), []byte("{Drama,Romance}"),
+		int64(1), float64(0), int64(0), []byte(`{"imdb": "tt0034583"}`), nil, nil, nil, nil, nil, nil,
+	}},
+}
+
+var creditColumns = []string{"id", "movie_id", "person_id", "name", "role", "character_name", "billing_order"}
+
+func TestShowMovieIncludeCredits(t *testing.T) {
+	credits := fakeResult{
+		match:   "FROM movie_credits",
+		columns: creditColumns,
+		rows: [][]driver.Value{
+			{int64(1), int64(1), int64(10), "Michael Curtiz", "director", "", int64(0)},
+			{int64(2), int64(1), int64(11), "Humphrey Bogart", "actor", "Rick Blaine", int64(0)},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		results []fakeResult
+		credits []map[string]any
+	}{
+		{
+			name:  "left out by default",
+			query: "",
+		},
+		{
+			name:    "included without any",
+			query:   "?include=credits",
+			results: []fakeResult{{match: "FROM movie_credits", columns: creditColumns}},
+			credits: []map[string]any{},
+		},
+		{
+			name:    "included with another runtime format",
+			query:   "?include=credits&runtime_format=iso8601",
+			results: []fakeResult{{match: "FROM movie_credits", columns: creditColumns}},
+			credits: []map[string]any{},
+		},
+		{
+			name:  "included",
+			query: "?include=credits",
+			credits: []map[string]any{
+				{"id": float64(1), "person_id": float64(10), "name": "Michael Curtiz", "role": "director", "billing_order": float64(0)},
+				{"id": float64(2), "person_id": float64(11), "name": "Humphrey Bogart", "role": "actor", "character": "Rick Blaine", "billing_order": float64(0)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t, append(tt.results, credits, storedMovie)...)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/1"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			app.showMovieHandler(rr, withParams(r, "id", "1"))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+
+			// credits that were asked for are a list even when there are none
+			var body struct {
+				Movie struct {
+					Title   string           `json:"title"`
+					Credits []map[string]any `json:"credits"`
+				} `json:"movie"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Movie.Title != "Casablanca" {
+				t.Errorf("title = %q, want Casablanca", body.Movie.Title)
+			}
+			if !reflect.DeepEqual(body.Movie.Credits, tt.credits) {
+				t.Errorf("credits = %v, want %v", body.Movie.Credits, tt.credits)
+			}
+
+			// the credits are only read when they are asked for
+			if ran := db.ran("FROM movie_credits"); ran != (tt.credits != nil) {
+				t.Errorf("credits read = %t, want %t", ran, tt.credits != nil)
+			}
+			if tt.credits != nil {
+				if got := db.argsOf("FROM movie_credits"); !reflect.DeepEqual(got, []driver.Value{int64(1)}) {
+					t.Errorf("credits args = %v, want the movie's ID", got)
+				}
+			}
+		})
+	}
+}
+
+func TestShowMovieIncludeValidation(t *testing.T) {
+	app, db := newTestApp(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/movies/1?include=credits,reviews", nil)
+	rr := httptest.NewRecorder()
+	app.showMovieHandler(rr, withParams(r, "id", "1"))
+
+	if got := validationErrors(t, rr)["include"]; got != "must only contain credits" {
+		t.Errorf("include error = %q, want %q", got, "must only contain credits")
+	}
+	if db.ran("FROM movies") {
+		t.Error("the movie was read")
+	}
+}
+
+// genreVocabulary is the genres table, with the genres the test movies use
+var genreVocabulary = fakeResult{
+	match:   "FROM genres",
+	columns: []string{"id", "created_at", "slug", "name"},
+	rows: [][]driver.Value{
+		{int64(1), time.Now(), "drama", "Drama"},
+		{int64(2), time.Now(), "romance", "Romance"},
+	},
+}
+
+func TestCreateMovieDuplicate(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		results  []fakeResult
+		location string
+		message  string
+		args     []driver.Value
+	}{
+		{
+			name: "external ID",
+			body: `{"title": "Casablanca", "year": 1942, "runtime": 102, "genres": ["drama"], "external_ids": {"tmdb": "289", "imdb": "tt0034583"}}`,
+			results: []fakeResult{
+				{match: "external_ids ? 'imdb'", columns: []string{"id"}},
+				{match: "external_ids ? 'tmdb'", columns: []string{"id"}, rows: [][]driver.Value{{int64(7)}}},
+			},
+			location: "/v1/movies/7",
+			message:  "A movie with the same tmdb ID already exists at /v1/movies/7",
+		},
+		{
+			name: "title and year",
+			body: `{"title": "casablanca!", "year": 1942, "runtime": 102, "genres": ["drama"]}`,
+			results: []fakeResult{
+				{match: "regexp_replace(lower(title)", columns: []string{"id"}, rows: [][]driver.Value{{int64(3)}}},
+			},
+			location: "/v1/movies/3",
+			args:     []driver.Value{"casablanca!", int64(1942)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := append([]fakeResult{genreVocabulary, {match: "pg_advisory_xact_lock"}}, tt.results...)
+			app, db := newTestApp(t, results...)
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/movies", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			app.createMovieHandler(rr, r)
+
+			if rr.Code != http.StatusConflict {
+				t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusConflict, rr.Body)
+			}
+			if got := rr.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.message != "" && body.Error != tt.message {
+				t.Errorf("error = %q, want %q", body.Error, tt.message)
+			}
+
+			if tt.args != nil {
+				if got := db.argsOf("regexp_replace(lower(title)"); !reflect.DeepEqual(got, tt.args) {
+					t.Errorf("title match args = %v, want %v", got, tt.args)
+				}
+			}
+			if db.ran("INSERT INTO movies") {
+				t.Error("the duplicate was stored")
+			}
+		})
+	}
+}
@@ -0,0 +1,924 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/openapi"
+)
+
+// the Swagger UI page for browsing the API description
+//
+//go:embed docs.html
+var apiDocsPage []byte
+
+// openAPIHandler serves the OpenAPI description of the API
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(app.openapi)
+}
+
+// apiDocsHandler serves a Swagger UI page for the OpenAPI description
+func (app *application) apiDocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(apiDocsPage)
+}
+
+// an operationDoc describes a route for the API description
+// the parts that every route of a kind shares, such as the path parameters and most of the error responses,
+// are worked out from the route and do not need to be listed here
+type operationDoc struct {
+	id          string
+	summary     string
+	description string
+	tag         string
+	query       []*openapi.Parameter
+	// body is the input struct the handler reads the request into, and model the type it builds from it and validates
+	body  reflect.Type
+	model reflect.Type
+	// status is the status of a successful response, and result the members of the envelope sent with it,
+	// each of which is a reflect.Type or an *openapi.Schema
+	status int
+	result map[string]any
+	// headers are the headers set on a successful response
+	headers []string
+	// also lists other statuses that are sent with the same envelope as the successful response
+	also map[int]string
+	// errors lists the codes of the error responses that are particular to the route
+	errors []string
+	// idempotent routes are wrapped with the idempotent middleware
+	idempotent bool
+	// raw routes are on the media router, so they skip content negotiation
+	raw bool
+	// admin routes are wrapped with requireAdmin
+	admin bool
+	// requestBody and responses replace the generated ones, for routes which do not use envelopes
+	requestBody *openapi.RequestBody
+	responses   map[string]*openapi.Response
+}
+
+// the descriptions of the error codes, and the status each is sent with
+var errorDocs = map[string]struct {
+	status      int
+	description string
+	headers     []string
+}{
+	errCodeServerError:          {http.StatusInternalServerError, "The server encountered a problem and could not process the request", nil},
+	errCodeNotFound:             {http.StatusNotFound, "The requested resource could not be found", nil},
+	errCodeMethodNotAllowed:     {http.StatusMethodNotAllowed, "The method is not supported for this resource", nil},
+	errCodeBadRequest:           {http.StatusBadRequest, "The request could not be read", nil},
+	errCodeFailedValidation:     {http.StatusUnprocessableEntity, "The request failed validation", nil},
+	errCodeNotAcceptable:        {http.StatusNotAcceptable, "None of the media types in the Accept header can be produced", nil},
+	errCodeUnsupportedMediaType: {http.StatusUnsupportedMediaType, "The request body is in a format the API cannot read", nil},
+	errCodeContentTooLarge:      {http.StatusRequestEntityTooLarge, "The request body is over the size limit", nil},
+	errCodeDuplicateMovie:       {http.StatusConflict, "The movie matches one that is already stored, which the Location header points at", []string{"Location"}},
+	errCodeIdempotencyKeyReused: {http.StatusUnprocessableEntity, "The Idempotency-Key was first sent with a different request", nil},
+	errCodeIdempotencyInFlight:  {http.StatusConflict, "A request with the same Idempotency-Key is still being handled", []string{"Retry-After"}},
+	errCodeInvalidAdminToken:    {http.StatusUnauthorized, "The admin token is missing or wrong", []string{"WWW-Authenticate"}},
+	errCodeAdminDisabled:        {http.StatusForbidden, "The admin routes are disabled, as the server has no admin token", nil},
+}
+
+// the descriptions of the response headers set by the routes
+var headerDocs = map[string]string{
+	"Location":         "The URL of the resource",
+	"Content-Location": "The canonical URL of the resource",
+	"Retry-After":      "The number of seconds to wait before retrying",
+	"ETag":             "The version of the representation, for If-None-Match",
+	"Last-Modified":    "When the resource was last changed",
+	"Cache-Control":    "How long the response may be cached for",
+	"WWW-Authenticate": "The authentication scheme to use",
+}
+
+// paging, sorting and format parameters, shared between the routes that take them
+var (
+	pageParam = &openapi.Parameter{
+		Name: "page", In: "query", Description: "The page of records to return",
+		Schema: &openapi.Schema{Type: "integer", Minimum: openapi.Float(1), Maximum: openapi.Float(10_000_000), Default: 1},
+	}
+	pageSizeParam = &openapi.Parameter{
+		Name: "page_size", In: "query", Description: "The number of records on each page",
+		Schema: &openapi.Schema{Type: "integer", Minimum: openapi.Float(1), Maximum: openapi.Float(100), Default: 20},
+	}
+	runtimeFormatParam = &openapi.Parameter{
+		Name: "runtime_format", In: "query", Description: "How the runtime of the movies in the response is written",
+		Schema: &openapi.Schema{Type: "string", Enum: enum(data.RuntimeFormats), Default: string(data.RuntimeFormatMinutes)},
+	}
+)
+
+// sortParam describes the ?sort= parameter of a list, a "-" prefix sorts in descending order
+func sortParam(safelist []string, defaultValue string) *openapi.Parameter {
+	return &openapi.Parameter{
+		Name: "sort", In: "query", Description: `The field to sort by, a "-" prefix sorts in descending order`,
+		Schema: &openapi.Schema{Type: "string", Enum: enum(safelist), Default: defaultValue},
+	}
+}
+
+// enum converts a list of values to the form used by the schema
+func enum[T any](values []T) []any {
+	e := make([]any, len(values))
+	for i, value := range values {
+		e[i] = value
+	}
+
+	return e
+}
+
+// operations documents every route, keyed by method and path as they are registered with the router
+var operations = map[string]operationDoc{
+	"GET /v1/healthz": {
+		id: "healthCheck", tag: "health", summary: "Show the status of the API",
+		description: "Kept for existing monitors, probes should use /v1/healthz/live and /v1/healthz/ready",
+		result: map[string]any{
+			"Status":      reflect.TypeFor[string](),
+			"System_Info": reflect.TypeFor[map[string]string](),
+		},
+	},
+	"GET /v1/healthz/live": {
+		id: "liveness", tag: "health", summary: "Check that the process is up",
+		description: "Does not check any dependencies",
+		result: map[string]any{
+			"status":      &openapi.Schema{Type: "string", Enum: []any{"alive"}},
+			"environment": reflect.TypeFor[string](),
+			"build":       reflect.TypeFor[map[string]string](),
+		},
+	},
+	"GET /v1/healthz/ready": {
+		id: "readiness", tag: "health", summary: "Check that the server should be sent traffic",
+		headers: []string{"Cache-Control"},
+		also:    map[int]string{http.StatusServiceUnavailable: "A dependency is down, or the server is draining before shutting down"},
+		result: map[string]any{
+			"status":       &openapi.Schema{Type: "string", Enum: []any{"ready", "unavailable", "draining"}},
+			"dependencies": reflect.TypeFor[map[string]dependencyStatus](),
+			"build":        reflect.TypeFor[map[string]string](),
+		},
+	},
+
+	"GET /v1/movies": {
+		id: "listMovies", tag: "movies", summary: "List movies",
+		query: []*openapi.Parameter{
+			{Name: "title", In: "query", Description: "Only movies whose title contains all of these words", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "genres", In: "query", Description: "Only movies with all of these comma-separated genres", Schema: &openapi.Schema{Type: "string"}},
+			pageParam, pageSizeParam, sortParam(movieSortSafelist, "id"), runtimeFormatParam,
+		},
+		result: map[string]any{"movies": reflect.TypeFor[[]*data.Movie](), "metadata": reflect.TypeFor[data.Metadata]()},
+		errors: []string{errCodeFailedValidation},
+	},
+	"POST /v1/movies": {
+		id: "createMovie", tag: "movies", summary: "Add a movie",
+		query: []*openapi.Parameter{runtimeFormatParam},
+		body:  reflect.TypeFor[movieInput](), model: reflect.TypeFor[data.Movie](),
+		status: http.StatusCreated, result: map[string]any{"movie": reflect.TypeFor[data.Movie]()},
+		headers: []string{"Location"}, errors: []string{errCodeDuplicateMovie}, idempotent: true,
+	},
+	"GET /v1/movies/lookup": {
+		id: "lookupMovie", tag: "movies", summary: "Find a movie by its ID in another database",
+		description: "Exactly one of the sources must be given",
+		query:       append(externalIDParams(), runtimeFormatParam),
+		result:      map[string]any{"movie": reflect.TypeFor[data.Movie]()},
+		headers:     []string{"Content-Location"}, errors: []string{errCodeFailedValidation, errCodeNotFound},
+	},
+	"GET /v1/movies/:id": {
+		id: "showMovie", tag: "movies", summary: "Show a movie",
+		query: []*openapi.Parameter{
+			runtimeFormatParam,
+			{Name: "include", In: "query", Description: "Related data to include", Schema: &openapi.Schema{Type: "string", Enum: []any{"credits"}}},
+		},
+		result: map[string]any{"movie": reflect.TypeFor[data.Movie]()},
+		errors: []string{errCodeFailedValidation},
+	},
+	"PUT /v1/movies/:id": {
+		id: "updateMovie", tag: "movies", summary: "Update a movie",
+		description: "The external IDs are left as they are if they are not sent",
+		query:       []*openapi.Parameter{runtimeFormatParam},
+		body:        reflect.TypeFor[movieInput](), model: reflect.TypeFor[data.Movie](),
+		result: map[string]any{"movie": reflect.TypeFor[data.Movie]()},
+		errors: []string{errCodeDuplicateMovie},
+	},
+	"DELETE /v1/movies/:id": {
+		id: "deleteMovie", tag: "movies", summary: "Delete a movie, along with its credits, reviews and poster",
+		result: messageResult,
+	},
+	"POST /v1/movies/:id/credits": {
+		id: "createMovieCredit", tag: "movies", summary: "Credit a person on a movie",
+		body: reflect.TypeFor[creditInput](), model: reflect.TypeFor[data.Credit](),
+		status: http.StatusCreated, result: map[string]any{"credit": reflect.TypeFor[data.Credit]()},
+		idempotent: true,
+	},
+	"DELETE /v1/movies/:id/credits/:credit_id": {
+		id: "deleteMovieCredit", tag: "movies", summary: "Remove a credit from a movie",
+		result: messageResult,
+	},
+	"GET /v1/movies/:id/reviews": {
+		id: "listReviews", tag: "reviews", summary: "List the reviews of a movie",
+		query:  []*openapi.Parameter{pageParam, pageSizeParam, sortParam(reviewSortSafelist, "-created_at")},
+		result: map[string]any{"reviews": reflect.TypeFor[[]*data.Review](), "metadata": reflect.TypeFor[data.Metadata]()},
+		errors: []string{errCodeFailedValidation},
+	},
+	"POST /v1/movies/:id/reviews": {
+		id: "createReview", tag: "reviews", summary: "Review a movie",
+		description: "Each reviewer can only review a movie once",
+		body:        reflect.TypeFor[reviewInput](), model: reflect.TypeFor[data.Review](),
+		status: http.StatusCreated, result: map[string]any{"review": reflect.TypeFor[data.Review]()},
+		idempotent: true,
+	},
+	"GET /v1/movies/:id/poster": {
+		id: "showPoster", tag: "posters", summary: "Show the poster of a movie",
+		query: []*openapi.Parameter{
+			{Name: "size", In: "query", Description: "The original upload or one of the thumbnails", Schema: &openapi.Schema{Type: "string", Enum: enum(posterSizes()), Default: data.PosterSizeOriginal}},
+			{Name: "v", In: "query", Description: "The version from the poster URL, a matching version can be cached for good", Schema: &openapi.Schema{Type: "string"}},
+		},
+		errors: []string{errCodeFailedValidation},
+		raw:    true,
+		responses: map[string]*openapi.Response{
+			"200": {
+				Description: "The poster image",
+				Headers:     responseHeaders([]string{"ETag", "Last-Modified", "Cache-Control"}),
+				Content:     imageContent(),
+			},
+			"304": {Description: "The poster has not changed since the version in If-None-Match"},
+		},
+	},
+	"POST /v1/movies/:id/poster": {
+		id: "uploadPoster", tag: "posters", summary: "Upload or replace the poster of a movie",
+		description: "The thumbnails are generated from the upload",
+		requestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"multipart/form-data": {Schema: &openapi.Schema{
+					Type:       "object",
+					Required:   []string{"poster"},
+					Properties: map[string]*openapi.Schema{"poster": {Type: "string", Format: "binary", Description: posterDescription()}},
+				}},
+			},
+		},
+		status: http.StatusCreated, result: map[string]any{"poster": reflect.TypeFor[data.Poster]()},
+		headers: []string{"Location"},
+		also:    map[int]string{http.StatusOK: "The poster was replaced"},
+		errors:  []string{errCodeBadRequest, errCodeContentTooLarge, errCodeFailedValidation},
+		raw:     true,
+	},
+	"DELETE /v1/movies/:id/poster": {
+		id: "deletePoster", tag: "posters", summary: "Delete the poster of a movie",
+		result: messageResult, raw: true,
+	},
+
+	"GET /v1/genres": {
+		id: "listGenres", tag: "genres", summary: "List the genres that movies can have",
+		result: map[string]any{"genres": reflect.TypeFor[[]*data.Genre]()},
+	},
+	"POST /v1/genres": {
+		id: "createGenre", tag: "genres", summary: "Add a genre",
+		description: "The slug is generated from the name when it is left out",
+		body:        reflect.TypeFor[genreInput](), model: reflect.TypeFor[data.Genre](),
+		status: http.StatusCreated, result: map[string]any{"genre": reflect.TypeFor[data.Genre]()},
+		idempotent: true,
+	},
+
+	"POST /v1/people": {
+		id: "createPerson", tag: "people", summary: "Add a person",
+		body: reflect.TypeFor[personInput](), model: reflect.TypeFor[data.Person](),
+		status: http.StatusCreated, result: map[string]any{"person": reflect.TypeFor[data.Person]()},
+		headers: []string{"Location"}, idempotent: true,
+	},
+	"GET /v1/people/:id": {
+		id: "showPerson", tag: "people", summary: "Show a person",
+		result: map[string]any{"person": reflect.TypeFor[data.Person]()},
+	},
+	"PUT /v1/people/:id": {
+		id: "updatePerson", tag: "people", summary: "Update a person",
+		body: reflect.TypeFor[personInput](), model: reflect.TypeFor[data.Person](),
+		result: map[string]any{"person": reflect.TypeFor[data.Person]()},
+	},
+	"DELETE /v1/people/:id": {
+		id: "deletePerson", tag: "people", summary: "Delete a person, along with their credits",
+		result: messageResult,
+	},
+
+	"GET /v1/watchlists": {
+		id: "listWatchlists", tag: "watchlists", summary: "List the watchlists of an owner",
+		query: []*openapi.Parameter{
+			{Name: "owner", In: "query", Required: true, Description: "The owner of the watchlists", Schema: &openapi.Schema{Type: "string"}},
+			pageParam, pageSizeParam, sortParam(watchlistSortSafelist, "name"),
+		},
+		result: map[string]any{"watchlists": reflect.TypeFor[[]*data.Watchlist](), "metadata": reflect.TypeFor[data.Metadata]()},
+		errors: []string{errCodeFailedValidation},
+	},
+	"POST /v1/watchlists": {
+		id: "createWatchlist", tag: "watchlists", summary: "Create a watchlist",
+		body: reflect.TypeFor[watchlistInput](), model: reflect.TypeFor[data.Watchlist](),
+		status: http.StatusCreated, result: map[string]any{"watchlist": reflect.TypeFor[data.Watchlist]()},
+		headers: []string{"Location"}, idempotent: true,
+	},
+	"GET /v1/watchlists/:id": {
+		id: "showWatchlist", tag: "watchlists", summary: "Show a watchlist and its items",
+		result: map[string]any{"watchlist": reflect.TypeFor[data.Watchlist]()},
+	},
+	"PATCH /v1/watchlists/:id": {
+		id: "renameWatchlist", tag: "watchlists", summary: "Rename a watchlist",
+		body: reflect.TypeFor[watchlistRenameInput](), model: reflect.TypeFor[data.Watchlist](),
+		result: map[string]any{"watchlist": reflect.TypeFor[data.Watchlist]()},
+	},
+	"DELETE /v1/watchlists/:id": {
+		id: "deleteWatchlist", tag: "watchlists", summary: "Delete a watchlist",
+		result: messageResult,
+	},
+	"POST /v1/watchlists/:id/items": {
+		id: "createWatchlistItem", tag: "watchlists", summary: "Add a movie to a watchlist",
+		description: "The movie goes at the end of the list unless a position is given",
+		body:        reflect.TypeFor[watchlistItemInput](), model: reflect.TypeFor[data.WatchlistItem](),
+		status: http.StatusCreated, result: map[string]any{"item": reflect.TypeFor[data.WatchlistItem]()},
+		idempotent: true,
+	},
+	"PATCH /v1/watchlists/:id/items/:item_id": {
+		id: "updateWatchlistItem", tag: "watchlists", summary: "Move a watchlist item or mark it as watched",
+		description: "Fields that are left out keep their current values",
+		body:        reflect.TypeFor[watchlistItemUpdateInput](), model: reflect.TypeFor[data.WatchlistItem](),
+		result: map[string]any{"item": reflect.TypeFor[data.WatchlistItem]()},
+	},
+	"DELETE /v1/watchlists/:id/items/:item_id": {
+		id: "deleteWatchlistItem", tag: "watchlists", summary: "Remove a movie from a watchlist",
+		result: messageResult,
+	},
+
+	"GET /v1/admin/audit-events": {
+		id: "listAuditEvents", tag: "admin", summary: "List the changes recorded in the audit log",
+		description: "Every create, update and delete of a movie is recorded, along with who made it. The newest events come first unless sorted otherwise",
+		query: []*openapi.Parameter{
+			{Name: "resource_type", In: "query", Description: "Only changes to this kind of record", Schema: &openapi.Schema{Type: "string", Enum: enum(data.AuditResourceTypes)}},
+			{Name: "resource_id", In: "query", Description: "Only changes to the record with this ID", Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: openapi.Float(1)}},
+			{Name: "actor", In: "query", Description: "Only changes made by this actor", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "action", In: "query", Description: "Only changes of this kind", Schema: &openapi.Schema{Type: "string", Enum: enum(data.AuditActions)}},
+			{Name: "since", In: "query", Description: "Only changes made at or after this time", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Description: "Only changes made before this time", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			pageParam, pageSizeParam, sortParam(auditSortSafelist, "-occurred_at"),
+		},
+		result: map[string]any{"audit_events": reflect.TypeFor[[]*data.AuditEvent](), "metadata": reflect.TypeFor[data.Metadata]()},
+		errors: []string{errCodeFailedValidation},
+		admin:  true,
+	},
+
+	"GET /v1/events": {
+		id: "listEvents", tag: "events", summary: "Read the change feed",
+		description: "Every create, update and delete of a movie, oldest first. Pass the next_cursor from one page as after= to read the next, " +
+			"an empty page means there is nothing newer yet. Events are kept, so the feed can be replayed from any cursor, or from 0 for all of it",
+		query: []*openapi.Parameter{
+			{Name: "after", In: "query", Description: "Only events after this cursor, the next_cursor of the previous page", Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: openapi.Float(0)}},
+			{Name: "limit", In: "query", Description: "The most events to return", Schema: &openapi.Schema{Type: "integer", Minimum: openapi.Float(1), Maximum: openapi.Float(data.EventsLimitMax), Default: data.EventsLimitDefault}},
+		},
+		result: map[string]any{"events": reflect.TypeFor[[]*data.Event](), "next_cursor": reflect.TypeFor[int64](), "has_more": reflect.TypeFor[bool]()},
+		errors: []string{errCodeFailedValidation},
+	},
+
+	"GET /v1/admin/webhooks": {
+		id: "listWebhooks", tag: "admin", summary: "List the webhooks",
+		query:  []*openapi.Parameter{pageParam, pageSizeParam, sortParam(webhookSortSafelist, "id")},
+		result: map[string]any{"webhooks": reflect.TypeFor[[]*data.Webhook](), "metadata": reflect.TypeFor[data.Metadata]()},
+		errors: []string{errCodeFailedValidation},
+		admin:  true,
+	},
+	"POST /v1/admin/webhooks": {
+		id: "createWebhook", tag: "admin", summary: "Subscribe a URL to the change feed",
+		description: "The events of the chosen types, or all of them when none are given, are POSTed to the URL as they appear at /v1/events. " +
+			"Each delivery is signed with the secret in the Greenlight-Signature header, as t=<unix time>,v1=<hex HMAC-SHA256 of the time, a dot and the body>. " +
+			"A secret is generated when none is given, and this is the only response it is sent back in. " +
+			"Failed deliveries are retried with exponential backoff, and a webhook whose deliveries keep failing is disabled",
+		body: reflect.TypeFor[webhookInput](), model: reflect.TypeFor[data.Webhook](),
+		status: http.StatusCreated, result: map[string]any{"webhook": reflect.TypeFor[data.Webhook](), "secret": reflect.TypeFor[string]()},
+		headers: []string{"Location"},
+		admin:   true,
+	},
+	"GET /v1/admin/webhooks/:id": {
+		id: "showWebhook", tag: "admin", summary: "Show a webhook",
+		result: map[string]any{"webhook": reflect.TypeFor[data.Webhook]()},
+		admin:  true,
+	},
+	"PATCH /v1/admin/webhooks/:id": {
+		id: "updateWebhook", tag: "admin", summary: "Change or enable a webhook",
+		description: "Fields left out keep their values. Enabling a disabled webhook clears its failures, the events it missed while disabled are not sent",
+		body:        reflect.TypeFor[webhookUpdateInput](), model: reflect.TypeFor[data.Webhook](),
+		result: map[string]any{"webhook": reflect.TypeFor[data.Webhook]()},
+		admin:  true,
+	},
+	"DELETE /v1/admin/webhooks/:id": {
+		id: "deleteWebhook", tag: "admin", summary: "Delete a webhook and its delivery log",
+		result: messageResult,
+		admin:  true,
+	},
+	"GET /v1/admin/webhooks/:id/deliveries": {
+		id: "listWebhookDeliveries", tag: "admin", summary: "List a webhook's deliveries and their attempts",
+		query: []*openapi.Parameter{
+			{Name: "status", In: "query", Description: "Only deliveries in this state", Schema: &openapi.Schema{Type: "string", Enum: enum(data.WebhookDeliveryStatuses)}},
+			pageParam, pageSizeParam, sortParam(webhookDeliverySortSafelist, "-created_at"),
+		},
+		result: map[string]any{"deliveries": reflect.TypeFor[[]*data.WebhookDelivery](), "metadata": reflect.TypeFor[data.Metadata]()},
+		errors: []string{errCodeFailedValidation},
+		admin:  true,
+	},
+
+	"GET /v1/movies/stream": {
+		id: "streamMovies", tag: "events", summary: "Stream movie changes as Server-Sent Events",
+		description: "Each change is sent as it happens, with the change feed cursor as the event id, the event type, e.g. movie.updated, " +
+			"as the event name, and the entry from /v1/events as the data. The stream starts with the next change, unless the client " +
+			"reconnects with Last-Event-ID, which browsers do by themselves, when the changes it missed are sent first. " +
+			"A comment line is sent as a heartbeat while nothing is changing",
+		raw: true,
+		query: []*openapi.Parameter{
+			{Name: "genres", In: "query", Description: "Only changes to movies with all of these comma-separated genres", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "last_event_id", In: "query", Description: "The id of the last event received, for clients that cannot set the Last-Event-ID header", Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: openapi.Float(0)}},
+			{Name: "Last-Event-ID", In: "header", Description: "The id of the last event received, to resume the stream from", Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: openapi.Float(0)}},
+		},
+		errors: []string{errCodeFailedValidation},
+		responses: map[string]*openapi.Response{
+			"200": {Description: "The stream of events", Content: map[string]openapi.MediaType{"text/event-stream": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	},
+
+	"GET /v1/graphql": {
+		id: "graphqlQuery", tag: "graphql", summary: "Run a GraphQL query",
+		description: "Queries the movies with GraphQL, e.g. a page of movies along with their credits and reviews in one request. " +
+			"Mutations have to be sent with POST. The schema can be read with introspection",
+		raw: true,
+		query: []*openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Description: "The GraphQL document", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "operationName", In: "query", Description: "The operation to run, when the document has more than one", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "variables", In: "query", Description: "The variables, as a JSON object", Schema: &openapi.Schema{Type: "string"}},
+		},
+		responses: graphqlResponses(),
+	},
+	"POST /v1/graphql": {
+		id: "graphqlRequest", tag: "graphql", summary: "Run a GraphQL query or mutation",
+		description: "The mutations check movies with the same rules as the REST routes, and are recorded in the audit log and the change feed",
+		raw:         true,
+		requestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"application/json": {Schema: &openapi.Schema{
+					Type:     "object",
+					Required: []string{"query"},
+					Properties: map[string]*openapi.Schema{
+						"query":         {Type: "string", Description: "The GraphQL document"},
+						"operationName": {Type: "string", Description: "The operation to run, when the document has more than one"},
+						"variables":     {Type: "object"},
+					},
+				}},
+			},
+		},
+		responses: graphqlResponses(),
+	},
+
+	"GET /v1/openapi.json": {
+		id: "openAPI", tag: "docs", summary: "Show this OpenAPI description",
+		raw: true,
+		responses: map[string]*openapi.Response{
+			"200": {Description: "The OpenAPI description", Content: map[string]openapi.MediaType{"application/json": {Schema: &openapi.Schema{Type: "object"}}}},
+		},
+	},
+	"GET /v1/docs": {
+		id: "apiDocs", tag: "docs", summary: "Browse this OpenAPI description with Swagger UI",
+		raw: true,
+		responses: map[string]*openapi.Response{
+			"200": {Description: "The Swagger UI page", Content: map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}}},
+		},
+	},
+}
+
+// the envelope sent back when a record is deleted
+var messageResult = map[string]any{"message": reflect.TypeFor[string]()}
+
+// graphqlResponses describes the responses from /v1/graphql, which are GraphQL results rather than envelopes
+// requests that are turned away before they run get the errors without any data
+func graphqlResponses() map[string]*openapi.Response {
+	result := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"data": {Type: []string{"object", "null"}, Description: "The result, left out when the request was not run"},
+			"errors": {Type: "array", Items: &openapi.Schema{
+				Type:     "object",
+				Required: []string{"message"},
+				Properties: map[string]*openapi.Schema{
+					"message":   {Type: "string"},
+					"locations": {Type: "array", Items: &openapi.Schema{Type: "object"}},
+					"path":      {Type: "array", Items: &openapi.Schema{}},
+					"extensions": {Type: "object", Description: "The error code, e.g. not_found, failed_validation or duplicate_movie, " +
+						"along with the invalid_params for validation errors and the location of the existing movie for duplicates",
+						Properties: map[string]*openapi.Schema{"code": {Type: "string"}}},
+				},
+			}},
+		},
+	}
+	content := map[string]openapi.MediaType{"application/json": {Schema: result}}
+
+	return map[string]*openapi.Response{
+		"200": {Description: "The request was run, any errors from it are listed alongside the data", Content: content},
+		"400": {Description: "The request could not be read, failed GraphQL validation, or went over the depth or complexity limit", Content: content},
+		"405": {Description: "A mutation was sent with GET", Content: content},
+		"415": {Description: "The body was not JSON", Content: content},
+	}
+}
+
+// externalIDParams describes a query parameter for each external ID source
+func externalIDParams() []*openapi.Parameter {
+	var params []*openapi.Parameter
+	for _, source := range sortedKeys(data.ExternalIDSources) {
+		params = append(params, &openapi.Parameter{
+			Name: source, In: "query", Description: fmt.Sprintf("The movie's %s ID", source),
+			Schema: &openapi.Schema{Type: "string", Pattern: data.ExternalIDSources[source].String()},
+		})
+	}
+
+	return params
+}
+
+// posterSizes lists the original and the thumbnail sizes, in the order the validation message gives them
+func posterSizes() []string {
+	sizes := []string{data.PosterSizeOriginal}
+	for size := range data.PosterThumbnailWidths {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+
+	return sizes
+}
+
+// posterDescription describes the limits ValidatePoster checks an upload against
+func posterDescription() string {
+	return fmt.Sprintf("A %s image, from %dx%d to %dx%d pixels",
+		strings.Join(sortedKeys(data.PosterContentTypes), ", "),
+		data.PosterMinWidth, data.PosterMinHeight, data.PosterMaxWidth, data.PosterMaxHeight)
+}
+
+// imageContent is the content of a response which is one of the poster images
+func imageContent() map[string]openapi.MediaType {
+	content := make(map[string]openapi.MediaType)
+	for _, contentType := range sortedKeys(data.PosterContentTypes) {
+		content[contentType] = openapi.MediaType{Schema: &openapi.Schema{Type: "string", Format: "binary"}}
+	}
+
+	return content
+}
+
+// responseHeaders describes the named response headers
+func responseHeaders(names []string) map[string]*openapi.Header {
+	if len(names) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]*openapi.Header, len(names))
+	for _, name := range names {
+		headers[name] = &openapi.Header{Description: headerDocs[name], Schema: &openapi.Schema{Type: "string"}}
+	}
+
+	return headers
+}
+
+// formatContent describes a body which can be sent in any of the supported formats, all of which share the JSON schema
+func formatContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	content := make(map[string]openapi.MediaType, len(formats))
+	for _, f := range formats {
+		content[f.contentType()] = openapi.MediaType{Schema: schema}
+	}
+
+	return content
+}
+
+// mustMarshalOpenAPI builds the API description for the registered routes and encodes it as JSON
+// it panics if a route has not been documented, or a documented route was not registered
+func mustMarshalOpenAPI(routes []route) []byte {
+	doc := buildOpenAPI(routes)
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+
+	return js
+}
+
+// buildOpenAPI describes the routes, using the entries in operations
+func buildOpenAPI(routes []route) *openapi.Document {
+	g := openapi.NewGenerator()
+	defineSchemas(g)
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   "Greenlight",
+			Version: version,
+			Description: "An API for information about movies. Responses are JSON unless the Accept header asks for XML, YAML or MessagePack, " +
+				"and request bodies can be sent in any of those formats. Errors are written as RFC 7807 problem details when the client " +
+				"accepts application/problem+json.",
+		},
+		Paths: make(map[string]*openapi.PathItem),
+		Components: openapi.Components{
+			Parameters: map[string]*openapi.Parameter{
+				"IdempotencyKey": {
+					Name: "Idempotency-Key", In: "header",
+					Description: "A key unique to this request, so that it can be retried safely. Retries with the same key and body get the first response back",
+					Schema:      &openapi.Schema{Type: "string", MaxLength: openapi.Int(255)},
+				},
+			},
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"AdminToken": {Type: "http", Scheme: "bearer", Description: "The token set with -admin-token"},
+			},
+		},
+	}
+
+	documented := make(map[string]bool)
+	tags := make(map[string]bool)
+
+	for _, rt := range routes {
+		key := rt.method + " " + rt.path
+		op, ok := operations[key]
+		if !ok {
+			panic(fmt.Sprintf("openapi: route %s is not documented", key))
+		}
+		documented[key] = true
+		tags[op.tag] = true
+
+		path, params := openAPIPath(rt.path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(rt.method)] = buildOperation(g, op, params)
+	}
+
+	for key := range operations {
+		if !documented[key] {
+			panic(fmt.Sprintf("openapi: documented route %s is not registered", key))
+		}
+	}
+
+	for _, tag := range sortedKeys(tags) {
+		doc.Tags = append(doc.Tags, openapi.Tag{Name: tag})
+	}
+
+	doc.Components.Schemas = g.Schemas()
+
+	return doc
+}
+
+// openAPIPath converts an httprouter path to an OpenAPI one, returning the path parameters along with it
+// e.g. /v1/movies/:id becomes /v1/movies/{id}
+func openAPIPath(path string) (string, []*openapi.Parameter) {
+	var params []*openapi.Parameter
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+			// every path parameter is a record ID, read with readInt64Param
+			params = append(params, &openapi.Parameter{
+				Name: name, In: "path", Required: true,
+				Schema: &openapi.Schema{Type: "integer", Format: "int64", Minimum: openapi.Float(1)},
+			})
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// buildOperation describes a single route, adding the parameters and error responses that follow from its kind
+func buildOperation(g *openapi.Generator, op operationDoc, pathParams []*openapi.Parameter) *openapi.Operation {
+	operation := &openapi.Operation{
+		OperationID: op.id,
+		Summary:     op.summary,
+		Description: op.description,
+		Tags:        []string{op.tag},
+		Parameters:  append(append([]*openapi.Parameter{}, pathParams...), op.query...),
+		RequestBody: op.requestBody,
+		Responses:   make(map[string]*openapi.Response),
+	}
+
+	codes := append([]string{errCodeServerError}, op.errors...)
+	if len(pathParams) > 0 {
+		codes = append(codes, errCodeNotFound)
+	}
+	if !op.raw {
+		codes = append(codes, errCodeNotAcceptable)
+	}
+
+	if op.body != nil {
+		operation.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  formatContent(g.Input(op.body, op.model)),
+		}
+		codes = append(codes, errCodeBadRequest, errCodeUnsupportedMediaType, errCodeFailedValidation)
+	}
+
+	if op.idempotent {
+		operation.Parameters = append(operation.Parameters, &openapi.Parameter{Ref: "#/components/parameters/IdempotencyKey"})
+		codes = append(codes, errCodeBadRequest, errCodeIdempotencyKeyReused, errCodeIdempotencyInFlight)
+	}
+
+	if op.admin {
+		operation.Security = []map[string][]string{{"AdminToken": {}}}
+		codes = append(codes, errCodeInvalidAdminToken, errCodeAdminDisabled)
+	}
+
+	if op.result != nil {
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		envelope := resultSchema(g, op.result)
+		operation.Responses[fmt.Sprint(status)] = &openapi.Response{
+			Description: http.StatusText(status),
+			Headers:     responseHeaders(op.headers),
+			Content:     formatContent(envelope),
+		}
+		for status, description := range op.also {
+			operation.Responses[fmt.Sprint(status)] = &openapi.Response{
+				Description: description,
+				Headers:     responseHeaders(op.headers),
+				Content:     formatContent(envelope),
+			}
+		}
+	}
+
+	for status, response := range op.responses {
+		operation.Responses[status] = response
+	}
+
+	for status, response := range errorResponses(codes) {
+		operation.Responses[status] = response
+	}
+
+	return operation
+}
+
+// resultSchema describes the envelope of a successful response
+func resultSchema(g *openapi.Generator, result map[string]any) *openapi.Schema {
+	s := &openapi.Schema{Type: "object", Properties: make(map[string]*openapi.Schema)}
+
+	for _, key := range sortedKeys(result) {
+		switch member := result[key].(type) {
+		case reflect.Type:
+			s.Properties[key] = g.Schema(member)
+		case *openapi.Schema:
+			s.Properties[key] = member
+		default:
+			panic(fmt.Sprintf("openapi: unsupported result member %T", member))
+		}
+		s.Required = append(s.Required, key)
+	}
+
+	return s
+}
+
+// errorResponses groups the error codes by status, describing each status with the plain error envelope
+// and the problem details that it is written as when the client asks for them
+func errorResponses(codes []string) map[string]*openapi.Response {
+	byStatus := make(map[int][]string)
+	for _, code := range codes {
+		status := errorDocs[code].status
+		if !slices.Contains(byStatus[status], code) {
+			byStatus[status] = append(byStatus[status], code)
+		}
+	}
+
+	responses := make(map[string]*openapi.Response, len(byStatus))
+	for status, codes := range byStatus {
+		sort.Strings(codes)
+
+		var descriptions, headers []string
+		plain := []*openapi.Schema{openapi.Ref("Error")}
+		for _, code := range codes {
+			descriptions = append(descriptions, errorDocs[code].description)
+			headers = append(headers, errorDocs[code].headers...)
+			if code == errCodeFailedValidation {
+				plain = append(plain, openapi.Ref("ValidationError"))
+			}
+		}
+
+		var schema *openapi.Schema
+		if len(plain) == 1 {
+			schema = plain[0]
+		} else {
+			schema = &openapi.Schema{OneOf: plain}
+		}
+
+		problem := &openapi.Schema{AllOf: []*openapi.Schema{
+			openapi.Ref("Problem"),
+			{Properties: map[string]*openapi.Schema{"code": {Enum: enum(codes)}}},
+		}}
+
+		content := formatContent(schema)
+		content[formatJSON.problemType] = openapi.MediaType{Schema: problem}
+
+		responses[fmt.Sprint(status)] = &openapi.Response{
+			Description: strings.Join(descriptions, ". "),
+			Headers:     responseHeaders(headers),
+			Content:     content,
+		}
+	}
+
+	return responses
+}
+
+// defineSchemas sets up the schemas that reflection cannot work out on its own:
+// the types with their own JSON encoding, the rules ValidateMovie checks in code, and the error envelopes
+func defineSchemas(g *openapi.Generator) {
+	// a runtime is written out as a string in one of the runtime formats, and read from any of them
+	g.DefineComponent(reflect.TypeFor[data.Runtime](), "Runtime", &openapi.Schema{
+		Type:        "string",
+		Description: "A runtime in minutes, written as \"135 mins\" by default, or in the format given with ?runtime_format=",
+		Pattern:     strings.Join(data.RuntimeInputPatterns(), "|"),
+		Examples:    []any{"135 mins", "2h 15m", "PT2H15M"},
+	})
+
+	externalIDs := &openapi.Schema{
+		Type:                 "object",
+		Description:          "The movie's IDs in other databases, each of which belongs to only one movie",
+		Properties:           make(map[string]*openapi.Schema),
+		AdditionalProperties: false,
+	}
+	for source, rx := range data.ExternalIDSources {
+		externalIDs.Properties[source] = &openapi.Schema{Type: "string", Pattern: rx.String()}
+	}
+	g.DefineComponent(reflect.TypeFor[data.ExternalIDs](), "ExternalIDs", externalIDs)
+
+	// the movie limits are checked in code by ValidateMovie, rather than with validate tags
+	g.Input(reflect.TypeFor[movieInput](), reflect.TypeFor[data.Movie]())
+	movie := g.Component(reflect.TypeFor[movieInput]())
+	movie.Required = []string{"title", "year", "runtime", "genres"}
+	movie.Properties["title"] = &openapi.Schema{
+		Type: "string", MinLength: openapi.Int(1), MaxLength: openapi.Int(data.MovieTitleMaxBytes),
+		Description: fmt.Sprintf("At most %d bytes long", data.MovieTitleMaxBytes),
+	}
+	movie.Properties["year"] = &openapi.Schema{
+		Type: "integer", Format: "int32", Minimum: openapi.Float(data.MovieYearMin),
+		Description: "Must not be in the future",
+	}
+	movie.Properties["runtime"] = &openapi.Schema{
+		Description: "A positive number of minutes, or a string in any of the runtime formats",
+		OneOf: []*openapi.Schema{
+			{Type: "integer", Minimum: openapi.Float(1)},
+			openapi.Ref("Runtime"),
+		},
+	}
+	movie.Properties["genres"] = &openapi.Schema{
+		Type: "array", MinItems: openapi.Int(data.MovieGenresMin), MaxItems: openapi.Int(data.MovieGenresMax), UniqueItems: true,
+		Description: "Genres from the vocabulary at /v1/genres, matched ignoring case and punctuation",
+		Items: &openapi.Schema{
+			Type: "string", MinLength: openapi.Int(1), MaxLength: openapi.Int(data.MovieGenreMaxChars), Pattern: `\S`,
+		},
+	}
+
+	// the event payload is stored as JSON, it is the movie as it was after the change
+	event := g.Component(reflect.TypeFor[data.Event]())
+	event.Properties["type"] = &openapi.Schema{Type: "string", Enum: enum(data.EventTypes)}
+	event.Properties["data"] = g.Schema(reflect.TypeFor[data.Movie]())
+
+	// the secret is checked on the webhook, where it is never written out, and every field of an update is optional
+	for _, input := range []reflect.Type{reflect.TypeFor[webhookInput](), reflect.TypeFor[webhookUpdateInput]()} {
+		g.Input(input, reflect.TypeFor[data.Webhook]())
+		g.Component(input).Properties["secret"] = &openapi.Schema{
+			Type: "string", MinLength: openapi.Int(16), MaxLength: openapi.Int(200),
+			Description: "The key for the HMAC-SHA256 signature of each delivery",
+		}
+	}
+	g.Component(reflect.TypeFor[webhookUpdateInput]()).Required = nil
+	g.Component(reflect.TypeFor[data.WebhookDelivery]()).Properties["status"] = &openapi.Schema{Type: "string", Enum: enum(data.WebhookDeliveryStatuses)}
+
+	// the error envelopes, see errors.go
+	g.Add("Error", &openapi.Schema{
+		Type:       "object",
+		Required:   []string{"error"},
+		Properties: map[string]*openapi.Schema{"error": {Type: "string", Description: "A message in the client's language"}},
+	})
+	g.Add("ValidationError", &openapi.Schema{
+		Type:     "object",
+		Required: []string{"error"},
+		Properties: map[string]*openapi.Schema{"error": {
+			Type:                 "object",
+			Description:          "The first problem with each field, keyed by the path to the field, e.g. genres/2",
+			AdditionalProperties: &openapi.Schema{Type: "string"},
+		}},
+	})
+
+	codes := sortedKeys(errorDocs)
+	g.Add("Problem", &openapi.Schema{
+		Type:        "object",
+		Description: "RFC 7807 problem details",
+		Required:    []string{"type", "title", "status", "detail", "instance", "code"},
+		Properties: map[string]*openapi.Schema{
+			"type":           {Type: "string", Format: "uri", Description: fmt.Sprintf("%s followed by the code", problemTypePrefix)},
+			"title":          {Type: "string"},
+			"status":         {Type: "integer"},
+			"detail":         {Type: "string"},
+			"instance":       {Type: "string"},
+			"code":           {Type: "string", Enum: enum(codes)},
+			"invalid_params": {Type: "array", Items: g.Schema(reflect.TypeFor[invalidParam]()), Description: "Every problem with every field, for failed_validation"},
+		},
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/openapi"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		params []string
+	}{
+		{"/v1/movies", "/v1/movies", nil},
+		{"/v1/movies/:id", "/v1/movies/{id}", []string{"id"}},
+		{"/v1/movies/:id/credits/:credit_id", "/v1/movies/{id}/credits/{credit_id}", []string{"id", "credit_id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, params := openAPIPath(tt.path)
+			if got != tt.want {
+				t.Errorf("path = %q, want %q", got, tt.want)
+			}
+
+			if len(params) != len(tt.params) {
+				t.Fatalf("got %d parameters, want %d", len(params), len(tt.params))
+			}
+			for i, p := range params {
+				if p.Name != tt.params[i] || p.In != "path" || !p.Required || p.Schema.Format != "int64" || *p.Schema.Minimum != 1 {
+					t.Errorf("parameter %d = %+v, want a required int64 path parameter named %s", i, p, tt.params[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.routes()
+
+	rr := httptest.NewRecorder()
+	app.openAPIHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// every registered route is described, with its operation keyed by the lowercase method
+	for path, methods := range map[string][]string{
+		"/v1/movies":                          {"get", "post"},
+		"/v1/movies/{id}":                     {"get", "put", "delete"},
+		"/v1/movies/{id}/poster":              {"get", "post", "delete"},
+		"/v1/admin/webhooks/{id}":             {"delete"},
+		"/v1/movies/lookup":                   {"get"},
+		"/v1/movies/{id}/credits/{credit_id}": {"delete"},
+	} {
+		item := doc.Paths[path]
+		if item == nil {
+			t.Errorf("path %s is not described", path)
+			continue
+		}
+		for _, method := range methods {
+			if (*item)[method] == nil {
+				t.Errorf("%s %s is not described", method, path)
+			}
+		}
+	}
+
+	list := (*doc.Paths["/v1/movies"])["get"]
+	var sort *openapi.Parameter
+	for _, p := range list.Parameters {
+		if p.Name == "sort" {
+			sort = p
+		}
+	}
+	if sort == nil || len(sort.Schema.Enum) != len(movieSortSafelist) {
+		t.Fatalf("sort = %+v, want an enum of the sort safelist", sort)
+	}
+	for i, key := range movieSortSafelist {
+		if sort.Schema.Enum[i] != key {
+			t.Errorf("sort enum %d = %v, want %s", i, sort.Schema.Enum[i], key)
+		}
+	}
+
+	// a route with a body takes the validation errors, and an admin route needs the token
+	create := (*doc.Paths["/v1/movies"])["post"]
+	if create.RequestBody == nil || create.Responses["422"] == nil || create.Responses["201"] == nil {
+		t.Errorf("createMovie = %+v, want a body and 201 and 422 responses", create)
+	}
+	if remove := (*doc.Paths["/v1/admin/webhooks/{id}"])["delete"]; len(remove.Security) != 1 || remove.Responses["404"] == nil {
+		t.Errorf("deleteWebhook = %+v, want the admin token and a 404 response", remove)
+	}
+
+	if doc.Components.Schemas["Movie"] == nil || doc.Components.Schemas["MovieInput"] == nil {
+		t.Error("the movie schemas are not in the components")
+	}
+}
+
+func TestBuildOpenAPIRoutesMustMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []route
+	}{
+		{"undocumented route", append(allRoutes(t), route{http.MethodPatch, "/v1/movies/:id"})},
+		{"unregistered route", allRoutes(t)[1:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("buildOpenAPI did not panic")
+				}
+			}()
+			buildOpenAPI(tt.routes)
+		})
+	}
+}
+
+// allRoutes returns a route for every documented operation
+func allRoutes(t *testing.T) []route {
+	t.Helper()
+
+	var routes []route
+	for _, key := range sortedKeys(operations) {
+		var rt route
+		if _, err := fmt.Sscan(key, &rt.method, &rt.path); err != nil {
+			t.Fatal(err)
+		}
+		routes = append(routes, rt)
+	}
+
+	return routes
+}
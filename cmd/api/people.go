@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// personInput is the body of a request to create or update a person
+type personInput struct {
+	Name      string `json:"name"`
+	BirthYear int32  `json:"birth_year"`
+	Biography string `json:"biography"`
+}
+
+// createPersonHandler
+func (app *application) createPersonHandler(w http.ResponseWriter, r *http.Request) {
+	// create struct to hold the person data
+	var input personInput
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	person := &data.Person{
+		Name:      input.Name,
+		BirthYear: input.BirthYear,
+		Biography: input.Biography,
+	}
+
+	// validate the person and return a 422 response if any checks fail
+	v := app.newValidator(r)
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.People.Insert(person)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// let the client know where to find the new person
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/people/%d", person.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"person": person}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showPersonHandler
+func (app *application) showPersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	person, err := app.models.People.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"person": person}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updatePersonHandler
+func (app *application) updatePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// fetch the existing record, send a 404 response if it cannot be found
+	person, err := app.models.People.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input personInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// copy the new values into the person record
+	person.Name = input.Name
+	person.BirthYear = input.BirthYear
+	person.Biography = input.Biography
+
+	v := app.newValidator(r)
+	if data.ValidatePerson(v, person); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.People.Update(person)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"person": person}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deletePersonHandler
+func (app *application) deletePersonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// deleting the person also removes all of their credits
+	err = app.models.People.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.person_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// creditInput is the body of a request to credit a person on a movie
+type creditInput struct {
+	PersonID     int64  `json:"person_id"`
+	Role         string `json:"role"`
+	Character    string `json:"character"`
+	BillingOrder int32  `json:"billing_order"`
+}
+
+// createMovieCreditHandler adds a director, actor or writer credit to a movie
+func (app *application) createMovieCreditHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input creditInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	credit := &data.Credit{
+		MovieID:      movieID,
+		PersonID:     input.PersonID,
+		Role:         input.Role,
+		Character:    input.Character,
+		BillingOrder: input.BillingOrder,
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateCredit(v, credit); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	// make sure the movie exists first, so that a bad movie ID is a 404 rather than a validation error
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.People.InsertCredit(credit)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidReference):
+			v.AddError("person_id", "validation.existing_person")
+			app.FailedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrDuplicateCredit):
+			v.AddError("person_id", "validation.duplicate_credit")
+			app.FailedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"credit": credit}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteMovieCreditHandler removes a single credit from a movie
+func (app *application) deleteMovieCreditHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	creditID, err := app.readInt64Param(r, "credit_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.People.DeleteCredit(movieID, creditID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.credit_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/blob"
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"golang.org/x/image/draw"
+
+	// register the decoders for the other image formats we accept
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+)
+
+// uploadPosterHandler stores the artwork of a movie from a multipart/form-data upload with a "poster" file
+// the content type is sniffed from the file itself, whatever the client claims it to be
+func (app *application) uploadPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	body, err := app.readPosterUpload(w, r)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.contentTooLargeResponse(w, r, app.config.posterMaxBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	v := app.newValidator(r)
+	if body == nil {
+		v.AddError("poster", "validation.required")
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	poster, img := decodePoster(v, id, body)
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	// store the original and the thumbnails before the metadata, so the poster is never visible without its blobs
+	err = app.blobs.Put(r.Context(), poster.Key(data.PosterSizeOriginal), bytes.NewReader(body), poster.Size, poster.ContentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for size, width := range data.PosterThumbnailWidths {
+		thumb, err := encodeThumbnail(img, width, poster.ThumbnailContentType())
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.blobs.Put(r.Context(), poster.Key(size), bytes.NewReader(thumb), int64(len(thumb)), poster.ThumbnailContentType())
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	previous, err := app.models.Posters.Upsert(poster)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the blobs of a replaced poster are no longer referenced, unless the same image was uploaded again
+	status := http.StatusCreated
+	if previous != nil {
+		status = http.StatusOK
+		if previous.Checksum != poster.Checksum {
+			app.deletePosterBlobs(r, previous)
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", poster.URL)
+
+	err = app.writeResponse(w, r, status, envelope{"poster": poster}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showPosterHandler serves the poster image of a movie, or one of its thumbnails with ?size=
+func (app *application) showPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	sizes := []string{data.PosterSizeOriginal}
+	for size := range data.PosterThumbnailWidths {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+	size := app.readString(qs, "size", data.PosterSizeOriginal)
+	v.Check(slices.Contains(sizes, size), "size", "validation.poster_size", strings.Join(sizes, ", "))
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	poster, err := app.models.Posters.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// the versioned URLs handed out with the movie never change content, so they can be cached for good
+	// anything else has to be revalidated, as the poster may be replaced
+	etag := poster.ETag(size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", poster.UpdatedAt.UTC().Format(http.TimeFormat))
+	if poster.IsVersion(qs.Get("v")) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300, must-revalidate")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rc, info, err := app.blobs.Get(r.Context(), poster.Key(size))
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	contentType := poster.ContentType
+	if size != data.PosterSizeOriginal {
+		contentType = poster.ThumbnailContentType()
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	// the status has been sent, so all we can do about a failed copy is log it
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// deletePosterHandler removes the artwork of a movie
+func (app *application) deletePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	poster, err := app.models.Posters.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deletePosterBlobs(r, poster)
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.poster_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readPosterUpload returns the contents of the "poster" file in a multipart/form-data body,
+// or nil if there is no such file. Other form fields are skipped
+func (app *application) readPosterUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	// leave some room on top of the file for the multipart boundaries and part headers
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.posterMaxBytes+64*1024)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New(`body must be multipart/form-data with a "poster" file`)
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		if part.FormName() != "poster" {
+			part.Close()
+			continue
+		}
+
+		// read one byte past the limit, so that a file which is too large can be told apart from one which fits exactly
+		body, err := io.ReadAll(io.LimitReader(part, app.config.posterMaxBytes+1))
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(body)) > app.config.posterMaxBytes {
+			return nil, &http.MaxBytesError{Limit: app.config.posterMaxBytes}
+		}
+
+		if len(body) == 0 {
+			return nil, nil
+		}
+
+		return body, nil
+	}
+}
+
+// decodePoster describes an uploaded poster from the file itself, checks it and decodes the image
+// the dimensions are read from the header before decoding, so that huge images are rejected without
+// allocating the memory to hold them. The image is nil when a check fails
+func decodePoster(v *validator.Validator, id int64, body []byte) (*data.Poster, image.Image) {
+	checksum := sha256.Sum256(body)
+	poster := &data.Poster{
+		MovieID:     id,
+		ContentType: http.DetectContentType(body),
+		Size:        int64(len(body)),
+		Checksum:    hex.EncodeToString(checksum[:]),
+	}
+
+	// an image whose header cannot be read has no dimensions to check, so only that is reported
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err == nil {
+		poster.Width, poster.Height = int32(cfg.Width), int32(cfg.Height)
+	} else if _, ok := data.PosterContentTypes[poster.ContentType]; ok {
+		v.AddError("poster", "validation.image_decode")
+		return poster, nil
+	}
+
+	if data.ValidatePoster(v, poster); !v.Valid() {
+		return poster, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		v.AddError("poster", "validation.image_decode")
+		return poster, nil
+	}
+
+	return poster, img
+}
+
+// deletePosterBlobs removes the original and thumbnails of a poster
+// the metadata is already gone by the time this is called, so failures are logged rather than returned
+func (app *application) deletePosterBlobs(r *http.Request, poster *data.Poster) {
+	for _, key := range poster.Keys() {
+		err := app.blobs.Delete(r.Context(), key)
+		if err != nil {
+			app.logError(r, fmt.Errorf("deleting poster blob %s: %w", key, err))
+		}
+	}
+}
+
+// encodeThumbnail scales an image down to the given width, keeping its aspect ratio, and encodes it
+// images that are already narrower than the width are not scaled up
+func encodeThumbnail(src image.Image, width int, contentType string) ([]byte, error) {
+	bounds := src.Bounds()
+	width = min(width, bounds.Dx())
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	default:
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// etagMatches reports whether an If-None-Match header matches the entity tag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// encodeImage returns a blank image of the given size in one of the formats the standard library can write
+func encodeImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecodePoster(t *testing.T) {
+	pngBody := encodeImage(t, "png", 200, 300)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		width       int32
+		height      int32
+		codes       []string
+	}{
+		{name: "png", body: pngBody, contentType: "image/png", width: 200, height: 300},
+		{name: "jpeg", body: encodeImage(t, "jpeg", 640, 960), contentType: "image/jpeg", width: 640, height: 960},
+		{name: "gif", body: encodeImage(t, "gif", 100, 100), contentType: "image/gif", width: 100, height: 100},
+		{name: "too small", body: encodeImage(t, "png", 99, 300), contentType: "image/png", width: 99, height: 300, codes: []string{"image_min_dimensions"}},
+		{name: "too large", body: encodeImage(t, "png", 8001, 100), contentType: "image/png", width: 8001, height: 100, codes: []string{"image_max_dimensions"}},
+		{name: "not an image", body: []byte("hello, world"), contentType: "text/plain; charset=utf-8", codes: []string{"image_type"}},
+		{name: "pdf", body: []byte("%PDF-1.7\n"), contentType: "application/pdf", codes: []string{"image_type"}},
+		{name: "truncated header", body: pngBody[:20], contentType: "image/png", codes: []string{"image_decode"}},
+		{name: "truncated pixels", body: pngBody[:len(pngBody)-20], contentType: "image/png", width: 200, height: 300, codes: []string{"image_decode"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			poster, img := decodePoster(v, 7, tt.body)
+
+			if poster.MovieID != 7 || poster.Size != int64(len(tt.body)) || len(poster.Checksum) != 64 {
+				t.Errorf("poster = %+v, want movie 7, size %d and a sha256 checksum", poster, len(tt.body))
+			}
+			if poster.ContentType != tt.contentType {
+				t.Errorf("content type = %q, want %q", poster.ContentType, tt.contentType)
+			}
+			if poster.Width != tt.width || poster.Height != tt.height {
+				t.Errorf("dimensions = %dx%d, want %dx%d", poster.Width, poster.Height, tt.width, tt.height)
+			}
+			if !slices.Equal(v.Codes["poster"], tt.codes) {
+				t.Errorf("codes = %v, want %v", v.Codes["poster"], tt.codes)
+			}
+			if (img != nil) != (tt.codes == nil) {
+				t.Errorf("image decoded: %t, want %t", img != nil, tt.codes == nil)
+			}
+		})
+	}
+}
+
+// newPosterUpload returns a multipart/form-data request with a file in each of the named fields
+func newPosterUpload(t *testing.T, files map[string][]byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		part, err := mw.CreateFormFile(name, name+".png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(content)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPut, "/v1/movies/1/poster", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestReadPosterUpload(t *testing.T) {
+	app := &application{}
+	app.config.posterMaxBytes = 1024
+
+	t.Run("poster file", func(t *testing.T) {
+		content := bytes.Repeat([]byte("x"), 1024)
+		r := newPosterUpload(t, map[string][]byte{"title": []byte("ignored"), "poster": content})
+
+		body, err := app.readPosterUpload(httptest.NewRecorder(), r)
+		if err != nil || !bytes.Equal(body, content) {
+			t.Errorf("readPosterUpload() = %d bytes, %v, want the 1024 byte file", len(body), err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		r := newPosterUpload(t, map[string][]byte{"poster": bytes.Repeat([]byte("x"), 1025)})
+
+		_, err := app.readPosterUpload(httptest.NewRecorder(), r)
+		var maxBytesError *http.MaxBytesError
+		if !errors.As(err, &maxBytesError) || maxBytesError.Limit != 1024 {
+			t.Errorf("readPosterUpload() error = %v, want a MaxBytesError of 1024", err)
+		}
+	})
+
+	t.Run("no poster", func(t *testing.T) {
+		r := newPosterUpload(t, map[string][]byte{"image": []byte("x")})
+
+		body, err := app.readPosterUpload(httptest.NewRecorder(), r)
+		if body != nil || err != nil {
+			t.Errorf("readPosterUpload() = %q, %v, want nil, nil", body, err)
+		}
+	})
+
+	t.Run("empty poster", func(t *testing.T) {
+		r := newPosterUpload(t, map[string][]byte{"poster": {}})
+
+		body, err := app.readPosterUpload(httptest.NewRecorder(), r)
+		if body != nil || err != nil {
+			t.Errorf("readPosterUpload() = %q, %v, want nil, nil", body, err)
+		}
+	})
+
+	t.Run("not multipart", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPut, "/v1/movies/1/poster", bytes.NewReader(encodeImage(t, "png", 100, 100)))
+		r.Header.Set("Content-Type", "image/png")
+
+		_, err := app.readPosterUpload(httptest.NewRecorder(), r)
+		if err == nil {
+			t.Error("readPosterUpload() error = nil, want an error")
+		}
+	})
+}
+
+func TestEncodeThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 1500))
+
+	tests := []struct {
+		name        string
+		src         image.Image
+		width       int
+		contentType string
+		wantWidth   int
+		wantHeight  int
+		wantFormat  string
+	}{
+		{"scaled down jpeg", src, 500, "image/jpeg", 500, 750, "jpeg"},
+		{"scaled down png", src, 185, "image/png", 185, 277, "png"},
+		{"never scaled up", image.NewRGBA(image.Rect(0, 0, 120, 180)), 500, "image/jpeg", 120, 180, "jpeg"},
+		{"at least one pixel high", image.NewRGBA(image.Rect(0, 0, 2000, 1)), 185, "image/png", 185, 1, "png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb, err := encodeThumbnail(tt.src, tt.width, tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != tt.wantFormat || cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight {
+				t.Errorf("thumbnail = %s %dx%d, want %s %dx%d", format, cfg.Width, cfg.Height, tt.wantFormat, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
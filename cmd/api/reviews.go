@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// reviewInput is the body of a request to review a movie
+type reviewInput struct {
+	Reviewer string `json:"reviewer"`
+	Score    int32  `json:"score"`
+	Body     string `json:"body"`
+}
+
+// createReviewHandler adds a reviewer's score and write-up to a movie
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input reviewInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	review := &data.Review{
+		MovieID:  movieID,
+		Reviewer: input.Reviewer,
+		Score:    input.Score,
+		Body:     input.Body,
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	// a foreign key violation means the movie does not exist, so that is a 404 rather than a validation error
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidReference):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateReview):
+			v.AddError("reviewer", "validation.duplicate_review")
+			app.FailedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// the values a movie's reviews can be sorted by
+var reviewSortSafelist = []string{"created_at", "score", "-created_at", "-score"}
+
+// listReviewsHandler returns a page of the reviews of a movie, newest first by default
+func (app *application) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = reviewSortSafelist
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	// make sure the movie exists, so that an unknown movie is a 404 rather than an empty list
+	_, err = app.models.Movies.Get(movieID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForMovie(movieID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -2,25 +2,151 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 func (app *application) routes() http.Handler {
-	// initialize a new httpRouter instance
-	router := httprouter.New()
+	// every route registered with either router is listed here, for the API description
+	var registered []route
 
-	// adding custom error handling for certain routes
-	router.NotFound = http.HandlerFunc(app.notFoundResponse)
-	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+	// initialize a new httpRouter instance, with our own responses for unknown routes and methods
+	router := app.newRouteTable(&registered)
 
 	// register the routes
+	// the POST routes are wrapped with idempotent, so that clients can retry them safely with an Idempotency-Key
+	// /v1/healthz is kept for existing monitors, probes should use /live and /ready
 	router.HandlerFunc(http.MethodGet, "/v1/healthz", app.healthCheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/healthz/live", app.livenessHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/healthz/ready", app.readinessHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies", app.idempotent(app.createMovieHandler))
+	router.HandlerFuncWithStatic(http.MethodGet, "/v1/movies/:id", "id", map[string]http.HandlerFunc{
+		"lookup": app.lookupMovieHandler,
+	}, app.showMovieHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/movies/:id", app.updateMovieHandler)
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.idempotent(app.createMovieCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.deleteMovieCreditHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.listReviewsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.idempotent(app.createReviewHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.listGenresHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.idempotent(app.createGenreHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.idempotent(app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.showPersonHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/people/:id", app.updatePersonHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.deletePersonHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/watchlists", app.listWatchlistsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/watchlists", app.idempotent(app.createWatchlistHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/watchlists/:id", app.showWatchlistHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/watchlists/:id", app.renameWatchlistHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/watchlists/:id", app.deleteWatchlistHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/watchlists/:id/items", app.idempotent(app.createWatchlistItemHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/watchlists/:id/items/:item_id", app.updateWatchlistItemHandler)
+	router.HandlerFunc(http.MethodDelete, "/v1/watchlists/:id/items/:item_id", app.deleteWatchlistItemHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/events", app.listEventsHandler)
+
+	// the admin routes need the admin token
+	router.HandlerFunc(http.MethodGet, "/v1/admin/audit-events", app.requireAdmin(app.listAuditEventsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/webhooks", app.requireAdmin(app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/webhooks", app.requireAdmin(app.createWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/webhooks/:id", app.requireAdmin(app.showWebhookHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/webhooks/:id", app.requireAdmin(app.updateWebhookHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/webhooks/:id", app.requireAdmin(app.deleteWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/webhooks/:id/deliveries", app.requireAdmin(app.listWebhookDeliveriesHandler))
+
+	// posters are uploaded as multipart/form-data and served as images, and the API docs are an HTML page,
+	// none of which are formats that negotiateContent knows about, so they get their own router which skips that middleware
+	media := app.newRouteTable(&registered)
+
+	// uploads are not wrapped with idempotent, storing the same poster twice ends up with the same blobs anyway
+	media.HandlerFunc(http.MethodGet, "/v1/movies/:id/poster", app.showPosterHandler)
+	media.HandlerFunc(http.MethodPost, "/v1/movies/:id/poster", app.uploadPosterHandler)
+	media.HandlerFunc(http.MethodDelete, "/v1/movies/:id/poster", app.deletePosterHandler)
+
+	media.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openAPIHandler)
+	media.HandlerFunc(http.MethodGet, "/v1/docs", app.apiDocsHandler)
+
+	// GraphQL is always JSON whatever the Accept header says, and sends its errors in its own format
+	app.graphqlSchema = app.mustBuildGraphQLSchema()
+	media.HandlerFunc(http.MethodGet, "/v1/graphql", app.graphqlHandler)
+	media.HandlerFunc(http.MethodPost, "/v1/graphql", app.graphqlHandler)
+
+	// the movie stream is text/event-stream, which negotiateContent does not know about either
+	// it cannot go on the media router, where /v1/movies/:id/poster would clash with it
+	streams := app.newRouteTable(&registered)
+	streams.HandlerFunc(http.MethodGet, "/v1/movies/stream", app.streamMoviesHandler)
+
+	// the API description is built once every route is known, a route without documentation is a programming error
+	app.openapi = mustMarshalOpenAPI(registered)
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/movies/{id}/poster", media)
+	mux.Handle("/v1/movies/stream", streams)
+	mux.Handle("/v1/openapi.json", media)
+	mux.Handle("/v1/docs", media)
+	mux.Handle("/v1/graphql", media)
+	mux.Handle("/", app.negotiateContent(router))
+
+	// wrap the call to the mux with the localize and recoverPanic middleware
+	// requestID goes outside recoverPanic, so that a panic is logged with the ID the client gets back
+	handler := app.requestID(app.recoverPanic(app.localize(mux)))
+
+	// compression goes outside recoverPanic, so that the error response for a panic is finished off properly
+	if app.config.compression.enabled {
+		handler = app.compress(handler)
+	}
+
+	return handler
+}
+
+// a route is a method and path registered with one of the routers
+type route struct {
+	method string
+	path   string
+}
+
+// a routeTable is an httprouter.Router which keeps a list of the routes registered with it
+type routeTable struct {
+	*httprouter.Router
+	routes *[]route
+}
+
+// newRouteTable returns a router which adds its routes to the list, with our own responses for unknown routes and methods
+func (app *application) newRouteTable(routes *[]route) routeTable {
+	router := httprouter.New()
+	router.NotFound = http.HandlerFunc(app.notFoundResponse)
+	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
+
+	return routeTable{Router: router, routes: routes}
+}
+
+// HandlerFunc registers a handler for the method and path, and records the route
+func (rt routeTable) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	*rt.routes = append(*rt.routes, route{method: method, path: path})
+	rt.Router.HandlerFunc(method, path, handler)
+}
+
+// httprouter does not allow a fixed path segment in the same place as a named parameter,
+// so /v1/movies/lookup cannot be registered next to /v1/movies/:id
+// HandlerFuncWithStatic works around this by sending the requests where the parameter has one of the fixed values
+// to their own handlers, each of which is recorded as a route of its own
+func (rt routeTable) HandlerFuncWithStatic(method, path, param string, handlers map[string]http.HandlerFunc, next http.HandlerFunc) {
+	for _, value := range sortedKeys(handlers) {
+		*rt.routes = append(*rt.routes, route{method: method, path: strings.Replace(path, ":"+param, value, 1)})
+	}
+
+	rt.HandlerFunc(method, path, func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := handlers[httprouter.ParamsFromContext(r.Context()).ByName(param)]; ok {
+			handler(w, r)
+			return
+		}
 
-	// wrap the call to router with the recoverPanic middleware
-	return app.recoverPanic(router)
+		next(w, r)
+	})
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// serve runs the HTTP server until it receives SIGINT or SIGTERM, and then shuts it down gracefully
+// the readiness probe reports "draining" for the drain delay first, so that load balancers stop sending requests
+// before the listener is closed, and in-flight requests then get up to the shutdown timeout to finish
+func (app *application) serve() error {
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+
+	// the movie streams never finish by themselves, so they are ended when shutdown starts rather than holding it up
+	server.RegisterOnShutdown(app.movieChanges.close)
+
+	// the gRPC API is served from the same process on a port of its own, and shut down along with the HTTP server
+	var grpcServer *grpc.Server
+	if app.config.grpcPort > 0 {
+		listener, err := app.listenGRPC()
+		if err != nil {
+			return err
+		}
+
+		grpcServer = app.newGRPCServer()
+
+		go func() {
+			err := app.serveGRPC(grpcServer, listener)
+			if err != nil {
+				app.logger.Error(err.Error(), "port", app.config.grpcPort)
+			}
+		}()
+	}
+
+	// receives the result of the graceful shutdown
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Info("draining server", "signal", s.String(), "drain_delay", app.config.shutdown.drainDelay.String())
+		app.draining.Store(true)
+		time.Sleep(app.config.shutdown.drainDelay)
+
+		app.logger.Info("shutting down server")
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdown.timeout)
+		defer cancel()
+
+		err := server.Shutdown(ctx)
+		if grpcServer != nil {
+			app.stopGRPC(ctx, grpcServer)
+		}
+
+		// the background tasks are stopped once no more requests can come in, and waited for so that the webhook
+		// deliveries in flight are finished and recorded, rather than left for their leases to run out
+		// each delivery has its own timeout, so this does not hang
+		app.logger.Info("stopping background tasks")
+		app.stopBackground()
+		app.wg.Wait()
+
+		shutdownError <- err
+	}()
+
+	// start the server
+	app.logger.Info("Starting server...", "port", server.Addr, "environment", app.config.env)
+
+	// Shutdown makes ListenAndServe return http.ErrServerClosed straight away, which is expected
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server", "addr", server.Addr)
+
+	return nil
+}
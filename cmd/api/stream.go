@@ -0,0 +1,272 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/lib/pq"
+)
+
+// the Postgres channel the movies table notifies on, see migrations/000013_add_movies_notify_trigger.up.sql
+const movieChangesChannel = "movie_changes"
+
+// how long a single write to a stream may take, the streams are held open far longer than the server's WriteTimeout,
+// so they move the deadline on before every write instead
+const streamWriteTimeout = 10 * time.Second
+
+// a changeHub wakes up the open movie streams whenever a movie changes
+// the wake-ups carry nothing, each stream reads what is new from the outbox itself, so one that is slow or was woken
+// up for nothing never misses or repeats an event
+type changeHub struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]bool
+	// closed when the server shuts down, to end the streams
+	done chan struct{}
+	once sync.Once
+}
+
+func newChangeHub() *changeHub {
+	return &changeHub{subs: make(map[chan struct{}]bool), done: make(chan struct{})}
+}
+
+// subscribe returns a channel that receives a value after a change, along with a function to stop receiving them
+// wake-ups that come while one is already waiting are merged into it
+func (h *changeHub) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	h.subs[ch] = true
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// notify wakes up every stream
+func (h *changeHub) notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close ends every stream, it is called when the server starts shutting down as the streams would otherwise keep it waiting
+func (h *changeHub) close() {
+	h.once.Do(func() { close(h.done) })
+}
+
+// listenForMovieChanges LISTENs for the notifications sent by the trigger on the movies table, and passes them on to the hub
+// notifications can be lost while the listener reconnects, so the streams are woken up after every reconnection too
+func (app *application) listenForMovieChanges(hub *changeHub) error {
+	listener := pq.NewListener(app.config.db.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			app.logger.Error(err.Error(), "channel", movieChangesChannel)
+		}
+	})
+
+	err := listener.Listen(movieChangesChannel)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+
+		for {
+			select {
+			case <-hub.done:
+				return
+			// a nil notification means the connection was re-established
+			case <-listener.Notify:
+				hub.notify()
+			// check the connection now and then, as a dead one is otherwise only noticed by the next notification
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+			}
+		}
+	}()
+
+	return nil
+}
+
+// streamMoviesHandler sends movie changes as they happen, as Server-Sent Events
+// each event has the change feed cursor as its id, the event type as its name, and the feed entry as its data
+// a client that reconnects with Last-Event-ID, which browsers do by themselves, gets the changes it missed first,
+// otherwise the stream starts with the next change; ?genres= only sends changes to movies with all of the given genres
+func (app *application) streamMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	genres := app.readCSV(qs, "genres", []string{})
+
+	// EventSource sends the header when it reconnects, the query string is for clients that cannot set headers
+	// without either, the stream starts from the next change
+	lastEventID := int64(-1)
+	if s := cmp.Or(r.Header.Get("Last-Event-ID"), qs.Get("last_event_id")); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		switch {
+		case err != nil:
+			v.AddError("last_event_id", "validation.integer")
+		case id < 0:
+			v.AddError("last_event_id", "validation.min_value", "0")
+		}
+		lastEventID = id
+	}
+	if !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, genre := range genres {
+		wanted[data.Slugify(genre)] = true
+	}
+
+	// subscribe before reading where the feed is up to, so that no change falls in between
+	changes, unsubscribe := app.movieChanges.subscribe()
+	defer unsubscribe()
+
+	if lastEventID < 0 {
+		var err error
+		lastEventID, err = app.models.Events.LastID()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// the server's ReadTimeout would otherwise end the request, and WriteTimeout is dealt with by writeEvents
+	rc := http.NewResponseController(w)
+	err := rc.SetReadDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// stop nginx and similar proxies from holding the events back
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(app.config.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// tell the client how soon to reconnect if the stream drops
+	err = app.writeEvents(rc, w, "retry: 3000\n\n")
+
+	for err == nil {
+		lastEventID, err = app.sendNewEvents(rc, w, lastEventID, wanted)
+		if err != nil {
+			break
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-app.movieChanges.done:
+			return
+		case <-changes:
+		// a comment line keeps proxies from closing the connection as idle, and finds out about clients that have gone
+		case <-heartbeat.C:
+			err = app.writeEvents(rc, w, ": heartbeat\n\n")
+		}
+	}
+
+	// the response has started, so all that can be done is to log the problem and let the client reconnect
+	if r.Context().Err() == nil {
+		app.logError(r, err)
+	}
+}
+
+// sendNewEvents writes the events after the cursor that match the genres, and returns the new cursor
+func (app *application) sendNewEvents(rc *http.ResponseController, w http.ResponseWriter, after int64, genres map[string]bool) (int64, error) {
+	const batch = 100
+
+	for {
+		events, err := app.models.Events.GetAfter(after, batch)
+		if err != nil {
+			return after, err
+		}
+
+		var msg []byte
+		for _, event := range events {
+			after = event.ID
+
+			if !eventHasGenres(event, genres) {
+				continue
+			}
+
+			js, err := json.Marshal(event)
+			if err != nil {
+				return after, err
+			}
+
+			msg = fmt.Appendf(msg, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, js)
+		}
+
+		if len(msg) > 0 {
+			err = app.writeEvents(rc, w, string(msg))
+			if err != nil {
+				return after, err
+			}
+		}
+
+		if len(events) < batch {
+			return after, nil
+		}
+	}
+}
+
+// writeEvents writes to the stream and flushes it, with a write deadline of its own
+func (app *application) writeEvents(rc *http.ResponseController, w http.ResponseWriter, s string) error {
+	err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(w, s)
+	if err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
+
+// eventHasGenres reports whether the movie in the event has every one of the genres, compared by slug
+func eventHasGenres(event *data.Event, genres map[string]bool) bool {
+	if len(genres) == 0 {
+		return true
+	}
+
+	var movie struct {
+		Genres []string `json:"genres"`
+	}
+	if json.Unmarshal(event.Data, &movie) != nil {
+		return false
+	}
+
+	found := 0
+	for _, genre := range movie.Genres {
+		if genres[data.Slugify(genre)] {
+			found++
+		}
+	}
+
+	return found == len(genres)
+}
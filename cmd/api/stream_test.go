@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openStream starts the movie stream handler on a test server and opens a stream with the header, if any
+// the stream is closed when the test ends
+func openStream(t *testing.T, app *application, query, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	if app.movieChanges == nil {
+		app.movieChanges = newChangeHub()
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(app.streamMoviesHandler))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/movies/stream"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		r.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	for header, want := range map[string]string{"Content-Type": "text/event-stream", "Cache-Control": "no-cache", "X-Accel-Buffering": "no"} {
+		if got := res.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	return bufio.NewReader(res.Body)
+}
+
+// readBlock reads the lines up to the next blank line, which ends an event or a comment
+func readBlock(t *testing.T, br *bufio.Reader) string {
+	t.Helper()
+
+	var block strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v, after %q", err, block.String())
+		}
+		if line == "\n" {
+			return block.String()
+		}
+		block.WriteString(line)
+	}
+}
+
+func TestStreamMoviesResume(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		after       int64
+		ids         []string
+	}{
+		{"Last-Event-ID", "", "5", 5, []string{"6", "7", "8"}},
+		{"query string", "?last_event_id=5", "", 5, []string{"6", "7", "8"}},
+		{"header over the query string", "?last_event_id=1", "5", 5, []string{"6", "7", "8"}},
+		{"genres", "?genres=Drama", "5", 5, []string{"6", "8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t, fakeResult{
+				match:   "FROM outbox",
+				columns: eventColumns,
+				rows: [][]driver.Value{
+					eventRow(6, "movie.created", 1, `["Drama"]`),
+					eventRow(7, "movie.updated", 2, `["Comedy"]`),
+					eventRow(8, "movie.deleted", 3, `["Drama", "Romance"]`),
+				},
+			})
+			app.config.streamHeartbeat = time.Hour
+
+			br := openStream(t, app, tt.query, tt.lastEventID)
+
+			if got := readBlock(t, br); got != "retry: 3000\n" {
+				t.Errorf("first block = %q, want the retry interval", got)
+			}
+
+			// the missed events are sent first, each with its cursor as the id and its type as the event name
+			var ids []string
+			for range tt.ids {
+				block := readBlock(t, br)
+				lines := strings.Split(strings.TrimSuffix(block, "\n"), "\n")
+				if len(lines) != 3 || !strings.HasPrefix(lines[0], "id: ") || !strings.HasPrefix(lines[1], "event: movie.") || !strings.HasPrefix(lines[2], "data: {") {
+					t.Fatalf("event = %q, want id, event and data lines", block)
+				}
+				ids = append(ids, strings.TrimPrefix(lines[0], "id: "))
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("ids = %v, want %v", ids, tt.ids)
+			}
+
+			if got := db.argsOf("FROM outbox"); len(got) == 0 || got[0] != tt.after {
+				t.Errorf("read the feed after %v, want %d", got, tt.after)
+			}
+			// the client said where it was up to, so there is no need to look up where the feed is
+			if db.ran("max(id)") {
+				t.Error("the newest event was looked up")
+			}
+		})
+	}
+}
+
+func TestStreamMoviesHeartbeat(t *testing.T) {
+	app, db := newTestApp(t,
+		fakeResult{match: "max(id)", columns: []string{"max"}, rows: [][]driver.Value{{int64(42)}}},
+		fakeResult{match: "FROM outbox", columns: eventColumns},
+	)
+	app.config.streamHeartbeat = 10 * time.Millisecond
+
+	br := openStream(t, app, "", "")
+
+	if got := readBlock(t, br); got != "retry: 3000\n" {
+		t.Errorf("first block = %q, want the retry interval", got)
+	}
+
+	// with nothing new, the idle stream only sends comments
+	for range 2 {
+		if got := readBlock(t, br); got != ": heartbeat\n" {
+			t.Errorf("block = %q, want a heartbeat", got)
+		}
+	}
+
+	// without a Last-Event-ID the stream starts from the newest event
+	if got := db.argsOf("ORDER BY id"); len(got) == 0 || got[0] != int64(42) {
+		t.Errorf("read the feed after %v, want 42", got)
+	}
+
+	// shutting down ends the stream
+	app.movieChanges.close()
+	for {
+		_, err := br.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading the stream: %v, want it to end", err)
+		}
+	}
+}
+
+func TestStreamMoviesValidation(t *testing.T) {
+	tests := []struct {
+		lastEventID string
+		want        string
+	}{
+		{"abc", "must be an integer value"},
+		{"-1", "must be at least 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lastEventID, func(t *testing.T) {
+			app, db := newTestApp(t)
+			app.movieChanges = newChangeHub()
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/movies/stream", nil)
+			r.Header.Set("Last-Event-ID", tt.lastEventID)
+			rr := httptest.NewRecorder()
+			app.streamMoviesHandler(rr, r)
+
+			if got := validationErrors(t, rr)["last_event_id"]; got != tt.want {
+				t.Errorf("last_event_id error = %q, want %q", got, tt.want)
+			}
+			if db.ran("outbox") {
+				t.Error("the change feed was read")
+			}
+		})
+	}
+}
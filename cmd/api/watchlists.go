@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// the values an owner's watchlists can be sorted by
+var watchlistSortSafelist = []string{"name", "created_at", "-name", "-created_at"}
+
+// listWatchlistsHandler returns a page of an owner's watchlists, given with ?owner=
+func (app *application) listWatchlistsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Owner string
+		data.Filters
+	}
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	input.Owner = app.readString(qs, "owner", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafelist = watchlistSortSafelist
+
+	v.Check(input.Owner != "", "owner", "validation.required")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	watchlists, metadata, err := app.models.Watchlists.GetAllForOwner(input.Owner, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"watchlists": watchlists, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// watchlistInput is the body of a request to create a watchlist
+type watchlistInput struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+}
+
+// createWatchlistHandler
+func (app *application) createWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input watchlistInput
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	watchlist := &data.Watchlist{
+		Owner: input.Owner,
+		Name:  input.Name,
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateWatchlist(v, watchlist); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Watchlists.Insert(watchlist)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateWatchlist):
+			v.AddError("name", "validation.duplicate_watchlist")
+			app.FailedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/watchlists/%d", watchlist.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"watchlist": watchlist}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showWatchlistHandler returns a watchlist along with its items in order
+func (app *application) showWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	watchlist, err := app.models.Watchlists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	watchlist.Items, err = app.models.Watchlists.GetItems(watchlist.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"watchlist": watchlist}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// watchlistRenameInput is the body of a request to rename a watchlist
+// only the name can be changed, a watchlist always stays with its owner
+type watchlistRenameInput struct {
+	Name string `json:"name"`
+}
+
+// renameWatchlistHandler
+func (app *application) renameWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	watchlist, err := app.models.Watchlists.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input watchlistRenameInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	watchlist.Name = input.Name
+
+	v := app.newValidator(r)
+	if data.ValidateWatchlist(v, watchlist); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Watchlists.Update(watchlist)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateWatchlist):
+			v.AddError("name", "validation.duplicate_watchlist")
+			app.FailedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"watchlist": watchlist}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteWatchlistHandler
+func (app *application) deleteWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Watchlists.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.watchlist_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// watchlistItemInput is the body of a request to add a movie to a watchlist
+type watchlistItemInput struct {
+	MovieID   int64      `json:"movie_id"`
+	Position  int32      `json:"position"`
+	Watched   bool       `json:"watched"`
+	WatchedAt *time.Time `json:"watched_at"`
+}
+
+// createWatchlistItemHandler adds a movie to a watchlist, at the end unless a position is given
+func (app *application) createWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	watchlistID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input watchlistItemInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	item := &data.WatchlistItem{
+		WatchlistID: watchlistID,
+		MovieID:     input.MovieID,
+		Position:    input.Position,
+		Watched:     input.Watched,
+		WatchedAt:   input.WatchedAt,
+	}
+
+	// a movie marked as watched without a date was watched now
+	if item.Watched && item.WatchedAt == nil {
+		now := time.Now()
+		item.WatchedAt = &now
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateWatchlistItem(v, item); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Watchlists.InsertItem(item)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrInvalidReference):
+			v.AddError("movie_id", "validation.existing_movie")
+			app.FailedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrDuplicateWatchlistItem):
+			v.AddError("movie_id", "validation.duplicate_watchlist_item")
+			app.FailedValidationResponse(w, r, v)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"item": item}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// watchlistItemUpdateInput is the body of a request to change a watchlist item
+// pointers tell us which fields were actually sent
+type watchlistItemUpdateInput struct {
+	Position  *int32     `json:"position"`
+	Watched   *bool      `json:"watched"`
+	WatchedAt *time.Time `json:"watched_at"`
+}
+
+// updateWatchlistItemHandler moves an item to a new position and/or marks it as watched or unwatched
+// fields left out of the request keep their current values
+func (app *application) updateWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	watchlistID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	itemID, err := app.readInt64Param(r, "item_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	item, err := app.models.Watchlists.GetItem(watchlistID, itemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input watchlistItemUpdateInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Position != nil {
+		item.Position = *input.Position
+	}
+
+	if input.Watched != nil {
+		item.Watched = *input.Watched
+		switch {
+		case !item.Watched:
+			item.WatchedAt = nil
+		case item.WatchedAt == nil:
+			now := time.Now()
+			item.WatchedAt = &now
+		}
+	}
+
+	if input.WatchedAt != nil {
+		item.WatchedAt = input.WatchedAt
+	}
+
+	v := app.newValidator(r)
+	v.Check(input.Position == nil || *input.Position >= 1, "position", "validation.min_value", "1")
+	if data.ValidateWatchlistItem(v, item); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Watchlists.UpdateItem(item)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"item": item}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteWatchlistItemHandler removes a movie from a watchlist, the items after it move up one place
+func (app *application) deleteWatchlistItemHandler(w http.ResponseWriter, r *http.Request) {
+	watchlistID, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	itemID, err := app.readInt64Param(r, "item_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Watchlists.DeleteItem(watchlistID, itemID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.watchlist_item_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lockedWatchlist is the locked watchlist row, with the number of items on it
+func lockedWatchlist(count int64) fakeResult {
+	return fakeResult{match: "FOR UPDATE", columns: []string{"count"}, rows: [][]driver.Value{{count}}}
+}
+
+func TestCreateWatchlistItemPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		position int
+		want     int64
+	}{
+		{"end by default", 0, 4},
+		{"first", 1, 1},
+		{"middle", 2, 2},
+		{"one past the end", 4, 4},
+		{"past the end", 10, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t,
+				lockedWatchlist(3),
+				fakeResult{match: "SET position = position + 1"},
+				fakeResult{
+					match:   "INSERT INTO watchlist_items",
+					columns: []string{"id", "added_at", "title"},
+					rows:    [][]driver.Value{{int64(9), time.Now(), "Casablanca"}},
+				},
+			)
+
+			body := `{"movie_id": 1, "position": ` + strconv.Itoa(tt.position) + `}`
+			r := httptest.NewRequest(http.MethodPost, "/v1/watchlists/1/items", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			app.createWatchlistItemHandler(rr, withParams(r, "id", "1"))
+
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusCreated, rr.Body)
+			}
+
+			// the items from the new position onwards move down one place to make room
+			if got := db.argsOf("SET position = position + 1"); !reflect.DeepEqual(got, []driver.Value{int64(1), tt.want}) {
+				t.Errorf("shift args = %v, want [1 %d]", got, tt.want)
+			}
+			if got := db.argsOf("INSERT INTO watchlist_items"); len(got) < 3 || got[2] != tt.want {
+				t.Errorf("insert args = %v, want position %d", got, tt.want)
+			}
+
+			var res struct {
+				Item struct {
+					Position int64 `json:"position"`
+				} `json:"item"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil || res.Item.Position != tt.want {
+				t.Errorf("position = %d, %v, want %d", res.Item.Position, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateWatchlistItemPosition(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int64
+	}{
+		{"down the list", `{"position": 4}`, 4},
+		{"up the list", `{"position": 1}`, 1},
+		{"past the end", `{"position": 9}`, 5},
+		{"left out", `{"watched": true}`, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t,
+				lockedWatchlist(5),
+				fakeResult{match: "SELECT position FROM watchlist_items", columns: []string{"position"}, rows: [][]driver.Value{{int64(2)}}},
+				fakeResult{match: "SET position = CASE"},
+				fakeResult{match: "SET watched"},
+				fakeResult{
+					match:   "WHERE watchlist_items.id = $1",
+					columns: []string{"id", "watchlist_id", "movie_id", "title", "position", "watched", "watched_at", "added_at"},
+					rows:    [][]driver.Value{{int64(7), int64(1), int64(3), "Casablanca", int64(2), false, nil, time.Now()}},
+				},
+			)
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/watchlists/1/items/7", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			app.updateWatchlistItemHandler(rr, withParams(r, "id", "1", "item_id", "7"))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusOK, rr.Body)
+			}
+
+			// the item moves from its current position, and the ones in between close up behind it
+			want := []driver.Value{int64(1), int64(7), int64(2), tt.want}
+			if got := db.argsOf("SET position = CASE"); !reflect.DeepEqual(got, want) {
+				t.Errorf("move args = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdateWatchlistItemValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		key  string
+		want string
+	}{
+		{"position below 1", `{"position": 0}`, "position", "must be at least 1"},
+		{"watched date for an unwatched movie", `{"watched": false, "watched_at": "2024-01-02T15:04:05Z"}`, "watched_at", "must only be set for watched movies"},
+		{"watched date in the future", `{"watched": true, "watched_at": "2999-01-02T15:04:05Z"}`, "watched_at", "must not be in the future"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t, fakeResult{
+				match:   "WHERE watchlist_items.id = $1",
+				columns: []string{"id", "watchlist_id", "movie_id", "title", "position", "watched", "watched_at", "added_at"},
+				rows:    [][]driver.Value{{int64(7), int64(1), int64(3), "Casablanca", int64(2), false, nil, time.Now()}},
+			})
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/watchlists/1/items/7", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			app.updateWatchlistItemHandler(rr, withParams(r, "id", "1", "item_id", "7"))
+
+			if got := validationErrors(t, rr)[tt.key]; got != tt.want {
+				t.Errorf("%s error = %q, want %q", tt.key, got, tt.want)
+			}
+			if db.ran("UPDATE watchlist_items") {
+				t.Error("the item was updated")
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// the values the webhooks and their deliveries can be sorted by
+var (
+	webhookSortSafelist         = []string{"id", "created_at", "-id", "-created_at"}
+	webhookDeliverySortSafelist = []string{"created_at", "-created_at"}
+)
+
+// listWebhooksHandler returns a page of the webhooks
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = webhookSortSafelist
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	webhooks, metadata, err := app.models.Webhooks.GetAll(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"webhooks": webhooks, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// webhookInput is the body of a request to create a webhook
+// a secret is generated when none is given, and no event types subscribes to all of them
+type webhookInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+// createWebhookHandler subscribes a URL to the change feed
+// the secret is only sent back in this response, so it is not wrapped with idempotent, which would keep a copy of it
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input webhookInput
+
+	err := app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := &data.Webhook{
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		Secret:     input.Secret,
+	}
+
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = []string{}
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = newWebhookSecret()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Webhooks.Insert(webhook)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/webhooks/%d", webhook.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"webhook": webhook, "secret": webhook.Secret}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showWebhookHandler returns a webhook, without its secret
+func (app *application) showWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	webhook, err := app.models.Webhooks.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// webhookUpdateInput is the body of a request to change a webhook
+// pointers tell us which fields were actually sent
+type webhookUpdateInput struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     *string  `json:"secret"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// updateWebhookHandler changes a webhook's URL, event types or secret, and enables or disables it
+// enabling a webhook that was disabled for failing gives it a fresh start, the deliveries it missed are not sent
+// fields left out of the request keep their current values
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	webhook, err := app.models.Webhooks.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input webhookUpdateInput
+
+	err = app.readRequest(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.EventTypes != nil {
+		webhook.EventTypes = input.EventTypes
+	}
+	if input.Secret != nil {
+		webhook.Secret = *input.Secret
+	}
+
+	if input.Enabled != nil && *input.Enabled != webhook.Enabled {
+		webhook.Enabled = *input.Enabled
+		if webhook.Enabled {
+			webhook.FailingSince = nil
+			webhook.DisabledAt = nil
+			webhook.DisabledReason = ""
+		} else {
+			now := time.Now()
+			webhook.DisabledAt = &now
+			webhook.DisabledReason = "disabled by an admin"
+		}
+	}
+
+	v := app.newValidator(r)
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	err = app.models.Webhooks.Update(webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteWebhookHandler removes a webhook along with its delivery log
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Webhooks.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": app.contextGetLocalizer(r).T("message.webhook_deleted")}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listWebhookDeliveriesHandler returns a page of a webhook's deliveries with the log of their attempts, newest first
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParams(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	v := app.newValidator(r)
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = webhookDeliverySortSafelist
+
+	if input.Status != "" {
+		v.Check(validator.PermittedValues(input.Status, data.WebhookDeliveryStatuses...), "status", "validation.one_of", strings.Join(data.WebhookDeliveryStatuses, ", "))
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.FailedValidationResponse(w, r, v)
+		return
+	}
+
+	// a missing webhook is a 404, rather than an empty list
+	_, err = app.models.Webhooks.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.GetDeliveries(id, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// newWebhookSecret returns a random secret for signing a webhook's deliveries
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
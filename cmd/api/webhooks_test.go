@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// withParams adds httprouter parameters to a request, as the router does
+func withParams(r *http.Request, params ...string) *http.Request {
+	var ps httprouter.Params
+	for i := 0; i+1 < len(params); i += 2 {
+		ps = append(ps, httprouter.Param{Key: params[i], Value: params[i+1]})
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, ps))
+}
+
+// validationErrors decodes the field errors from a 422 response
+func validationErrors(t *testing.T, rr *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusUnprocessableEntity, rr.Body)
+	}
+
+	var body struct {
+		Error map[string]string `json:"error"`
+	}
+	err := json.Unmarshal(rr.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body.Error
+}
+
+var webhookColumns = []string{"id", "created_at", "url", "event_types", "secret", "enabled", "failing_since", "disabled_at", "disabled_reason", "version"}
+
+// storedWebhook is the row of an existing webhook with a valid secret
+var storedWebhook = fakeResult{
+	match:   "FROM webhooks",
+	columns: webhookColumns,
+	rows: [][]driver.Value{{
+		int64(1), time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), "https://example.com/hook", []byte("{movie.created}"),
+		"0123456789abcdef0123456789abcdef", true, nil, nil, "", int64(1),
+	}},
+}
+
+func TestCreateWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"too short", "a", "must be at least 16 characters long"},
+		{"one short", strings.Repeat("s", 15), "must be at least 16 characters long"},
+		{"too long", strings.Repeat("s", 201), "must not be more than 200 characters long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+
+			body := `{"url": "https://example.com/hook", "secret": "` + tt.secret + `"}`
+			r := httptest.NewRequest(http.MethodPost, "/v1/admin/webhooks", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			app.createWebhookHandler(rr, r)
+
+			if got := validationErrors(t, rr)["secret"]; got != tt.want {
+				t.Errorf("secret error = %q, want %q", got, tt.want)
+			}
+			if db.ran("INSERT INTO webhooks") {
+				t.Error("the webhook was stored")
+			}
+		})
+	}
+
+	// a secret that is left out is generated, and is long enough
+	app, _ := newTestApp(t, fakeResult{
+		match:   "INSERT INTO webhooks",
+		columns: []string{"id", "created_at", "enabled", "version"},
+		rows:    [][]driver.Value{{int64(1), time.Now(), true, int64(1)}},
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/admin/webhooks", strings.NewReader(`{"url": "https://example.com/hook"}`))
+	rr := httptest.NewRecorder()
+	app.createWebhookHandler(rr, r)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d, body: %s", rr.Code, http.StatusCreated, rr.Body)
+	}
+
+	var created struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil || len(created.Secret) < 16 {
+		t.Errorf("generated secret = %q, %v, want one of at least 16 characters", created.Secret, err)
+	}
+}
+
+func TestUpdateWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", `{"secret": ""}`, "must be provided"},
+		{"too short", `{"secret": "a"}`, "must be at least 16 characters long"},
+		{"too long", `{"secret": "` + strings.Repeat("s", 201) + `"}`, "must not be more than 200 characters long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t, storedWebhook)
+
+			r := httptest.NewRequest(http.MethodPatch, "/v1/admin/webhooks/1", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			app.updateWebhookHandler(rr, withParams(r, "id", "1"))
+
+			if got := validationErrors(t, rr)["secret"]; got != tt.want {
+				t.Errorf("secret error = %q, want %q", got, tt.want)
+			}
+			if db.ran("UPDATE webhooks") {
+				t.Error("the webhook was updated")
+			}
+		})
+	}
+}
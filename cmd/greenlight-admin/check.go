@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// a violation is a stored movie field which breaks one of the validation rules
+type violation struct {
+	MovieID int64  `json:"movie_id"`
+	Title   string `json:"title"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// runCheck runs every stored movie through ValidateMovie
+// the database constraints only cover the runtime, the year range and the number of genres,
+// so rows written before the other rules were added, or straight into the database, can break them,
+// e.g. overlong titles, duplicate or unknown genres and malformed external IDs
+func runCheck(a *admin, args []string) error {
+	fs := a.newFlagSet("check")
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return &usageError{"check takes no arguments"}
+	}
+
+	genres, err := a.models.Genres.Vocabulary()
+	if err != nil {
+		return err
+	}
+
+	checked := 0
+	violations := []violation{}
+
+	err = a.eachMovie("", []string{}, func(movie *data.Movie) error {
+		checked++
+
+		v := validator.New()
+		data.ValidateMovie(v, movie, genres)
+		for _, field := range sortedKeys(v.FieldErrors) {
+			for _, message := range v.FieldErrors[field] {
+				violations = append(violations, violation{MovieID: movie.ID, Title: movie.Title, Field: field, Message: message})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if a.output == "json" {
+		err = a.printJSON(map[string]any{"checked": checked, "violations": violations})
+		if err != nil {
+			return err
+		}
+	} else {
+		if len(violations) > 0 {
+			rows := make([][]string, len(violations))
+			for i, v := range violations {
+				rows[i] = []string{strconv.FormatInt(v.MovieID, 10), v.Title, v.Field, v.Message}
+			}
+			a.printTable([]string{"ID", "TITLE", "FIELD", "PROBLEM"}, rows)
+			fmt.Fprintln(a.stdout)
+		}
+		fmt.Fprintf(a.stdout, "checked %d movies, %d problems\n", checked, len(violations))
+	}
+
+	if len(violations) > 0 {
+		return errProblemsFound
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// greenlight-admin manages the movie catalogue directly in the database, through the same models as the API
+//
+//	greenlight-admin [-db-dsn DSN] [-output table|json] <command> [flags] [args]
+//
+// run greenlight-admin -help for the list of commands
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	// the pq driver registers itself with the sql package
+	_ "github.com/lib/pq"
+)
+
+// an admin holds what every command needs: the models, where to write and in which format
+type admin struct {
+	models data.Models
+	stdout io.Writer
+	stderr io.Writer
+	output string
+}
+
+// a command is a single subcommand, e.g. "list" or "import"
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(a *admin, args []string) error
+}
+
+// the commands in the order they are listed in the help
+var commands = []command{
+	{"list", "[-title T] [-genres a,b] [-sort S] [-page N] [-page-size N]", "list movies", runList},
+	{"show", "ID", "show a movie", runShow},
+	{"create", "-title T -year Y -runtime R -genres a,b [-external-id source=id]", "add a movie", runCreate},
+	{"update", "[-title T] [-year Y] [-runtime R] [-genres a,b] [-external-id source=id] ID", "change a movie", runUpdate},
+	{"delete", "ID", "delete a movie", runDelete},
+	{"import", "[-dry-run] FILE", "add the movies in a .json or .csv file", runImport},
+	{"export", "[-title T] [-genres a,b] FILE|-", "write movies to a .json or .csv file", runExport},
+	{"purge", "movies [-title T] [-genres a,b] [-yes] | idempotency-keys", "delete movies matching a filter, or expired idempotency keys", runPurge},
+	{"check", "", "report stored movies which break the validation rules", runCheck},
+}
+
+// errProblemsFound is returned by commands which ran but found something wrong, e.g. invalid rows,
+// they have already reported the details, so only the exit status is left to set
+var errProblemsFound = errors.New("problems found")
+
+// errBadFlags is returned when a command's flags cannot be parsed, the flag package has already printed why
+var errBadFlags = errors.New("bad flags")
+
+// a usageError is a command line which cannot be run, it exits with status 2
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run parses the global flags, connects to the database and runs the command, returning the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("greenlight-admin", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs) }
+
+	// the DSN is shared with the API, so export GREENLIGHT_DB_DSN works for both
+	dsn := fs.String("db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "Postgres DSN (default $GREENLIGHT_DB_DSN)")
+	output := fs.String("output", "table", "output format (table|json)")
+
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "-output must be table or json, not %q\n", *output)
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		usage(fs)
+		return 2
+	}
+
+	cmd, ok := findCommand(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", fs.Arg(0))
+		usage(fs)
+		return 2
+	}
+
+	if *dsn == "" {
+		fmt.Fprintln(stderr, "-db-dsn must be provided, e.g. with GREENLIGHT_DB_DSN")
+		return 2
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	// changes made with the tool are recorded in the audit log under the name of the user running it
+	models := data.NewModels(db)
+	models.Movies = models.Movies.WithAudit(data.AuditInfo{Actor: auditActor()})
+
+	a := &admin{
+		models: models,
+		stdout: stdout,
+		stderr: stderr,
+		output: *output,
+	}
+
+	err = cmd.run(a, fs.Args()[1:])
+	if err != nil {
+		var usageErr *usageError
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errProblemsFound):
+			return 1
+		case errors.Is(err, errBadFlags):
+			return 2
+		case errors.As(err, &usageErr):
+			fmt.Fprintf(stderr, "%s\nusage: greenlight-admin %s %s\n", err, cmd.name, cmd.args)
+			return 2
+		default:
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// auditActor names the user running the tool, e.g. "greenlight-admin:alice"
+func auditActor() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	return "greenlight-admin:" + name
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return command{}, false
+}
+
+// usage prints the global flags and the commands
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "usage: greenlight-admin [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w, "\nflags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+		if cmd.args != "" {
+			fmt.Fprintf(w, "  %-8s   %s %s\n", "", cmd.name, cmd.args)
+		}
+	}
+}
+
+// newFlagSet returns the flag set for a command, errors are returned rather than exiting
+func (a *admin) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	return fs
+}
+
+// parseFlags parses a command's flags, turning a parse error into errBadFlags
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errBadFlags
+	}
+
+	return err
+}
+
+// openDB connects to the database, failing if it cannot be reached within 5 seconds
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
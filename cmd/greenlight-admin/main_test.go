@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		status int
+		stderr string
+	}{
+		{"help", []string{"-help"}, 0, "commands:"},
+		{"no command", nil, 2, "usage: greenlight-admin"},
+		{"unknown command", []string{"frobnicate"}, 2, `unknown command "frobnicate"`},
+		{"bad output", []string{"-output", "xml", "list"}, 2, `-output must be table or json, not "xml"`},
+		{"unknown flag", []string{"-verbose", "list"}, 2, "flag provided but not defined: -verbose"},
+		{"no DSN", []string{"list"}, 2, "-db-dsn must be provided"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the DSN is read from the environment, which must not leak into the test
+			t.Setenv("GREENLIGHT_DB_DSN", "")
+
+			var stdout, stderr bytes.Buffer
+			if got := run(tt.args, &stdout, &stderr); got != tt.status {
+				t.Errorf("run() = %d, want %d", got, tt.status)
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
+
+// the commands below fail on their flags and arguments before they reach the models, so the admin has none
+func TestCommandUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(*admin, []string) error
+		args []string
+		want string
+	}{
+		{"show without an ID", runShow, nil, "expected a single movie ID"},
+		{"show with two IDs", runShow, []string{"1", "2"}, "expected a single movie ID"},
+		{"show with a bad ID", runShow, []string{"abc"}, `invalid movie ID "abc"`},
+		{"delete with a zero ID", runDelete, []string{"0"}, `invalid movie ID "0"`},
+		{"update without an ID", runUpdate, []string{"-title", "Casablanca"}, "expected a single movie ID"},
+		{"create with an argument", runCreate, []string{"-title", "Casablanca", "extra"}, "create takes no arguments"},
+		{"purge nothing", runPurge, nil, "expected what to purge: movies or idempotency-keys"},
+		{"purge the wrong thing", runPurge, []string{"people"}, `cannot purge "people", expected movies or idempotency-keys`},
+		{"purge movies without a filter", runPurge, []string{"movies", "-yes"}, "purge movies needs -title or -genres"},
+		{"purge movies with an argument", runPurge, []string{"movies", "-title", "x", "extra"}, "purge movies takes no arguments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			a := &admin{stdout: &bytes.Buffer{}, stderr: &stderr, output: "table"}
+
+			err := tt.run(a, tt.args)
+
+			var usageErr *usageError
+			if !errors.As(err, &usageErr) || usageErr.message != tt.want {
+				t.Errorf("error = %v, want usage error %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want error
+	}{
+		{"no flags", nil, nil},
+		{"help", []string{"-h"}, flag.ErrHelp},
+		{"unknown flag", []string{"-colour"}, errBadFlags},
+		{"bad value", []string{"-year", "nineteen"}, errBadFlags},
+		{"bad external ID", []string{"-external-id", "tt0111161"}, errBadFlags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			a := &admin{stderr: &stderr}
+			fs := a.newFlagSet("create")
+			registerMovieFlags(fs)
+
+			if err := parseFlags(fs, tt.args); !errors.Is(err, tt.want) {
+				t.Errorf("parseFlags() = %v, want %v", err, tt.want)
+			}
+			// the flag package explains what was wrong on stderr, rather than exiting
+			if tt.want != nil && stderr.Len() == 0 {
+				t.Error("nothing was written to stderr")
+			}
+		})
+	}
+}
+
+func TestMovieFlagsApply(t *testing.T) {
+	existing := func() *data.Movie {
+		return &data.Movie{
+			Title:       "Casablanca",
+			Year:        1942,
+			Runtime:     102,
+			Genres:      []string{"Drama"},
+			ExternalIDs: data.ExternalIDs{"imdb": "tt0034583"},
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want *data.Movie
+		err  bool
+	}{
+		{
+			name: "nothing set",
+			want: existing(),
+		},
+		{
+			name: "only the flags given are changed",
+			args: []string{"-year", "1943", "-genres", " Drama, Romance ,,"},
+			want: &data.Movie{
+				Title: "Casablanca", Year: 1943, Runtime: 102, Genres: []string{"Drama", "Romance"},
+				ExternalIDs: data.ExternalIDs{"imdb": "tt0034583"},
+			},
+		},
+		{
+			name: "runtime formats and external IDs",
+			args: []string{"-runtime", "1h 42m", "-external-id", "tmdb=289", "-external-id", "imdb=tt0034584"},
+			want: &data.Movie{
+				Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"Drama"},
+				ExternalIDs: data.ExternalIDs{"imdb": "tt0034584", "tmdb": "289"},
+			},
+		},
+		{
+			name: "empty genres",
+			args: []string{"-genres", ""},
+			want: &data.Movie{
+				Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{},
+				ExternalIDs: data.ExternalIDs{"imdb": "tt0034583"},
+			},
+		},
+		{
+			name: "bad runtime",
+			args: []string{"-runtime", "a while"},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("update", flag.ContinueOnError)
+			in := registerMovieFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			movie := existing()
+			err := in.apply(fs, movie)
+			if tt.err {
+				if err == nil {
+					t.Error("apply() did not return an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(movie, tt.want) {
+				t.Errorf("movie = %+v, want %+v", movie, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+		err    bool
+	}{
+		{"movies.json", "", "json", false},
+		{"MOVIES.CSV", "", "csv", false},
+		{"movies.txt", "csv", "csv", false},
+		{"-", "json", "json", false},
+		{"-", "", "", true},
+		{"movies.xml", "", "", true},
+		{"movies.json", "yaml", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.format, func(t *testing.T) {
+			got, err := fileFormat(tt.name, tt.format)
+			if got != tt.want || (err != nil) != tt.err {
+				t.Errorf("fileFormat() = %q, %v, want %q, error %t", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
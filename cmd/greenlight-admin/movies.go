@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// the sort values list accepts, the same as GET /v1/movies
+var movieSortSafelist = []string{
+	"id", "title", "year", "runtime", "average_rating", "rating_count",
+	"-id", "-title", "-year", "-runtime", "-average_rating", "-rating_count",
+}
+
+func runList(a *admin, args []string) error {
+	fs := a.newFlagSet("list")
+	title := fs.String("title", "", "only movies matching the title")
+	genres := fs.String("genres", "", "only movies with all of these comma-separated genres")
+	filters := data.Filters{SortSafelist: movieSortSafelist}
+	fs.StringVar(&filters.Sort, "sort", "id", "sort by this field, with a - prefix for descending order")
+	fs.IntVar(&filters.Page, "page", 1, "page number")
+	fs.IntVar(&filters.PageSize, "page-size", 20, "movies per page")
+
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return &usageError{"list takes no arguments"}
+	}
+
+	v := validator.New()
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return validationError(v)
+	}
+
+	movies, metadata, err := a.models.Movies.GetAll(*title, splitList(*genres), filters)
+	if err != nil {
+		return err
+	}
+
+	if a.output == "json" {
+		return a.printJSON(map[string]any{"movies": movies, "metadata": metadata})
+	}
+
+	a.printMovies(movies)
+	if metadata.TotalRecords > 0 {
+		fmt.Fprintf(a.stdout, "\npage %d of %d, %d movies\n", metadata.CurrentPage, metadata.LastPage, metadata.TotalRecords)
+	}
+	return nil
+}
+
+func runShow(a *admin, args []string) error {
+	fs := a.newFlagSet("show")
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	movie, err := a.getMovie(id)
+	if err != nil {
+		return err
+	}
+
+	return a.printMovie(movie)
+}
+
+func runCreate(a *admin, args []string) error {
+	fs := a.newFlagSet("create")
+	in := registerMovieFlags(fs)
+
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return &usageError{"create takes no arguments"}
+	}
+
+	movie := &data.Movie{ExternalIDs: data.ExternalIDs{}}
+	err = in.apply(fs, movie)
+	if err != nil {
+		return err
+	}
+
+	err = a.validateMovie(movie)
+	if err != nil {
+		return err
+	}
+
+	err = a.models.Movies.Insert(movie)
+	if err != nil {
+		return err
+	}
+
+	return a.printMovie(movie)
+}
+
+func runUpdate(a *admin, args []string) error {
+	fs := a.newFlagSet("update")
+	in := registerMovieFlags(fs)
+
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	movie, err := a.getMovie(id)
+	if err != nil {
+		return err
+	}
+
+	// only the fields given on the command line are changed
+	err = in.apply(fs, movie)
+	if err != nil {
+		return err
+	}
+
+	err = a.validateMovie(movie)
+	if err != nil {
+		return err
+	}
+
+	err = a.models.Movies.Update(movie)
+	if err != nil {
+		return err
+	}
+
+	return a.printMovie(movie)
+}
+
+func runDelete(a *admin, args []string) error {
+	fs := a.newFlagSet("delete")
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(fs)
+	if err != nil {
+		return err
+	}
+
+	err = a.removeMovie(id)
+	if err != nil {
+		return err
+	}
+
+	if a.output == "json" {
+		return a.printJSON(map[string]any{"deleted": id})
+	}
+
+	fmt.Fprintf(a.stdout, "deleted movie %d\n", id)
+	return nil
+}
+
+// removeMovie deletes a movie, warning that its poster images are left in the blob store, which this tool does not manage
+func (a *admin) removeMovie(id int64) error {
+	poster, err := a.models.Posters.Get(id)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+
+	err = a.models.Movies.Delete(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return fmt.Errorf("movie %d not found", id)
+		}
+		return err
+	}
+
+	if poster != nil {
+		fmt.Fprintf(a.stderr, "warning: the poster images of movie %d were left in the blob store\n", id)
+	}
+
+	return nil
+}
+
+// getMovie fetches a movie, with a readable error when there is none
+func (a *admin) getMovie(id int64) (*data.Movie, error) {
+	movie, err := a.models.Movies.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, fmt.Errorf("movie %d not found", id)
+		}
+		return nil, err
+	}
+
+	return movie, nil
+}
+
+// validateMovie runs the API's checks on a movie, including the genre vocabulary, and canonicalises its genres
+func (a *admin) validateMovie(movie *data.Movie) error {
+	genres, err := a.models.Genres.Vocabulary()
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie, genres); !v.Valid() {
+		return validationError(v)
+	}
+
+	movie.Genres = genres.Canonical(movie.Genres)
+	return nil
+}
+
+// movieFlags holds the flags that set a movie's fields, shared by create and update
+type movieFlags struct {
+	title       string
+	year        int
+	runtime     string
+	genres      string
+	externalIDs data.ExternalIDs
+}
+
+func registerMovieFlags(fs *flag.FlagSet) *movieFlags {
+	in := &movieFlags{externalIDs: data.ExternalIDs{}}
+
+	fs.StringVar(&in.title, "title", "", "title")
+	fs.IntVar(&in.year, "year", 0, "release year")
+	fs.StringVar(&in.runtime, "runtime", "", `runtime, e.g. "135", "2h 15m" or "PT2H15M"`)
+	fs.StringVar(&in.genres, "genres", "", "comma-separated genres")
+	fs.Func("external-id", "an ID in another database as source=id, e.g. imdb=tt0111161, can be repeated", func(value string) error {
+		source, id, ok := strings.Cut(value, "=")
+		if !ok || source == "" {
+			return errors.New("must be source=id")
+		}
+		in.externalIDs[source] = id
+		return nil
+	})
+
+	return in
+}
+
+// apply copies the flags that were set on the command line into the movie
+func (in *movieFlags) apply(fs *flag.FlagSet, movie *data.Movie) error {
+	var err error
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "title":
+			movie.Title = in.title
+		case "year":
+			movie.Year = int32(in.year)
+		case "runtime":
+			var runtime data.Runtime
+			runtime, err = data.ParseRuntime(in.runtime)
+			movie.Runtime = runtime
+		case "genres":
+			movie.Genres = splitList(in.genres)
+		case "external-id":
+			if movie.ExternalIDs == nil {
+				movie.ExternalIDs = data.ExternalIDs{}
+			}
+			for source, id := range in.externalIDs {
+				movie.ExternalIDs[source] = id
+			}
+		}
+	})
+
+	return err
+}
+
+// parseID reads the single movie ID argument
+func parseID(fs *flag.FlagSet) (int64, error) {
+	if fs.NArg() != 1 {
+		return 0, &usageError{"expected a single movie ID"}
+	}
+
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || id < 1 {
+		return 0, &usageError{fmt.Sprintf("invalid movie ID %q", fs.Arg(0))}
+	}
+
+	return id, nil
+}
+
+// splitList splits a comma-separated flag value, an empty value is an empty list
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// validationError turns the validator's messages into a single error, one field per line
+func validationError(v *validator.Validator) error {
+	var b strings.Builder
+	b.WriteString("failed validation:")
+	for _, field := range sortedKeys(v.FieldErrors) {
+		for _, message := range v.FieldErrors[field] {
+			fmt.Fprintf(&b, "\n  %s: %s", field, message)
+		}
+	}
+
+	return errors.New(b.String())
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// printJSON writes v as indented JSON, in the same shape as the API's responses
+func (a *admin) printJSON(v any) error {
+	js, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(a.stdout, string(js))
+	return err
+}
+
+// printTable writes the rows in aligned columns under the header
+func (a *admin) printTable(header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// printMovies writes one movie per row
+func (a *admin) printMovies(movies []*data.Movie) {
+	if len(movies) == 0 {
+		fmt.Fprintln(a.stdout, "no movies found")
+		return
+	}
+
+	rows := make([][]string, len(movies))
+	for i, movie := range movies {
+		rows[i] = []string{
+			strconv.FormatInt(movie.ID, 10),
+			movie.Title,
+			strconv.Itoa(int(movie.Year)),
+			movie.Runtime.Format(data.RuntimeFormatMinutes),
+			strings.Join(movie.Genres, ", "),
+			fmt.Sprintf("%.1f (%d)", movie.AverageRating, movie.RatingCount),
+			strconv.Itoa(int(movie.Version)),
+		}
+	}
+
+	a.printTable([]string{"ID", "TITLE", "YEAR", "RUNTIME", "GENRES", "RATING", "VERSION"}, rows)
+}
+
+// printMovie writes a single movie, one field per line in the table output
+func (a *admin) printMovie(movie *data.Movie) error {
+	if a.output == "json" {
+		return a.printJSON(map[string]any{"movie": movie})
+	}
+
+	rows := [][]string{
+		{"id", strconv.FormatInt(movie.ID, 10)},
+		{"title", movie.Title},
+		{"year", strconv.Itoa(int(movie.Year))},
+		{"runtime", movie.Runtime.Format(data.RuntimeFormatMinutes)},
+		{"genres", strings.Join(movie.Genres, ", ")},
+		{"rating", fmt.Sprintf("%.1f from %d reviews", movie.AverageRating, movie.RatingCount)},
+		{"version", strconv.Itoa(int(movie.Version))},
+	}
+	for _, source := range sortedKeys(movie.ExternalIDs) {
+		rows = append(rows, []string{source, movie.ExternalIDs[source]})
+	}
+	if movie.Poster != nil {
+		rows = append(rows, []string{"poster", fmt.Sprintf("%dx%d %s", movie.Poster.Width, movie.Poster.Height, movie.Poster.ContentType)})
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+// sortedKeys returns the keys of a map in order, so that output is stable
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// runPurge deletes records in bulk, either the movies matching a filter or the expired idempotency keys
+func runPurge(a *admin, args []string) error {
+	if len(args) == 0 {
+		return &usageError{"expected what to purge: movies or idempotency-keys"}
+	}
+
+	switch args[0] {
+	case "movies":
+		return a.purgeMovies(args[1:])
+	case "idempotency-keys":
+		return a.purgeIdempotencyKeys(args[1:])
+	default:
+		return &usageError{fmt.Sprintf("cannot purge %q, expected movies or idempotency-keys", args[0])}
+	}
+}
+
+// purgeMovies deletes the movies matching the filter
+// without -yes it only lists them, and a filter is required so that the whole catalogue is never removed by accident
+func (a *admin) purgeMovies(args []string) error {
+	fs := a.newFlagSet("purge movies")
+	title := fs.String("title", "", "only movies matching the title")
+	genres := fs.String("genres", "", "only movies with all of these comma-separated genres")
+	yes := fs.Bool("yes", false, "delete the movies rather than only listing them")
+
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return &usageError{"purge movies takes no arguments"}
+	}
+	if *title == "" && *genres == "" {
+		return &usageError{"purge movies needs -title or -genres"}
+	}
+
+	// collect the movies first, as deleting while paging would skip over some of them
+	var movies []*data.Movie
+	err = a.eachMovie(*title, splitList(*genres), func(movie *data.Movie) error {
+		movies = append(movies, movie)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		if a.output == "json" {
+			return a.printJSON(map[string]any{"movies": movies, "deleted": false})
+		}
+		a.printMovies(movies)
+		if len(movies) > 0 {
+			fmt.Fprintf(a.stdout, "\nrun again with -yes to delete these %d movies\n", len(movies))
+		}
+		return nil
+	}
+
+	ids := []int64{}
+	for _, movie := range movies {
+		err = a.removeMovie(movie.ID)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, movie.ID)
+	}
+
+	if a.output == "json" {
+		return a.printJSON(map[string]any{"deleted": ids})
+	}
+
+	fmt.Fprintf(a.stdout, "deleted %d movies\n", len(ids))
+	return nil
+}
+
+// purgeIdempotencyKeys removes the keys that can no longer be replayed, which the API also does every hour
+func (a *admin) purgeIdempotencyKeys(args []string) error {
+	fs := a.newFlagSet("purge idempotency-keys")
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return &usageError{"purge idempotency-keys takes no arguments"}
+	}
+
+	n, err := a.models.Idempotency.DeleteExpired()
+	if err != nil {
+		return err
+	}
+
+	if a.output == "json" {
+		return a.printJSON(map[string]any{"deleted": n})
+	}
+
+	fmt.Fprintf(a.stdout, "deleted %d expired idempotency keys\n", n)
+	return nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// genresSeparator separates the genres inside the genres column of a CSV file
+const genresSeparator = "|"
+
+// a movieRecord is a movie read from an import file, it has the fields of the API's movie input
+// the JSON files written by export can be read back, as the extra fields are ignored
+type movieRecord struct {
+	// Line is the position of the record in the file, for reporting problems
+	Line        int              `json:"-"`
+	Title       string           `json:"title"`
+	Year        int32            `json:"year"`
+	Runtime     data.Runtime     `json:"runtime"`
+	Genres      []string         `json:"genres"`
+	ExternalIDs data.ExternalIDs `json:"external_ids"`
+}
+
+// an importProblem is a record that could not be imported
+type importProblem struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// an importResult sums up an import
+type importResult struct {
+	Created    int             `json:"created"`
+	Duplicates int             `json:"duplicates"`
+	Invalid    int             `json:"invalid"`
+	DryRun     bool            `json:"dry_run,omitempty"`
+	Problems   []importProblem `json:"problems"`
+}
+
+func runImport(a *admin, args []string) error {
+	fs := a.newFlagSet("import")
+	dryRun := fs.Bool("dry-run", false, "validate the file without adding any movies")
+	format := fs.String("format", "", "file format (json|csv), by default taken from the file extension")
+
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return &usageError{"expected a single file"}
+	}
+
+	name := fs.Arg(0)
+	f, err := fileFormat(name, *format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var records []*movieRecord
+	result := importResult{DryRun: *dryRun, Problems: []importProblem{}}
+
+	switch f {
+	case "json":
+		records, err = readJSONRecords(file)
+	case "csv":
+		records, result.Problems, err = readCSVRecords(file)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	result.Invalid = len(result.Problems)
+
+	genres, err := a.models.Genres.Vocabulary()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		movie := &data.Movie{
+			Title:       record.Title,
+			Year:        record.Year,
+			Runtime:     record.Runtime,
+			Genres:      record.Genres,
+			ExternalIDs: record.ExternalIDs,
+		}
+		if movie.ExternalIDs == nil {
+			movie.ExternalIDs = data.ExternalIDs{}
+		}
+
+		v := validator.New()
+		if data.ValidateMovie(v, movie, genres); !v.Valid() {
+			result.Invalid++
+			for _, field := range sortedKeys(v.FieldErrors) {
+				for _, message := range v.FieldErrors[field] {
+					result.Problems = append(result.Problems, importProblem{Line: record.Line, Field: field, Message: message})
+				}
+			}
+			continue
+		}
+
+		movie.Genres = genres.Canonical(movie.Genres)
+
+		if *dryRun {
+			result.Created++
+			continue
+		}
+
+		// movies which are already in the catalogue are skipped, so that an import can be run again after fixing the file
+		err = a.models.Movies.Insert(movie)
+		var duplicateErr *data.DuplicateMovieError
+		switch {
+		case errors.As(err, &duplicateErr):
+			result.Duplicates++
+			fmt.Fprintf(a.stderr, "line %d: skipped, %s is already movie %d\n", record.Line, duplicateErr.Field, duplicateErr.ID)
+		case err != nil:
+			return fmt.Errorf("line %d: %w", record.Line, err)
+		default:
+			result.Created++
+		}
+	}
+
+	if a.output == "json" {
+		err = a.printJSON(result)
+		if err != nil {
+			return err
+		}
+	} else {
+		a.printImportResult(result)
+	}
+
+	if result.Invalid > 0 {
+		return errProblemsFound
+	}
+	return nil
+}
+
+func (a *admin) printImportResult(result importResult) {
+	if len(result.Problems) > 0 {
+		rows := make([][]string, len(result.Problems))
+		for i, p := range result.Problems {
+			rows[i] = []string{strconv.Itoa(p.Line), p.Field, p.Message}
+		}
+		a.printTable([]string{"LINE", "FIELD", "PROBLEM"}, rows)
+		fmt.Fprintln(a.stdout)
+	}
+
+	verb := "created"
+	if result.DryRun {
+		verb = "would create"
+	}
+	fmt.Fprintf(a.stdout, "%s %d, skipped %d duplicates, %d invalid\n", verb, result.Created, result.Duplicates, result.Invalid)
+}
+
+// readJSONRecords reads a JSON array of movies
+func readJSONRecords(r io.Reader) ([]*movieRecord, error) {
+	var records []*movieRecord
+	err := json.NewDecoder(r).Decode(&records)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, record := range records {
+		record.Line = i + 1
+	}
+
+	return records, nil
+}
+
+// readCSVRecords reads movies from a CSV file with a header row
+// the columns are title, year, runtime, genres (separated by "|") and one per external ID source, e.g. imdb,
+// any other column, such as the id written by export, is ignored
+// rows that cannot be parsed are returned as problems rather than stopping the import
+func readCSVRecords(r io.Reader) ([]*movieRecord, []importProblem, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing %q column", name)
+		}
+	}
+
+	var records []*movieRecord
+	problems := []importProblem{}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		record := &movieRecord{
+			Line:        line,
+			Title:       row[columns["title"]],
+			Genres:      splitGenres(row[columns["genres"]]),
+			ExternalIDs: data.ExternalIDs{},
+		}
+
+		year, err := strconv.ParseInt(strings.TrimSpace(row[columns["year"]]), 10, 32)
+		if err != nil {
+			problems = append(problems, importProblem{Line: line, Field: "year", Message: "must be an integer"})
+			continue
+		}
+		record.Year = int32(year)
+
+		record.Runtime, err = data.ParseRuntime(strings.TrimSpace(row[columns["runtime"]]))
+		if err != nil {
+			problems = append(problems, importProblem{Line: line, Field: "runtime", Message: err.Error()})
+			continue
+		}
+
+		for source := range data.ExternalIDSources {
+			if i, ok := columns[source]; ok && row[i] != "" {
+				record.ExternalIDs[source] = row[i]
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, problems, nil
+}
+
+func runExport(a *admin, args []string) error {
+	fs := a.newFlagSet("export")
+	title := fs.String("title", "", "only movies matching the title")
+	genres := fs.String("genres", "", "only movies with all of these comma-separated genres")
+	format := fs.String("format", "", "file format (json|csv), by default taken from the file extension, json for -")
+
+	err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return &usageError{`expected a single file, or - for standard output`}
+	}
+
+	name := fs.Arg(0)
+	if name == "-" && *format == "" {
+		*format = "json"
+	}
+	f, err := fileFormat(name, *format)
+	if err != nil {
+		return err
+	}
+
+	var movies []*data.Movie
+	err = a.eachMovie(*title, splitList(*genres), func(movie *data.Movie) error {
+		movies = append(movies, movie)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w := a.stdout
+	if name != "-" {
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch f {
+	case "json":
+		err = writeJSONMovies(w, movies)
+	case "csv":
+		err = writeCSVMovies(w, movies)
+	}
+	if err != nil {
+		return err
+	}
+
+	if name != "-" {
+		fmt.Fprintf(a.stderr, "exported %d movies to %s\n", len(movies), name)
+	}
+	return nil
+}
+
+// writeJSONMovies writes the movies as a JSON array, which import can read back
+func writeJSONMovies(w io.Writer, movies []*data.Movie) error {
+	if movies == nil {
+		movies = []*data.Movie{}
+	}
+
+	js, err := json.MarshalIndent(movies, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(js))
+	return err
+}
+
+// writeCSVMovies writes the movies with a header row, in the columns import reads
+func writeCSVMovies(w io.Writer, movies []*data.Movie) error {
+	sources := sortedKeys(data.ExternalIDSources)
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(append([]string{"id", "title", "year", "runtime", "genres"}, sources...))
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		row := []string{
+			strconv.FormatInt(movie.ID, 10),
+			movie.Title,
+			strconv.Itoa(int(movie.Year)),
+			strconv.Itoa(int(movie.Runtime)),
+			strings.Join(movie.Genres, genresSeparator),
+		}
+		for _, source := range sources {
+			row = append(row, movie.ExternalIDs[source])
+		}
+
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// eachMovie calls fn with every movie matching the filter, in ID order
+func (a *admin) eachMovie(title string, genres []string, fn func(*data.Movie) error) error {
+	filters := data.Filters{Page: 1, PageSize: 100, Sort: "id", SortSafelist: []string{"id"}}
+
+	for {
+		movies, metadata, err := a.models.Movies.GetAll(title, genres, filters)
+		if err != nil {
+			return err
+		}
+
+		for _, movie := range movies {
+			err = fn(movie)
+			if err != nil {
+				return err
+			}
+		}
+
+		if filters.Page >= metadata.LastPage {
+			return nil
+		}
+		filters.Page++
+	}
+}
+
+// fileFormat returns the format of a file, either the one asked for or the one its extension implies
+func fileFormat(name, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	}
+
+	switch format {
+	case "json", "csv":
+		return format, nil
+	default:
+		return "", &usageError{fmt.Sprintf("cannot tell the format of %q, use a .json or .csv file or set -format", name)}
+	}
+}
+
+// splitGenres splits the genres column of a CSV file
+func splitGenres(value string) []string {
+	genres := []string{}
+	for _, genre := range strings.Split(value, genresSeparator) {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			genres = append(genres, genre)
+		}
+	}
+
+	return genres
+}
@@ -0,0 +1,46 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// custom errors returned by every BlobStore implementation
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Info describes a stored blob
+type Info struct {
+	Key         string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// a BlobStore keeps binary objects, such as poster images, under slash-separated keys like "posters/1/original.jpg"
+// Get returns ErrNotFound for a missing key, Delete treats a missing key as already deleted
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey makes sure a key is a clean, relative path, so that it cannot escape the store's root or bucket
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
+
+// a Pinger is a BlobStore that can check it is reachable, used by the readiness probe
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,142 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"posters/1/original.jpg", true},
+		{"a", true},
+		{"a..b/c", true},
+		{"", false},
+		{"/posters/1/original.jpg", false},
+		{"..", false},
+		{"../etc/passwd", false},
+		{"posters/../../etc/passwd", false},
+		{"posters//1", false},
+		{"posters/1/", false},
+		{"./posters", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := checkKey(tt.key)
+			if valid := err == nil; valid != tt.valid {
+				t.Errorf("checkKey(%q) = %v, want valid: %t", tt.key, err, tt.valid)
+			}
+		})
+	}
+}
+
+// testStore runs the behaviour every BlobStore must have against a store
+func testStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	key := "posters/1/abc/original.png"
+	content := []byte("not really a png")
+
+	t.Run("get missing", func(t *testing.T) {
+		_, _, err := store.Get(ctx, "posters/missing/original.png")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("put and get", func(t *testing.T) {
+		err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/png")
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+
+		rc, info, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer rc.Close()
+
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("reading blob: %v", err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("blob = %q, want %q", got, content)
+		}
+		if info.Key != key || info.Size != int64(len(content)) || info.ContentType != "image/png" {
+			t.Errorf("info = %+v, want key %s, size %d and type image/png", info, key, len(content))
+		}
+	})
+
+	t.Run("put replaces", func(t *testing.T) {
+		replacement := []byte("a newer image")
+		err := store.Put(ctx, key, bytes.NewReader(replacement), -1, "image/png")
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+
+		rc, _, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer rc.Close()
+
+		got, _ := io.ReadAll(rc)
+		if !bytes.Equal(got, replacement) {
+			t.Errorf("blob = %q, want %q", got, replacement)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := store.Delete(ctx, key)
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+
+		_, _, err = store.Get(ctx, key)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+		}
+
+		// deleting again is not an error
+		err = store.Delete(ctx, key)
+		if err != nil {
+			t.Errorf("second Delete() error = %v", err)
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "/abs", "../escape"} {
+			err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain")
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+			}
+			_, _, err = store.Get(ctx, key)
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Get(%q) error = %v, want ErrInvalidKey", key, err)
+			}
+			err = store.Delete(ctx, key)
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Delete(%q) error = %v, want ErrInvalidKey", key, err)
+			}
+		}
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		pinger, ok := store.(Pinger)
+		if !ok {
+			t.Skip("store does not implement Pinger")
+		}
+
+		err := pinger.Ping(ctx)
+		if err != nil {
+			t.Errorf("Ping() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// an FSStore keeps blobs as files under a directory on the local filesystem
+// the content type is not stored, it is worked out from the key's extension when the blob is read back
+type FSStore struct {
+	root string
+}
+
+// NewFSStore returns a store rooted at dir, creating the directory if it does not exist
+func NewFSStore(dir string) (*FSStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FSStore{root: dir}, nil
+}
+
+// Put writes the blob to a temporary file first and renames it into place,
+// so that readers never see a partly written blob
+func (s *FSStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	name := s.path(key)
+
+	err := os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	// removing the temporary file fails harmlessly once it has been renamed
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Get opens a blob for reading, the caller must close it
+func (s *FSStore) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Info{}, err
+	}
+
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil, Info{}, ErrNotFound
+		default:
+			return nil, Info{}, err
+		}
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Info{}, err
+	}
+
+	info := Info{
+		Key:         key,
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+	}
+
+	return f, info, nil
+}
+
+// Delete removes a blob
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path turns a key into a filename under the store's root
+func (s *FSStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Ping checks that the root directory is still there
+func (s *FSStore) Ping(ctx context.Context) error {
+	stat, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package blob
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFSStore(t *testing.T) {
+	store, err := NewFSStore(filepath.Join(t.TempDir(), "uploads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, store)
+}
+
+func TestFSStoreLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFSStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Put(context.Background(), "posters/1/original.jpg", strings.NewReader("jpeg"), 4, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "posters", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "original.jpg" {
+		t.Errorf("files = %v, want only original.jpg", entries)
+	}
+}
+
+func TestFSStorePingMissingRoot(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewFSStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Remove(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Ping(context.Background()); err == nil {
+		t.Error("Ping() error = nil, want an error once the root is gone")
+	}
+}
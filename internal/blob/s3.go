@@ -0,0 +1,122 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config holds the settings for connecting to an S3-compatible service, such as AWS S3 or MinIO
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// an S3Store keeps blobs as objects in an S3 bucket
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Store connects to the service and makes sure the bucket exists, creating it if needed
+func NewS3Store(ctx context.Context, cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		err = client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &S3Store{client: client, bucket: cfg.Bucket}, nil
+}
+
+// Put uploads a blob, a size of -1 streams an upload of unknown length
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Get opens a blob for reading, the caller must close it
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Info{}, err
+	}
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, Info{}, translateS3Error(err)
+	}
+
+	// GetObject is lazy, so Stat is where a missing object is reported
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, Info{}, translateS3Error(err)
+	}
+
+	info := Info{
+		Key:         key,
+		ContentType: stat.ContentType,
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+	}
+
+	return obj, info, nil
+}
+
+// Delete removes a blob, S3 does not report an error for a missing object
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// translateS3Error turns the service's "no such key" response into ErrNotFound
+func translateS3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// Ping checks that the service can be reached and the bucket still exists
+func (s *S3Store) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucket)
+	}
+
+	return nil
+}
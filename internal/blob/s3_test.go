@@ -0,0 +1,40 @@
+package blob
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestS3Store runs against a MinIO server when GREENLIGHT_TEST_S3_ENDPOINT is set, e.g. to localhost:9000 for the
+// one in docker-compose.yml, whose credentials are the defaults. A bucket of its own is created for the run
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("GREENLIGHT_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("set GREENLIGHT_TEST_S3_ENDPOINT to the host:port of a MinIO server to run the S3 tests")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	store, err := NewS3Store(ctx, S3Config{
+		Endpoint:  endpoint,
+		Bucket:    fmt.Sprintf("greenlight-test-%d", time.Now().UnixNano()),
+		Region:    "us-east-1",
+		AccessKey: cmp.Or(os.Getenv("GREENLIGHT_TEST_S3_ACCESS_KEY"), "greenlight"),
+		SecretKey: cmp.Or(os.Getenv("GREENLIGHT_TEST_S3_SECRET_KEY"), "gr33nLight-minio"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// every blob has been deleted by the end of testStore, so the bucket is empty
+		store.client.RemoveBucket(context.Background(), store.bucket)
+	})
+
+	testStore(t, store)
+}
@@ -0,0 +1,208 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// the actions recorded in the audit log
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// the kinds of record that changes are audited for
+const (
+	AuditResourceMovie = "movie"
+)
+
+// AuditResourceTypes lists every resource type, for validating filters
+var AuditResourceTypes = []string{AuditResourceMovie}
+
+// AuditActions lists every action, for validating filters
+var AuditActions = []string{AuditActionCreate, AuditActionUpdate, AuditActionDelete}
+
+// AuditActorSystem is recorded as the actor of changes made without an AuditInfo
+const AuditActorSystem = "system"
+
+// AuditInfo says who made a change and from where, for the audit log
+type AuditInfo struct {
+	Actor     string
+	ClientIP  string
+	RequestID string
+}
+
+// an AuditEvent is a single change to a record
+// Changes maps each field that changed to its old and new values
+type AuditEvent struct {
+	ID           int64                  `json:"id"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+	Action       string                 `json:"action"`
+	ResourceType string                 `json:"resource_type"`
+	ResourceID   int64                  `json:"resource_id"`
+	Actor        string                 `json:"actor"`
+	ClientIP     string                 `json:"client_ip,omitempty"`
+	RequestID    string                 `json:"request_id,omitempty"`
+	Changes      map[string]AuditChange `json:"changes"`
+}
+
+// an AuditChange is the value of a field before and after a change
+// creates only have a new value and deletes only an old one
+type AuditChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// AuditFilter narrows down the events returned by AuditModel.GetAll, zero values match everything
+type AuditFilter struct {
+	ResourceType string
+	ResourceID   int64
+	Actor        string
+	Action       string
+	// events from Since, up to but not including Until
+	Since time.Time
+	Until time.Time
+}
+
+// ValidateAuditFilter checks the filter values are ones that can match
+func ValidateAuditFilter(v *validator.Validator, f AuditFilter) {
+	if f.ResourceType != "" {
+		v.Check(validator.PermittedValues(f.ResourceType, AuditResourceTypes...), "resource_type", "validation.one_of", strings.Join(AuditResourceTypes, ", "))
+	}
+	if f.Action != "" {
+		v.Check(validator.PermittedValues(f.Action, AuditActions...), "action", "validation.one_of", strings.Join(AuditActions, ", "))
+	}
+	v.Check(f.ResourceID >= 0, "resource_id", "validation.positive_integer")
+	if !f.Since.IsZero() && !f.Until.IsZero() {
+		v.Check(f.Until.After(f.Since), "until", "validation.gt_field", "since")
+	}
+}
+
+// an execer is either the connection pool or a transaction, for writes that are made with both
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// recordAudit writes an event for a change, it is called with the transaction that makes the change
+// so that the change and its event are committed or rolled back together
+// before is nil for a create and after is nil for a delete
+func recordAudit(tx execer, info *AuditInfo, action, resourceType string, resourceID int64, before, after map[string]any) error {
+	if info == nil {
+		info = &AuditInfo{Actor: AuditActorSystem}
+	}
+
+	changes, err := json.Marshal(auditChanges(before, after))
+	if err != nil {
+		return err
+	}
+
+	query := `
+			INSERT INTO audit_events (action, resource_type, resource_id, actor, client_ip, request_id, changes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.Exec(query, action, resourceType, resourceID, info.Actor, info.ClientIP, info.RequestID, changes)
+	return err
+}
+
+// auditChanges compares the fields of a record before and after a change, keeping the ones that differ
+func auditChanges(before, after map[string]any) map[string]AuditChange {
+	changes := make(map[string]AuditChange)
+
+	for field, old := range before {
+		if value, ok := after[field]; !ok || !reflect.DeepEqual(old, value) {
+			changes[field] = AuditChange{Old: old, New: after[field]}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = AuditChange{New: value}
+		}
+	}
+
+	return changes
+}
+
+// an AuditModel reads the audit log, the events are written by the models whose changes they record
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// GetAll returns a page of the events matching the filter
+func (m AuditModel) GetAll(filter AuditFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	// the sort column comes from the safelist, so it is safe to interpolate
+	// ties are broken by id in the same direction, so that events from the same second keep their order
+	query := fmt.Sprintf(`
+			SELECT count(*) OVER(), id, occurred_at, action, resource_type, resource_id, actor, client_ip, request_id, changes
+			FROM audit_events
+			WHERE ($1 = '' OR resource_type = $1)
+			AND ($2 = 0 OR resource_id = $2)
+			AND ($3 = '' OR actor = $3)
+			AND ($4 = '' OR action = $4)
+			AND ($5::timestamptz IS NULL OR occurred_at >= $5)
+			AND ($6::timestamptz IS NULL OR occurred_at < $6)
+			ORDER BY %s %s, id %[2]s
+			LIMIT $7 OFFSET $8`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		filter.ResourceType, filter.ResourceID, filter.Actor, filter.Action,
+		nullTime(filter.Since), nullTime(filter.Until),
+		filters.limit(), filters.offset(),
+	}
+
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		var event AuditEvent
+		var changes []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&event.ID,
+			&event.OccurredAt,
+			&event.Action,
+			&event.ResourceType,
+			&event.ResourceID,
+			&event.Actor,
+			&event.ClientIP,
+			&event.RequestID,
+			&changes,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(changes, &event.Changes)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
+
+// nullTime is NULL for the zero time, so that an unset bound matches every event
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,94 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+func TestValidateAuditFilter(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   map[string][]string
+	}{
+		{"empty", AuditFilter{}, map[string][]string{}},
+		{"every field", AuditFilter{ResourceType: "movie", ResourceID: 1, Actor: "alice", Action: "update", Since: since, Until: since.Add(time.Hour)}, map[string][]string{}},
+		{"unknown resource type", AuditFilter{ResourceType: "review"}, map[string][]string{"resource_type": {"one_of"}}},
+		{"unknown action", AuditFilter{Action: "UPDATE"}, map[string][]string{"action": {"one_of"}}},
+		{"negative resource id", AuditFilter{ResourceID: -1}, map[string][]string{"resource_id": {"positive_integer"}}},
+		{"until before since", AuditFilter{Since: since, Until: since.Add(-time.Hour)}, map[string][]string{"until": {"gt_field"}}},
+		// until is exclusive, so the same time matches nothing
+		{"until at since", AuditFilter{Since: since, Until: since}, map[string][]string{"until": {"gt_field"}}},
+		{"only since", AuditFilter{Since: since}, map[string][]string{}},
+		{"only until", AuditFilter{Until: since}, map[string][]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateAuditFilter(v, tt.filter)
+
+			if !reflect.DeepEqual(v.Codes, tt.want) {
+				t.Errorf("codes = %v, want %v", v.Codes, tt.want)
+			}
+		})
+	}
+
+	// the permitted values are listed in the message
+	v := validator.New()
+	ValidateAuditFilter(v, AuditFilter{Action: "rename"})
+	if want := "must be one of create, update, delete"; v.Errors["action"] != want {
+		t.Errorf("action error = %q, want %q", v.Errors["action"], want)
+	}
+}
+
+func TestAuditChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]any
+		after  map[string]any
+		want   map[string]AuditChange
+	}{
+		{
+			name:  "create",
+			after: map[string]any{"title": "Casablanca", "year": 1942},
+			want:  map[string]AuditChange{"title": {New: "Casablanca"}, "year": {New: 1942}},
+		},
+		{
+			name:   "delete",
+			before: map[string]any{"title": "Casablanca"},
+			want:   map[string]AuditChange{"title": {Old: "Casablanca"}},
+		},
+		{
+			name:   "update keeps only what changed",
+			before: map[string]any{"title": "Casablanca", "year": 1942, "genres": []string{"drama"}},
+			after:  map[string]any{"title": "Casablanca", "year": 1943, "genres": []string{"drama"}},
+			want:   map[string]AuditChange{"year": {Old: 1942, New: 1943}},
+		},
+		{
+			name:   "slices are compared by value",
+			before: map[string]any{"genres": []string{"drama"}},
+			after:  map[string]any{"genres": []string{"drama", "romance"}},
+			want:   map[string]AuditChange{"genres": {Old: []string{"drama"}, New: []string{"drama", "romance"}}},
+		},
+		{
+			name:   "nothing changed",
+			before: map[string]any{"title": "Casablanca"},
+			after:  map[string]any{"title": "Casablanca"},
+			want:   map[string]AuditChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auditChanges(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("auditChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// the types of event written to the change feed
+const (
+	EventMovieCreated = "movie.created"
+	EventMovieUpdated = "movie.updated"
+	EventMovieDeleted = "movie.deleted"
+)
+
+// EventTypes lists every event type, for documenting the feed
+var EventTypes = []string{EventMovieCreated, EventMovieUpdated, EventMovieDeleted}
+
+// the most events returned by a single read of the change feed
+const (
+	EventsLimitDefault = 100
+	EventsLimitMax     = 1000
+)
+
+// an Event is a single entry in the change feed
+// Data is the movie as it was after the change, or as it was when it was deleted
+type Event struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Type       string          `json:"type"`
+	ResourceID int64           `json:"resource_id"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// ValidateEventsCursor checks the cursor and limit a client pages through the change feed with
+func ValidateEventsCursor(v *validator.Validator, after int64, limit int) {
+	v.Check(after >= 0, "after", "validation.min_value", "0")
+	v.Check(limit > 0, "limit", "validation.min_value", "1")
+	v.Check(limit <= EventsLimitMax, "limit", "validation.max_value", strconv.Itoa(EventsLimitMax))
+}
+
+// eventData is the movie as written to the change feed, only the stored fields and none of the per-response ones
+func (movie *Movie) eventData() *Movie {
+	return &Movie{
+		ID:            movie.ID,
+		Title:         movie.Title,
+		Year:          movie.Year,
+		Runtime:       movie.Runtime,
+		Genres:        movie.Genres,
+		Version:       movie.Version,
+		ExternalIDs:   movie.ExternalIDs,
+		AverageRating: movie.AverageRating,
+		RatingCount:   movie.RatingCount,
+	}
+}
+
+// recordEvent writes an event to the change feed, it is called with the transaction that makes the change, after
+// every other write, so that the change and its event are committed or rolled back together
+//
+// ids come from a sequence, so without care a transaction could take an id and commit after one with a higher id,
+// which a consumer that has already read past it would never see. the advisory lock is held until the transaction
+// ends, so ids are handed out in the order their transactions commit and a cursor never skips over an event
+func recordEvent(tx execer, eventType string, resourceID int64, payload any) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('outbox'))`)
+	if err != nil {
+		return err
+	}
+
+	query := `
+			INSERT INTO outbox (event_type, resource_id, payload)
+			VALUES ($1, $2, $3)`
+
+	_, err = tx.Exec(query, eventType, resourceID, js)
+	return err
+}
+
+// an EventModel reads the change feed, the events are written by the models whose changes they record
+type EventModel struct {
+	DB *sql.DB
+}
+
+// GetAfter returns up to limit events that come after the cursor, oldest first
+// the cursor is the id of the last event the consumer has seen, 0 starts from the beginning of the feed
+func (m EventModel) GetAfter(after int64, limit int) ([]*Event, error) {
+	query := `
+			SELECT id, occurred_at, event_type, resource_id, payload
+			FROM outbox
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2`
+
+	rows, err := m.DB.Query(query, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(&event.ID, &event.OccurredAt, &event.Type, &event.ResourceID, &event.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// LastID returns the cursor of the newest event, or 0 when there are none, for consumers that only want what comes next
+func (m EventModel) LastID() (int64, error) {
+	var id int64
+	err := m.DB.QueryRow(`SELECT coalesce(max(id), 0) FROM outbox`).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,111 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// ErrDuplicateMovie is matched by a DuplicateMovieError with errors.Is
+var ErrDuplicateMovie = errors.New("duplicate movie")
+
+// a DuplicateMovieError is returned when a movie matches one that is already stored
+// Field is the external ID source that matched, e.g. "imdb", or "title" for a title and year match
+type DuplicateMovieError struct {
+	ID    int64
+	Field string
+}
+
+func (e *DuplicateMovieError) Error() string {
+	return fmt.Sprintf("duplicate movie: matches movie %d by %s", e.ID, e.Field)
+}
+
+func (e *DuplicateMovieError) Is(target error) bool {
+	return target == ErrDuplicateMovie
+}
+
+// the sources we accept external IDs from, along with the format of their IDs
+// each source has its own unique index on the movies table, so adding one here needs a migration too
+var ExternalIDSources = map[string]*regexp.Regexp{
+	"imdb": regexp.MustCompile(`^tt\d{7,}$`),
+	"tmdb": regexp.MustCompile(`^[1-9]\d*$`),
+}
+
+// ExternalIDs maps a source to the movie's ID there, e.g. {"imdb": "tt0111161"}
+// it is stored in a jsonb column
+type ExternalIDs map[string]string
+
+// Value implements driver.Valuer, writing the IDs out as a JSON object
+func (ids ExternalIDs) Value() (driver.Value, error) {
+	if ids == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(map[string]string(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner, reading the IDs from a JSON object
+func (ids *ExternalIDs) Scan(src any) error {
+	var b []byte
+
+	switch src := src.(type) {
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	case nil:
+		*ids = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into ExternalIDs", src)
+	}
+
+	return json.Unmarshal(b, (*map[string]string)(ids))
+}
+
+// sources returns the sources in a stable order
+func (ids ExternalIDs) sources() []string {
+	sources := make([]string, 0, len(ids))
+	for source := range ids {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	return sources
+}
+
+// externalIDSourceNames lists the supported sources for messages
+func externalIDSourceNames() string {
+	names := make([]string, 0, len(ExternalIDSources))
+	for source := range ExternalIDSources {
+		names = append(names, source)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// ValidateExternalIDs checks that every ID is from a supported source and in that source's format
+// each problem is reported on the ID's own path, e.g. "external_ids/imdb"
+func ValidateExternalIDs(v *validator.Validator, ids ExternalIDs) {
+	for _, source := range ids.sources() {
+		rx, ok := ExternalIDSources[source]
+		if !ok {
+			v.AddError(validator.Path("external_ids", source), "validation.external_id_source", externalIDSourceNames())
+			continue
+		}
+
+		v.Check(validator.Matches(ids[source], rx), validator.Path("external_ids", source), "validation.external_id_format", source)
+	}
+}
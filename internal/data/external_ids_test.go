@@ -0,0 +1,102 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+func TestValidateExternalIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		ids  ExternalIDs
+		want map[string]string
+	}{
+		{"none", nil, map[string]string{}},
+		{"empty", ExternalIDs{}, map[string]string{}},
+		{"valid", ExternalIDs{"imdb": "tt0034583", "tmdb": "289"}, map[string]string{}},
+		{"long imdb id", ExternalIDs{"imdb": "tt10872600"}, map[string]string{}},
+		{"imdb id too short", ExternalIDs{"imdb": "tt034583"}, map[string]string{"external_ids/imdb": "must be a valid imdb ID"}},
+		{"imdb id without prefix", ExternalIDs{"imdb": "0034583"}, map[string]string{"external_ids/imdb": "must be a valid imdb ID"}},
+		{"tmdb id with a leading zero", ExternalIDs{"tmdb": "0289"}, map[string]string{"external_ids/tmdb": "must be a valid tmdb ID"}},
+		{"tmdb id that is not a number", ExternalIDs{"tmdb": "casablanca"}, map[string]string{"external_ids/tmdb": "must be a valid tmdb ID"}},
+		{"unknown source", ExternalIDs{"letterboxd": "casablanca"}, map[string]string{"external_ids/letterboxd": "is not a supported source, use one of imdb, tmdb"}},
+		{
+			name: "each problem on its own path",
+			ids:  ExternalIDs{"imdb": "", "tmdb": "289", "wikidata": "Q132689"},
+			want: map[string]string{
+				"external_ids/imdb":     "must be a valid imdb ID",
+				"external_ids/wikidata": "is not a supported source, use one of imdb, tmdb",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateExternalIDs(v, tt.ids)
+
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("errors = %q, want %q", v.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalIDsValue(t *testing.T) {
+	tests := []struct {
+		ids  ExternalIDs
+		want string
+	}{
+		// the column is NOT NULL, so no IDs are stored as an empty object
+		{nil, "{}"},
+		{ExternalIDs{}, "{}"},
+		{ExternalIDs{"imdb": "tt0034583", "tmdb": "289"}, `{"imdb":"tt0034583","tmdb":"289"}`},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.ids.Value()
+		if err != nil || got != tt.want {
+			t.Errorf("Value() of %v = %v, %v, want %s", tt.ids, got, err, tt.want)
+		}
+	}
+}
+
+func TestExternalIDsScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    ExternalIDs
+		wantErr bool
+	}{
+		{[]byte(`{"imdb": "tt0034583"}`), ExternalIDs{"imdb": "tt0034583"}, false},
+		{`{"tmdb": "289"}`, ExternalIDs{"tmdb": "289"}, false},
+		{`{}`, ExternalIDs{}, false},
+		{nil, nil, false},
+		{42, nil, true},
+		{`["tt0034583"]`, nil, true},
+	}
+
+	for _, tt := range tests {
+		var ids ExternalIDs
+		err := ids.Scan(tt.src)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Scan(%v) = %v, %v, want %v, an error: %t", tt.src, ids, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDuplicateMovieError(t *testing.T) {
+	err := fmt.Errorf("inserting movie: %w", &DuplicateMovieError{ID: 7, Field: "imdb"})
+
+	if !errors.Is(err, ErrDuplicateMovie) {
+		t.Error("errors.Is(err, ErrDuplicateMovie) = false, want true")
+	}
+
+	var dupErr *DuplicateMovieError
+	if !errors.As(err, &dupErr) || dupErr.ID != 7 || dupErr.Field != "imdb" {
+		t.Errorf("errors.As() = %+v, want the movie and source that matched", dupErr)
+	}
+}
@@ -0,0 +1,83 @@
+package data
+
+import (
+	"math"
+	"strings"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// Filters holds the paging and sorting options for listing records
+// SortSafelist holds the sort values the client is allowed to use, a "-" prefix means descending
+type Filters struct {
+	Page         int
+	PageSize     int
+	Sort         string
+	SortSafelist []string
+}
+
+// ValidateFilters checks the paging values are sensible and the sort value is one we allow
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(f.Page > 0, "page", "validation.min_value", "1")
+	v.Check(f.Page <= 10_000_000, "page", "validation.max_value", "10000000")
+	v.Check(f.PageSize > 0, "page_size", "validation.min_value", "1")
+	v.Check(f.PageSize <= 100, "page_size", "validation.max_value", "100")
+
+	v.Check(validator.PermittedValues(f.Sort, f.SortSafelist...), "sort", "validation.sort", strings.Join(f.SortSafelist, ", "))
+}
+
+// sortColumn returns the column to sort by, checked against the safelist so it is safe to put in the query
+// we panic if the value somehow got past validation, as that would otherwise be an SQL injection risk
+func (f Filters) sortColumn() string {
+	for _, safeValue := range f.SortSafelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+// sortDirection returns "ASC" or "DESC" depending on the prefix of the sort value
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// limit is the number of records per page
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+// offset is the number of records to skip to reach the current page
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// Metadata holds the pagination details sent back alongside a page of records
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+// calculateMetadata works out the pagination details from the total number of records
+// an empty Metadata is returned when there are no records
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
@@ -0,0 +1,238 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// custom ErrDuplicateGenre error; returned when a genre with the same slug already exists
+var ErrDuplicateGenre = errors.New("duplicate genre")
+
+// regular expressions for building and checking genre slugs, e.g. "science-fiction"
+var (
+	SlugRX          = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugSeparatorRX = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// a Genre is an entry in the managed vocabulary of genres that movies can use
+// movies store the display name, the slug is the normalised form used for matching
+type Genre struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Slug       string    `json:"slug" validate:"omitempty,max=50"`
+	Name       string    `json:"name" validate:"required,notblank,max=50"`
+	MovieCount int64     `json:"movie_count"`
+}
+
+// Slugify normalises a genre name into its slug, so "Sci-Fi", "sci fi" and "SCI_FI" all become "sci-fi"
+// this must stay in step with the expression used to backfill the genres table in its migration
+func Slugify(name string) string {
+	return strings.Trim(slugSeparatorRX.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-"), "-")
+}
+
+// ValidateGenre checks a new genre, filling in the slug from the name if it was not given
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	if genre.Slug == "" {
+		genre.Slug = Slugify(genre.Name)
+	}
+
+	v.Struct(genre)
+	v.Check(validator.Matches(genre.Slug, SlugRX), "slug", "validation.slug")
+}
+
+// a GenreVocabulary is the set of known genres, used to check and normalise the genres on a movie
+type GenreVocabulary struct {
+	bySlug map[string]*Genre
+}
+
+// NewGenreVocabulary builds a vocabulary from a list of genres
+func NewGenreVocabulary(genres []*Genre) *GenreVocabulary {
+	vocab := &GenreVocabulary{bySlug: make(map[string]*Genre, len(genres))}
+	for _, genre := range genres {
+		vocab.bySlug[genre.Slug] = genre
+	}
+
+	return vocab
+}
+
+// Lookup finds the genre matching a name, ignoring case and punctuation
+func (vocab *GenreVocabulary) Lookup(name string) (*Genre, bool) {
+	genre, ok := vocab.bySlug[Slugify(name)]
+	return genre, ok
+}
+
+// Canonical replaces each known genre with its display name, leaving unknown genres as they are
+func (vocab *GenreVocabulary) Canonical(names []string) []string {
+	canonical := make([]string, len(names))
+	for i, name := range names {
+		canonical[i] = name
+		if genre, ok := vocab.Lookup(name); ok {
+			canonical[i] = genre.Name
+		}
+	}
+
+	return canonical
+}
+
+// Suggest returns up to three display names of known genres that look like a typo of the name, closest first
+func (vocab *GenreVocabulary) Suggest(name string) []string {
+	slug := Slugify(name)
+
+	// allow roughly one edit for every three characters, so short names only match very close genres
+	maxDistance := max(1, len(slug)/3)
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+
+	for genreSlug, genre := range vocab.bySlug {
+		distance := levenshtein(slug, genreSlug)
+		// a name that is the start of a genre ("sci" for "science-fiction") is also worth suggesting
+		if distance <= maxDistance || (len(slug) >= 3 && strings.HasPrefix(genreSlug, slug)) {
+			matches = append(matches, match{name: genre.Name, distance: distance})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	suggestions := make([]string, 0, 3)
+	for i := 0; i < len(matches) && i < 3; i++ {
+		suggestions = append(suggestions, matches[i].name)
+	}
+
+	return suggestions
+}
+
+// levenshtein returns the number of single character edits needed to turn a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// methods for working with the genres vocabulary
+// a GenreModel struct that wraps an sql.DB connection pool
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// insert a genre into the vocabulary
+// ErrDuplicateGenre is returned if the slug is already taken
+func (m GenreModel) Insert(genre *Genre) error {
+	query := `
+			INSERT INTO genres (slug, name)
+			VALUES ($1, $2)
+			RETURNING id, created_at`
+
+	err := m.DB.QueryRow(query, genre.Slug, genre.Name).Scan(&genre.ID, &genre.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateGenre
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAll returns every genre in alphabetical order, along with the number of movies using it
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+			SELECT genres.id, genres.created_at, genres.slug, genres.name, count(movies.id)
+			FROM genres
+			LEFT JOIN movies ON genres.name = ANY(movies.genres)
+			GROUP BY genres.id
+			ORDER BY genres.name`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(&genre.ID, &genre.CreatedAt, &genre.Slug, &genre.Name, &genre.MovieCount)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+// Vocabulary loads the known genres for validating movies
+func (m GenreModel) Vocabulary() (*GenreVocabulary, error) {
+	query := `
+			SELECT id, created_at, slug, name
+			FROM genres`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var genres []*Genre
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(&genre.ID, &genre.CreatedAt, &genre.Slug, &genre.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewGenreVocabulary(genres), nil
+}
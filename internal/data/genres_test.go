@@ -0,0 +1,138 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// testVocabulary is a small vocabulary, with display names that differ from their slugs
+var testVocabulary = NewGenreVocabulary([]*Genre{
+	{Slug: "drama", Name: "Drama"},
+	{Slug: "comedy", Name: "Comedy"},
+	{Slug: "sci-fi", Name: "Sci-Fi"},
+	{Slug: "science-fiction", Name: "Science Fiction"},
+	{Slug: "film-noir", Name: "Film Noir"},
+})
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Drama":             "drama",
+		"Sci-Fi":            "sci-fi",
+		"sci fi":            "sci-fi",
+		"SCI_FI":            "sci-fi",
+		"  Film   Noir  ":   "film-noir",
+		"--rock & roll!--":  "rock-roll",
+		"Science--Fiction":  "science-fiction",
+		"Café":              "caf",
+		"":                  "",
+		"!!!":               "",
+		"film-noir/mystery": "film-noir-mystery",
+	}
+
+	for name, want := range tests {
+		if got := Slugify(name); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGenreVocabularyCanonical(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{[]string{"drama", "SCI FI", "film_noir"}, []string{"Drama", "Sci-Fi", "Film Noir"}},
+		// unknown genres are left as they are, for validation to report
+		{[]string{"Comedy", "westerns"}, []string{"Comedy", "westerns"}},
+		{[]string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		got := testVocabulary.Canonical(tt.names)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+
+	// the names passed in are not changed
+	names := []string{"drama"}
+	testVocabulary.Canonical(names)
+	if names[0] != "drama" {
+		t.Errorf("Canonical() changed its argument to %q", names)
+	}
+}
+
+func TestGenreVocabularySuggest(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"dramma", []string{"Drama"}},
+		{"comdy", []string{"Comedy"}},
+		// the start of a genre's slug is suggested too, closest first
+		{"sci", []string{"Sci-Fi", "Science Fiction"}},
+		{"western", []string{}},
+		// short names only match very close genres
+		{"ab", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := testVocabulary.Suggest(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"drama", "drama", 0},
+		{"drama", "", 5},
+		{"", "drama", 5},
+		{"drama", "dramma", 1},
+		{"comedy", "comdy", 1},
+		{"kitten", "sitting", 3},
+		{"über", "uber", 1},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMovieGenres(t *testing.T) {
+	tests := []struct {
+		name   string
+		genres []string
+		want   map[string]string
+	}{
+		{"known", []string{"drama", "Sci Fi"}, map[string]string{}},
+		{"duplicates by slug", []string{"Sci-Fi", "sci fi"}, map[string]string{"genres": "must not contain duplicate values", "genres/1": "must not duplicate an earlier genre"}},
+		{"unknown with a suggestion", []string{"drama", "comdy"}, map[string]string{"genres/1": "is not a known genre, did you mean Comedy?"}},
+		{"unknown", []string{"western"}, map[string]string{"genres/0": "is not a known genre"}},
+		{"blank", []string{"drama", " "}, map[string]string{"genres/1": "must not be blank"}},
+		{"too long", []string{strings.Repeat("g", 51)}, map[string]string{"genres/0": "must not be more than 50 characters long"}},
+		{"none", []string{}, map[string]string{"genres": "must contain at least 1 genre"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := &Movie{Title: "Casablanca", Year: 1942, Runtime: 102, Genres: tt.genres}
+
+			v := validator.New()
+			ValidateMovie(v, movie, testVocabulary)
+
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("errors = %q, want %q", v.Errors, tt.want)
+			}
+		})
+	}
+}
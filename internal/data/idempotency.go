@@ -0,0 +1,176 @@
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// custom errors returned when an idempotency key is still being used by another request,
+// or was first used for a request with a different method, path or body,
+// and when a request's claim on a key was taken over by a retry before it completed
+var (
+	ErrIdempotencyKeyInProgress = errors.New("idempotency key in progress")
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyClaimLost     = errors.New("idempotency key claimed by another request")
+)
+
+// an IdempotencyRecord is the stored response to a request sent with an Idempotency-Key header
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Status      int
+	Headers     map[string][]string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// methods for working with idempotency keys
+// an IdempotencyModel struct that wraps an sql.DB connection pool
+type IdempotencyModel struct {
+	DB *sql.DB
+}
+
+// Begin claims a key for a request before it is handled
+// a nil record and error means the key is ours, and the request should be handled and then completed or released
+// with the returned claim, which stops a request whose lock was taken over from touching the key again
+// a non-nil record is the stored response to replay. ErrIdempotencyKeyInProgress is returned while another
+// request holds the key, and ErrIdempotencyKeyMismatch if the key was first used for a different request
+// keys are taken over once they expire, or when an unfinished request has held the lock for longer than lockTimeout
+func (m IdempotencyModel) Begin(key, fingerprint string, ttl, lockTimeout time.Duration) (*IdempotencyRecord, string, error) {
+	b := make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	rand.Read(b)
+	claim := hex.EncodeToString(b)
+
+	// the insert and the takeover happen in a single statement, so two requests can never both claim the key
+	query := `
+			INSERT INTO idempotency_keys (key, fingerprint, expires_at, claim)
+			VALUES ($1, $2, NOW() + make_interval(secs => $3), $5)
+			ON CONFLICT (key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, expires_at = EXCLUDED.expires_at, created_at = NOW(),
+				locked_at = NOW(), claim = EXCLUDED.claim, status = NULL, headers = NULL, body = NULL
+			WHERE idempotency_keys.expires_at < NOW()
+			OR (idempotency_keys.status IS NULL
+				AND idempotency_keys.fingerprint = EXCLUDED.fingerprint
+				AND idempotency_keys.locked_at < NOW() - make_interval(secs => $4))
+			RETURNING key`
+
+	var claimed string
+
+	err := m.DB.QueryRow(query, key, fingerprint, ttl.Seconds(), lockTimeout.Seconds(), claim).Scan(&claimed)
+	if err == nil {
+		return nil, claim, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, "", err
+	}
+
+	// someone else holds the key, so see what they did with it
+	record, err := m.get(key)
+	if err != nil {
+		// the key was released between the two statements, ask the client to retry
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, "", ErrIdempotencyKeyInProgress
+		}
+		return nil, "", err
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		return nil, "", ErrIdempotencyKeyMismatch
+	case record.Status == 0:
+		return nil, "", ErrIdempotencyKeyInProgress
+	default:
+		return record, "", nil
+	}
+}
+
+// Complete stores the response to a request, so that it can be replayed until the key expires
+// ErrIdempotencyClaimLost is returned when the claim has since been taken over, the response is then not stored
+func (m IdempotencyModel) Complete(key, claim string, status int, headers map[string][]string, body []byte) error {
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE idempotency_keys
+			SET status = $1, headers = $2, body = $3
+			WHERE key = $4 AND claim = $5 AND status IS NULL`
+
+	result, err := m.DB.Exec(query, status, encoded, body, key, claim)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrIdempotencyClaimLost
+	}
+
+	return nil
+}
+
+// Release gives up a key that we claimed without storing a response, so that the request can be retried
+// nothing is released when the claim has since been taken over, the key then belongs to the retry
+func (m IdempotencyModel) Release(key, claim string) error {
+	query := `DELETE FROM idempotency_keys
+			WHERE key = $1 AND claim = $2 AND status IS NULL`
+
+	_, err := m.DB.Exec(query, key, claim)
+	return err
+}
+
+// DeleteExpired removes the keys that can no longer be replayed, returning how many were removed
+func (m IdempotencyModel) DeleteExpired() (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// get fetches the stored state of a key
+func (m IdempotencyModel) get(key string) (*IdempotencyRecord, error) {
+	query := `
+			SELECT key, fingerprint, COALESCE(status, 0), COALESCE(headers, '{}'), COALESCE(body, ''), created_at, expires_at
+			FROM idempotency_keys
+			WHERE key = $1`
+
+	var record IdempotencyRecord
+	var headers []byte
+
+	err := m.DB.QueryRow(query, key).Scan(
+		&record.Key,
+		&record.Fingerprint,
+		&record.Status,
+		&headers,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(headers, &record.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
@@ -10,14 +10,37 @@ var (
 	ErrRecordNotFound = errors.New("record not found")
 )
 
-// a Models struct that wraps the MovieModel
+// a queryRower is either the connection pool or a transaction, for helpers that are used with both
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// a Models struct that wraps the MovieModel, PersonModel, GenreModel, ReviewModel, WatchlistModel, PosterModel and IdempotencyModel, AuditModel, EventModel and WebhookModel
 type Models struct {
-	Movies MovieModel
+	Movies      MovieModel
+	People      PersonModel
+	Genres      GenreModel
+	Reviews     ReviewModel
+	Watchlists  WatchlistModel
+	Posters     PosterModel
+	Idempotency IdempotencyModel
+	Audit       AuditModel
+	Events      EventModel
+	Webhooks    WebhookModel
 }
 
 // a NewModels() method which returns a Models struct containing the initialized MovieModel
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Movies: MovieModel{DB: db},
+		Movies:      MovieModel{DB: db},
+		People:      PersonModel{DB: db},
+		Genres:      GenreModel{DB: db},
+		Reviews:     ReviewModel{DB: db},
+		Watchlists:  WatchlistModel{DB: db},
+		Posters:     PosterModel{DB: db},
+		Idempotency: IdempotencyModel{DB: db},
+		Audit:       AuditModel{DB: db},
+		Events:      EventModel{DB: db},
+		Webhooks:    WebhookModel{DB: db},
 	}
 }
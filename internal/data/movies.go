@@ -2,7 +2,11 @@ package data
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/TaskMasterErnest/greenlight/internal/validator"
@@ -17,56 +21,312 @@ type Movie struct {
 	Runtime   Runtime   `json:"runtime,omitempty"`
 	Genres    []string  `json:"genres"`
 	Version   int32     `json:"version"`
+	// the movie's IDs in other databases, e.g. {"imdb": "tt0111161"}, each one is unique across all movies
+	ExternalIDs ExternalIDs `json:"external_ids,omitempty"`
+	// the rating fields are kept up to date by the database as reviews come in, they are never set by clients
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int32   `json:"rating_count"`
+	// Poster is nil until artwork has been uploaded for the movie
+	Poster *Poster `json:"poster,omitempty"`
+	// Credits are not stored with the movie, they are only filled in when asked for with ?include=credits
+	Credits []*Credit `json:"credits,omitempty"`
+
+	// runtimeFormat controls how Runtime is written out, it is set per response and never stored
+	runtimeFormat RuntimeFormat
+}
+
+// SetRuntimeFormat changes the representation of the runtime when the movie is marshaled
+func (m *Movie) SetRuntimeFormat(f RuntimeFormat) {
+	m.runtimeFormat = f
+}
+
+// MarshalJSON writes the movie out with its runtime in the chosen format
+func (m Movie) MarshalJSON() ([]byte, error) {
+	// movieJSON has the same fields as Movie but none of its methods, so marshaling it does not recurse back in here
+	type movieJSON Movie
+
+	// credits that were asked for are written out even when there are none, they are nil when they were not asked for
+	var credits *[]*Credit
+	if m.Credits != nil {
+		credits = &m.Credits
+	}
+
+	// the default format is handled by Runtime's own MarshalJSON() method
+	if m.runtimeFormat == "" || m.runtimeFormat == RuntimeFormatMinutes {
+		return json.Marshal(struct {
+			movieJSON
+			Credits *[]*Credit `json:"credits,omitempty"`
+		}{movieJSON: movieJSON(m), Credits: credits})
+	}
+
+	// shadow the embedded runtime field with its formatted string
+	aux := struct {
+		movieJSON
+		Runtime string     `json:"runtime,omitempty"`
+		Credits *[]*Credit `json:"credits,omitempty"`
+	}{movieJSON: movieJSON(m), Credits: credits}
+
+	if m.Runtime != 0 {
+		aux.Runtime = m.Runtime.Format(m.runtimeFormat)
+	}
+
+	return json.Marshal(aux)
+}
+
+// ValidateRuntimeFormat checks that the requested runtime output format is one we support
+func ValidateRuntimeFormat(v *validator.Validator, f RuntimeFormat) {
+	v.Check(validator.PermittedValues(f, RuntimeFormats...), "runtime_format", "validation.runtime_format")
 }
 
+// the limits checked by ValidateMovie, also used to document the API
+const (
+	MovieTitleMaxBytes = 500
+	MovieYearMin       = 1888
+	MovieGenresMin     = 1
+	MovieGenresMax     = 5
+	MovieGenreMaxChars = 50
+)
+
 // a ValidateMovie function that will validate all input on the movie struct
 // regardless of whether it is a fresh input or an edited input
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// genres are checked against the vocabulary, unless it is nil
+func ValidateMovie(v *validator.Validator, movie *Movie, genres *GenreVocabulary) {
 	// use the Check method from the validator to execute validation checks
 	// this will add errors to the errors map if the validations do not evaluate to true
+	// the messages are i18n keys, so they are translated into the validator's locale
 	// <validating Title input>
-	v.Check(movie.Title != "", "title", "must be provided")
-	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(movie.Title != "", "title", "validation.required")
+	v.Check(len(movie.Title) <= MovieTitleMaxBytes, "title", "validation.max_bytes", MovieTitleMaxBytes)
 
 	// <validating Year input>
-	v.Check(movie.Year != 0, "year", "must be provided")
-	v.Check(movie.Year >= 1888, "year", "must be greater than 1888")
-	v.Check(movie.Year <= int32(time.Now().Year()), "year", "must not be in the future")
+	v.Check(movie.Year != 0, "year", "validation.required")
+	v.Check(movie.Year >= MovieYearMin, "year", "validation.year_min", MovieYearMin)
+	v.Check(movie.Year <= int32(time.Now().Year()), "year", "validation.not_future")
 
 	// <validating Runtime input>
-	v.Check(movie.Runtime != 0, "runtime", "must be provided")
-	v.Check(movie.Runtime > 0, "runtime", "must be a positive integer")
+	v.Check(movie.Runtime != 0, "runtime", "validation.required")
+	v.Check(movie.Runtime > 0, "runtime", "validation.positive_integer")
 
 	// <validating Genre input>
-	v.Check(movie.Genres != nil, "genres", "must be provided")
-	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
-	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
+	v.Check(movie.Genres != nil, "genres", "validation.required")
+	v.Check(len(movie.Genres) >= MovieGenresMin, "genres", "validation.genres_min", MovieGenresMin)
+	v.Check(len(movie.Genres) <= MovieGenresMax, "genres", "validation.genres_max", MovieGenresMax)
 	// now we check if all the genres are unique
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	// genres are compared by slug, so "Sci-Fi" and "sci-fi" count as the same genre
+	slugs := make([]string, len(movie.Genres))
+	for i, genre := range movie.Genres {
+		slugs[i] = Slugify(genre)
+	}
+	v.Check(validator.Unique(slugs), "genres", "validation.duplicates")
+
+	// <validating each Genre>
+	// problems with a single genre are reported on its own path, e.g. "genres/2"
+	for i, genre := range movie.Genres {
+		v.Check(validator.NotBlank(genre), validator.Path("genres", i), "validation.not_blank")
+		v.Check(validator.MaxRunes(genre, MovieGenreMaxChars), validator.Path("genres", i), "validation.max_chars", strconv.Itoa(MovieGenreMaxChars))
+
+		// unknown genres are rejected, with the closest known genres as suggestions
+		if genres != nil && validator.NotBlank(genre) {
+			if _, ok := genres.Lookup(genre); !ok {
+				if suggestions := genres.Suggest(genre); len(suggestions) > 0 {
+					v.AddError(validator.Path("genres", i), "validation.unknown_genre_suggest", strings.Join(suggestions, ", "))
+				} else {
+					v.AddError(validator.Path("genres", i), "validation.unknown_genre")
+				}
+			}
+		}
+	}
+	for _, i := range validator.Duplicates(slugs) {
+		v.AddError(validator.Path("genres", i), "validation.duplicate_genre")
+	}
+
+	// <validating External IDs input>
+	ValidateExternalIDs(v, movie.ExternalIDs)
 }
 
 // methods for performing CRUD to Movies
 // a MovieModel struct that wraps an sql.DB connection pool
+// every create, update and delete is recorded in the audit log, with the AuditInfo set by WithAudit, and in the change feed
 type MovieModel struct {
-	DB *sql.DB
+	DB    *sql.DB
+	audit *AuditInfo
+}
+
+// WithAudit returns a copy of the model which records the changes it makes as done by the given actor
+func (m MovieModel) WithAudit(info AuditInfo) MovieModel {
+	m.audit = &info
+	return m
+}
+
+// auditFields are the stored fields of a movie that changes are recorded for
+// the runtime is kept as a plain number of minutes, and missing lists and maps as empty ones, so that they compare equal
+func (movie *Movie) auditFields() map[string]any {
+	genres := movie.Genres
+	if genres == nil {
+		genres = []string{}
+	}
+	externalIDs := map[string]string(movie.ExternalIDs)
+	if externalIDs == nil {
+		externalIDs = map[string]string{}
+	}
+
+	return map[string]any{
+		"title":        movie.Title,
+		"year":         movie.Year,
+		"runtime":      int32(movie.Runtime),
+		"genres":       genres,
+		"external_ids": externalIDs,
+	}
 }
 
 // insert a movie record into the Movie table
+// a *DuplicateMovieError is returned if the movie shares an external ID with a stored movie,
+// or has the same title and year once case, spacing and punctuation are ignored
 func (m MovieModel) Insert(movie *Movie) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// take a lock on the normalised title and year, so two imports of the same movie cannot both get past the checks
+	// the external IDs do not need this, their unique indexes catch any race
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext(regexp_replace(lower($1), '[^[:alnum:]]+', '', 'g') || ':' || $2::text))`,
+		movie.Title, movie.Year)
+	if err != nil {
+		return err
+	}
+
+	err = findDuplicateMovie(tx, movie)
+	if err != nil {
+		return err
+	}
+
 	// defining the SQL query for inserting the new record into the movies table
 	// and returning system-generated data
 	query := `
-			INSERT INTO movies (title, year, runtime, genres)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, created_at, version`
+			INSERT INTO movies (title, year, runtime, genres, external_ids)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at, version, average_rating, rating_count`
 
 	// an args slice to contain the values for the placeholder parameters for the movie struct
 	// with this, we can make it clear as to "what values are being used where" in the query
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalIDs}
 
-	// using the QueryRow() method to execute the SQL query on the connection pool
+	// using the QueryRow() method to execute the SQL query on the transaction
 	// we pass in the args slice as a variadic parameter and scan the system-generated output into the movie struct
-	return m.DB.QueryRow(query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	err = tx.QueryRow(query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version, &movie.AverageRating, &movie.RatingCount)
+	if err != nil {
+		return m.duplicateExternalID(err, movie)
+	}
+
+	err = recordAudit(tx, m.audit, AuditActionCreate, AuditResourceMovie, movie.ID, nil, movie.auditFields())
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(tx, EventMovieCreated, movie.ID, movie.eventData())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// findDuplicateMovie returns a *DuplicateMovieError for the first stored movie matching the new one
+// external IDs are checked before the title and year, as they are the more reliable match
+func findDuplicateMovie(q queryRower, movie *Movie) error {
+	for _, source := range movie.ExternalIDs.sources() {
+		id, err := findByExternalID(q, source, movie.ExternalIDs[source])
+		switch {
+		case err == nil:
+			return &DuplicateMovieError{ID: id, Field: source}
+		case !errors.Is(err, ErrRecordNotFound):
+			return err
+		}
+	}
+
+	query := `
+			SELECT id
+			FROM movies
+			WHERE regexp_replace(lower(title), '[^[:alnum:]]+', '', 'g') = regexp_replace(lower($1), '[^[:alnum:]]+', '', 'g')
+			AND year = $2
+			ORDER BY id
+			LIMIT 1`
+
+	var id int64
+
+	err := q.QueryRow(query, movie.Title, movie.Year).Scan(&id)
+	switch {
+	case err == nil:
+		return &DuplicateMovieError{ID: id, Field: "title"}
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	default:
+		return err
+	}
+}
+
+// findByExternalID returns the ID of the movie with the given ID at a source
+// the source is written into the query so that its partial unique index is used,
+// which is safe as only the sources in ExternalIDSources are allowed
+func findByExternalID(q queryRower, source, externalID string) (int64, error) {
+	if _, ok := ExternalIDSources[source]; !ok {
+		return 0, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+			SELECT id
+			FROM movies
+			WHERE external_ids ? '%[1]s' AND external_ids->>'%[1]s' = $1`, source)
+
+	var id int64
+
+	err := q.QueryRow(query, externalID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// duplicateExternalID turns a unique violation on one of the external ID indexes into a *DuplicateMovieError
+// this happens when another request stored the same ID between our check and our write
+func (m MovieModel) duplicateExternalID(err error, movie *Movie) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+
+	for source := range ExternalIDSources {
+		if pqErr.Constraint != "movies_external_ids_"+source+"_idx" {
+			continue
+		}
+
+		id, lookupErr := findByExternalID(m.DB, source, movie.ExternalIDs[source])
+		if lookupErr != nil {
+			return err
+		}
+
+		return &DuplicateMovieError{ID: id, Field: source}
+	}
+
+	return err
+}
+
+// GetByExternalID fetches the movie with the given ID at a source, e.g. ("imdb", "tt0111161")
+func (m MovieModel) GetByExternalID(source, externalID string) (*Movie, error) {
+	id, err := findByExternalID(m.DB, source, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Get(id)
 }
 
 // fetching a movie record from the Movie table
@@ -78,17 +338,21 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	}
 
 	// define query for retrieving movie data
+	// the poster columns are all NULL when the movie has no poster
 	query := `
-			SELECT id, created_at, title, year, runtime, genres, version
+			SELECT movies.id, movies.created_at, title, year, runtime, genres, version, average_rating, rating_count,
+				external_ids, ` + posterColumns + `
 			FROM movies
-			WHERE id = $1`
+			LEFT JOIN movie_posters ON movie_posters.movie_id = movies.id
+			WHERE movies.id = $1`
 
 	// declare a struct to hold the data returned by the query
 	var movie Movie
+	var poster nullPoster
 
 	// execute query with QueryRow() method, pass in ID value as placeholder param
 	// scan response data into fields of Movie struct
-	err := m.DB.QueryRow(query, id).Scan(
+	dest := []any{
 		&movie.ID,
 		&movie.CreatedAt,
 		&movie.Title,
@@ -96,7 +360,11 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.Version,
-	)
+		&movie.AverageRating,
+		&movie.RatingCount,
+		&movie.ExternalIDs,
+	}
+	err := m.DB.QueryRow(query, id).Scan(append(dest, poster.dest()...)...)
 
 	// handle any errors.
 	// if Scan() returns an sql.ErrNoRows error, check and return our custom ErrRecordNotFound instead
@@ -109,56 +377,167 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		}
 	}
 
+	movie.Poster = poster.poster(movie.ID)
+
 	// otherwise return a pointer to the movie struct
 	return &movie, nil
 
 }
 
+// GetAll returns a page of movies, optionally filtered by title and genres
+// the title is matched with full-text search, and a movie must have every one of the given genres
+func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+	// the sort column comes from the safelist, so it is safe to interpolate
+	// the id is always added as a secondary sort so that pages are stable
+	query := fmt.Sprintf(`
+			SELECT count(*) OVER(), movies.id, movies.created_at, title, year, runtime, genres, version,
+				average_rating, rating_count, external_ids, %s
+			FROM movies
+			LEFT JOIN movie_posters ON movie_posters.movie_id = movies.id
+			WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+			AND (genres @> $2 OR $2 = '{}')
+			ORDER BY movies.%s %s, movies.id ASC
+			LIMIT $3 OFFSET $4`, posterColumns, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
+
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+		var poster nullPoster
+
+		dest := []any{
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.ExternalIDs,
+		}
+		err := rows.Scan(append(dest, poster.dest()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movie.Poster = poster.poster(movie.ID)
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
 // update a specific movie record in the Movie table
+// the stored movie is read and locked first, so that the audit log records what the update changed
 func (m MovieModel) Update(movie *Movie) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before Movie
+	err = tx.QueryRow(`SELECT title, year, runtime, genres, external_ids FROM movies WHERE id = $1 FOR UPDATE`, movie.ID).
+		Scan(&before.Title, &before.Year, &before.Runtime, pq.Array(&before.Genres), &before.ExternalIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
 	// add query to update the fields in the movie struct
 	query := `UPDATE movies
-			SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-			WHERE id = $5
+			SET title = $1, year = $2, runtime = $3, genres = $4, external_ids = $5, version = version + 1
+			WHERE id = $6
 			RETURNING version`
 
 	// make a slice of args that we will pass into the executing SQL query
-	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ID}
+	args := []any{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.ExternalIDs, movie.ID}
 
 	// make the query with the QueryRow() method, passing in the slice of args as a variadic parameter
 	// scan the new version value into the movie struct
-	return m.DB.QueryRow(query, args...).Scan(&movie.Version)
+	// an external ID taken by another movie is returned as a *DuplicateMovieError
+	err = tx.QueryRow(query, args...).Scan(&movie.Version)
+	if err != nil {
+		return m.duplicateExternalID(err, movie)
+	}
+
+	err = recordAudit(tx, m.audit, AuditActionUpdate, AuditResourceMovie, movie.ID, before.auditFields(), movie.auditFields())
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(tx, EventMovieUpdated, movie.ID, movie.eventData())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // delete a specific movie record from the Movie table
+// the deleted fields are returned by the query, so that the audit log and change feed keep what was removed
 func (m MovieModel) Delete(id int64) error {
 	// return an error if the movie ID is less than 1
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	// query to delete movie with specific ID
 	query := `DELETE FROM movies
-			WHERE id = $1`
+			WHERE id = $1
+			RETURNING title, year, runtime, genres, external_ids, version, average_rating, rating_count`
 
-	// execute the SQL query using the Exec() method, pass in the id variable as the placeholder parameter
-	// the Exec() method returns an sql.Result object
-	result, err := m.DB.Exec(query, id)
+	// if no row comes back, then we know that the record was not in the table
+	movie := Movie{ID: id}
+	err = tx.QueryRow(query, id).Scan(&movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres), &movie.ExternalIDs,
+		&movie.Version, &movie.AverageRating, &movie.RatingCount)
 	if err != nil {
-		return err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
 	}
 
-	// get the number of rows affected by the query by calling the RowsAffected() method on the sql.Result output
-	rowsAffected, err := result.RowsAffected()
+	err = recordAudit(tx, m.audit, AuditActionDelete, AuditResourceMovie, id, movie.auditFields(), nil)
 	if err != nil {
 		return err
 	}
 
-	// if not rows were affected, then we know that the record was not in the table
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	err = recordEvent(tx, EventMovieDeleted, id, movie.eventData())
+	if err != nil {
+		return err
 	}
 
-	return nil
-
+	return tx.Commit()
 }
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// custom errors returned when a credit refers to a movie or person that does not exist,
+// or repeats a credit the movie already has
+var (
+	ErrInvalidReference = errors.New("invalid reference")
+	ErrDuplicateCredit  = errors.New("duplicate credit")
+)
+
+// the roles a person can be credited with on a movie
+const (
+	RoleDirector = "director"
+	RoleActor    = "actor"
+	RoleWriter   = "writer"
+)
+
+// a Person is anyone credited on a movie, e.g. a director or an actor
+// the validate tags hold the static rules, ValidatePerson adds the ones that depend on the current time
+type Person struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name" validate:"required,notblank,max=200"`
+	BirthYear int32     `json:"birth_year,omitempty" validate:"omitempty,min=1800"`
+	Biography string    `json:"biography,omitempty" validate:"max=5000"`
+	Version   int32     `json:"version"`
+}
+
+// a Credit links a person to a movie in a particular role
+// Name is the person's name, filled in when credits are read back for a movie
+type Credit struct {
+	ID           int64  `json:"id"`
+	MovieID      int64  `json:"-"`
+	PersonID     int64  `json:"person_id" validate:"required"`
+	Name         string `json:"name,omitempty"`
+	Role         string `json:"role" validate:"required,oneof=director actor writer"`
+	Character    string `json:"character,omitempty" validate:"max=200"`
+	BillingOrder int32  `json:"billing_order" validate:"min=0"`
+}
+
+// ValidatePerson checks a person against the rules in its validate tags and that the birth year is not in the future
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Struct(person)
+	v.Check(person.BirthYear <= int32(time.Now().Year()), "birth_year", "validation.not_future")
+}
+
+// ValidateCredit checks a credit against the rules in its validate tags
+// only actors play a character, so the character name must be empty for the other roles
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Struct(credit)
+	v.Check(credit.Role == RoleActor || credit.Character == "", "character", "validation.character_actor_only")
+}
+
+// methods for performing CRUD to People and their movie credits
+// a PersonModel struct that wraps an sql.DB connection pool
+type PersonModel struct {
+	DB *sql.DB
+}
+
+// insert a person record into the people table
+func (m PersonModel) Insert(person *Person) error {
+	query := `
+			INSERT INTO people (name, birth_year, biography)
+			VALUES ($1, NULLIF($2, 0), $3)
+			RETURNING id, created_at, version`
+
+	args := []any{person.Name, person.BirthYear, person.Biography}
+
+	return m.DB.QueryRow(query, args...).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+// fetching a person record from the people table
+func (m PersonModel) Get(id int64) (*Person, error) {
+	// no ID will be less than 1, so avoid the database call
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+			SELECT id, created_at, name, COALESCE(birth_year, 0), biography, version
+			FROM people
+			WHERE id = $1`
+
+	var person Person
+
+	err := m.DB.QueryRow(query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&person.BirthYear,
+		&person.Biography,
+		&person.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &person, nil
+}
+
+// update a specific person record in the people table
+func (m PersonModel) Update(person *Person) error {
+	query := `UPDATE people
+			SET name = $1, birth_year = NULLIF($2, 0), biography = $3, version = version + 1
+			WHERE id = $4
+			RETURNING version`
+
+	args := []any{person.Name, person.BirthYear, person.Biography, person.ID}
+
+	return m.DB.QueryRow(query, args...).Scan(&person.Version)
+}
+
+// delete a specific person record from the people table
+// their credits are removed along with them by the ON DELETE CASCADE on movie_credits
+func (m PersonModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM people
+			WHERE id = $1`
+
+	result, err := m.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// InsertCredit adds a credit to a movie
+// ErrInvalidReference is returned if the movie or person does not exist, ErrDuplicateCredit if the credit already exists
+func (m PersonModel) InsertCredit(credit *Credit) error {
+	query := `
+			WITH inserted AS (
+				INSERT INTO movie_credits (movie_id, person_id, role, character_name, billing_order)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id, person_id
+			)
+			SELECT inserted.id, people.name
+			FROM inserted
+			INNER JOIN people ON people.id = inserted.person_id`
+
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.Character, credit.BillingOrder}
+
+	err := m.DB.QueryRow(query, args...).Scan(&credit.ID, &credit.Name)
+	if err != nil {
+		// translate foreign key and unique violations into our own errors
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrInvalidReference
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateCredit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetCreditsForMovie returns the credits of a movie, with directors and writers first and each role in billing order
+func (m PersonModel) GetCreditsForMovie(movieID int64) ([]*Credit, error) {
+	query := `
+			SELECT movie_credits.id, movie_credits.movie_id, movie_credits.person_id, people.name,
+				movie_credits.role, movie_credits.character_name, movie_credits.billing_order
+			FROM movie_credits
+			INNER JOIN people ON people.id = movie_credits.person_id
+			WHERE movie_credits.movie_id = $1
+			ORDER BY CASE movie_credits.role WHEN 'director' THEN 1 WHEN 'writer' THEN 2 ELSE 3 END,
+				movie_credits.billing_order, movie_credits.id`
+
+	rows, err := m.DB.Query(query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// an empty, non-nil slice is written out as [] rather than null
+	credits := []*Credit{}
+
+	for rows.Next() {
+		var credit Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.Name,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// DeleteCredit removes a single credit from a movie
+func (m PersonModel) DeleteCredit(movieID, creditID int64) error {
+	if movieID < 1 || creditID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM movie_credits
+			WHERE id = $1 AND movie_id = $2`
+
+	result, err := m.DB.Exec(query, creditID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// the content types accepted for poster uploads, and the file extension each is stored under
+var PosterContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// limits on the dimensions of an uploaded poster, in pixels
+const (
+	PosterMinWidth  = 100
+	PosterMinHeight = 100
+	PosterMaxWidth  = 8000
+	PosterMaxHeight = 8000
+)
+
+// the original upload is kept as it was sent, the thumbnails are generated from it at these widths
+const PosterSizeOriginal = "original"
+
+var PosterThumbnailWidths = map[string]int{
+	"small":  185,
+	"medium": 500,
+}
+
+// a Poster describes the artwork uploaded for a movie, the image itself is kept in a blob store
+// the checksum is of the original upload, and changes whenever the poster is replaced
+type Poster struct {
+	MovieID     int64             `json:"-"`
+	ContentType string            `json:"content_type"`
+	Width       int32             `json:"width"`
+	Height      int32             `json:"height"`
+	Size        int64             `json:"size"`
+	Checksum    string            `json:"-"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+}
+
+// ValidatePoster checks the sniffed content type and the dimensions of an upload
+func ValidatePoster(v *validator.Validator, poster *Poster) {
+	_, ok := PosterContentTypes[poster.ContentType]
+	v.Check(ok, "poster", "validation.image_type")
+
+	if ok {
+		v.Check(poster.Width >= PosterMinWidth && poster.Height >= PosterMinHeight, "poster", "validation.image_min_dimensions", PosterMinWidth, PosterMinHeight)
+		v.Check(poster.Width <= PosterMaxWidth && poster.Height <= PosterMaxHeight, "poster", "validation.image_max_dimensions", PosterMaxWidth, PosterMaxHeight)
+	}
+}
+
+// Key returns the blob key of the original or a thumbnail
+// keys include the checksum, so a replaced poster never overwrites the blobs of the one before it
+func (p *Poster) Key(size string) string {
+	ext := PosterContentTypes[p.ContentType]
+	if size != PosterSizeOriginal {
+		ext = p.ThumbnailExt()
+	}
+
+	return path.Join("posters", fmt.Sprint(p.MovieID), p.Checksum, size+ext)
+}
+
+// Keys returns the blob keys of the original and all of the thumbnails
+func (p *Poster) Keys() []string {
+	keys := []string{p.Key(PosterSizeOriginal)}
+	for size := range PosterThumbnailWidths {
+		keys = append(keys, p.Key(size))
+	}
+
+	return keys
+}
+
+// ThumbnailContentType is JPEG for JPEG originals, and PNG for everything else so that transparency is kept
+func (p *Poster) ThumbnailContentType() string {
+	if p.ContentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+
+	return "image/png"
+}
+
+// ThumbnailExt is the file extension matching ThumbnailContentType
+func (p *Poster) ThumbnailExt() string {
+	return PosterContentTypes[p.ThumbnailContentType()]
+}
+
+// ETag identifies one size of one version of the poster
+func (p *Poster) ETag(size string) string {
+	return fmt.Sprintf(`"%s-%s"`, p.Checksum, size)
+}
+
+// setURLs fills in the URLs the poster is served from
+// the v parameter changes with the checksum, so these URLs can be cached for as long as the client likes
+func (p *Poster) setURLs() {
+	base := fmt.Sprintf("/v1/movies/%d/poster", p.MovieID)
+	version := p.Checksum[:min(len(p.Checksum), 16)]
+
+	p.URL = fmt.Sprintf("%s?v=%s", base, version)
+	p.Thumbnails = make(map[string]string, len(PosterThumbnailWidths))
+	for size := range PosterThumbnailWidths {
+		p.Thumbnails[size] = fmt.Sprintf("%s?size=%s&v=%s", base, size, version)
+	}
+}
+
+// IsVersion reports whether v is the version given out in the poster's URLs
+func (p *Poster) IsVersion(v string) bool {
+	return v != "" && v == p.Checksum[:min(len(p.Checksum), 16)]
+}
+
+// methods for working with the poster metadata
+// a PosterModel struct that wraps an sql.DB connection pool
+type PosterModel struct {
+	DB *sql.DB
+}
+
+// Upsert saves the poster of a movie, replacing any poster it already had
+// the previous poster is returned so that its blobs can be removed, or nil if there was none
+func (m PosterModel) Upsert(poster *Poster) (*Poster, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	previous, err := getPoster(tx, poster.MovieID, true)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, err
+	}
+
+	query := `
+			INSERT INTO movie_posters (movie_id, content_type, width, height, size, checksum)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (movie_id) DO UPDATE
+			SET content_type = EXCLUDED.content_type, width = EXCLUDED.width, height = EXCLUDED.height,
+				size = EXCLUDED.size, checksum = EXCLUDED.checksum, updated_at = NOW()
+			RETURNING updated_at`
+
+	args := []any{poster.MovieID, poster.ContentType, poster.Width, poster.Height, poster.Size, poster.Checksum}
+
+	err = tx.QueryRow(query, args...).Scan(&poster.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	poster.setURLs()
+
+	return previous, nil
+}
+
+// fetching the poster of a movie
+func (m PosterModel) Get(movieID int64) (*Poster, error) {
+	if movieID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	return getPoster(m.DB, movieID, false)
+}
+
+// delete the poster of a movie, returning it so that its blobs can be removed
+func (m PosterModel) Delete(movieID int64) (*Poster, error) {
+	if movieID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+			DELETE FROM movie_posters
+			WHERE movie_id = $1
+			RETURNING movie_id, content_type, width, height, size, checksum, updated_at`
+
+	return scanPoster(m.DB.QueryRow(query, movieID))
+}
+
+// getPoster reads a poster with either the connection pool or a transaction, optionally locking the row
+func getPoster(q queryRower, movieID int64, forUpdate bool) (*Poster, error) {
+	query := `
+			SELECT movie_id, content_type, width, height, size, checksum, updated_at
+			FROM movie_posters
+			WHERE movie_id = $1`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	return scanPoster(q.QueryRow(query, movieID))
+}
+
+// scanPoster reads a poster row, returning ErrRecordNotFound when there is no row
+func scanPoster(row *sql.Row) (*Poster, error) {
+	var poster Poster
+
+	err := row.Scan(
+		&poster.MovieID,
+		&poster.ContentType,
+		&poster.Width,
+		&poster.Height,
+		&poster.Size,
+		&poster.Checksum,
+		&poster.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	poster.setURLs()
+
+	return &poster, nil
+}
+
+// posterColumns are the poster columns selected alongside a movie, from a LEFT JOIN on movie_posters
+const posterColumns = `movie_posters.content_type, movie_posters.width, movie_posters.height,
+				movie_posters.size, movie_posters.checksum, movie_posters.updated_at`
+
+// a nullPoster scans the poster columns of a movie, which are all NULL when the movie has no poster
+type nullPoster struct {
+	contentType sql.NullString
+	width       sql.NullInt32
+	height      sql.NullInt32
+	size        sql.NullInt64
+	checksum    sql.NullString
+	updatedAt   sql.NullTime
+}
+
+// dest returns the scan destinations, in the same order as posterColumns
+func (np *nullPoster) dest() []any {
+	return []any{&np.contentType, &np.width, &np.height, &np.size, &np.checksum, &np.updatedAt}
+}
+
+// poster returns the scanned poster, or nil if the movie has none
+func (np *nullPoster) poster(movieID int64) *Poster {
+	if !np.checksum.Valid {
+		return nil
+	}
+
+	poster := &Poster{
+		MovieID:     movieID,
+		ContentType: np.contentType.String,
+		Width:       np.width.Int32,
+		Height:      np.height.Int32,
+		Size:        np.size.Int64,
+		Checksum:    np.checksum.String,
+		UpdatedAt:   np.updatedAt.Time,
+	}
+	poster.setURLs()
+
+	return poster
+}
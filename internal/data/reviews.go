@@ -0,0 +1,115 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// custom ErrDuplicateReview error; returned when a reviewer has already reviewed the movie
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// a Review is a single reviewer's score and write-up of a movie
+// each reviewer can only review a movie once, this is enforced by a unique constraint on the reviews table
+type Review struct {
+	ID        int64     `json:"id"`
+	MovieID   int64     `json:"movie_id"`
+	Reviewer  string    `json:"reviewer" validate:"required,notblank,max=100"`
+	Score     int32     `json:"score" validate:"required,min=1,max=10"`
+	Body      string    `json:"body,omitempty" validate:"max=10000"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"version"`
+}
+
+// ValidateReview checks a review against the rules in its validate tags
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Struct(review)
+}
+
+// methods for working with movie reviews
+// a ReviewModel struct that wraps an sql.DB connection pool
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// insert a review for a movie
+// the movie's average_rating and rating_count are refreshed by a trigger on the reviews table
+// ErrInvalidReference is returned if the movie does not exist, ErrDuplicateReview if the reviewer already reviewed it
+func (m ReviewModel) Insert(review *Review) error {
+	query := `
+			INSERT INTO reviews (movie_id, reviewer, score, body)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, updated_at, version`
+
+	args := []any{review.MovieID, review.Reviewer, review.Score, review.Body}
+
+	err := m.DB.QueryRow(query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrInvalidReference
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllForMovie returns a page of the reviews of a movie
+func (m ReviewModel) GetAllForMovie(movieID int64, filters Filters) ([]*Review, Metadata, error) {
+	// the sort column comes from the safelist, so it is safe to interpolate
+	query := fmt.Sprintf(`
+			SELECT count(*) OVER(), id, movie_id, reviewer, score, body, created_at, updated_at, version
+			FROM reviews
+			WHERE movie_id = $1
+			ORDER BY %s %s, id ASC
+			LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.Query(query, movieID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	// an empty, non-nil slice is written out as [] rather than null
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.MovieID,
+			&review.Reviewer,
+			&review.Score,
+			&review.Body,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
@@ -3,53 +3,179 @@ package data
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 // define error to be returned by UnmarshalJSON()
+// the errors returned wrap this one with a more precise message, so errors.Is() still works for callers
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
 // declare a custom Runtime type, same as in the Movie struct
 type Runtime int32
 
+// RuntimeFormat is the representation used when writing a Runtime out
+type RuntimeFormat string
+
+// the supported runtime output formats
+const (
+	RuntimeFormatMinutes RuntimeFormat = "minutes" // "135 mins", the default
+	RuntimeFormatISO8601 RuntimeFormat = "iso8601" // "PT2H15M"
+	RuntimeFormatHuman   RuntimeFormat = "human"   // "2h 15m"
+)
+
+// RuntimeFormats lists every supported runtime output format, used for validating user input
+var RuntimeFormats = []RuntimeFormat{RuntimeFormatMinutes, RuntimeFormatISO8601, RuntimeFormatHuman}
+
+// regular expressions for the accepted runtime input forms
+var (
+	// "135", "135 mins", "1 min", "135 minutes", "135m"
+	minutesRX = regexp.MustCompile(`^(-?\d+)\s*(?:m|min|mins|minute|minutes)?$`)
+	// "2h 15m", "2h15m", "2 hrs 15 mins", "2 hours", "2h"
+	hoursRX = regexp.MustCompile(`^(-?\d+)\s*(?:h|hr|hrs|hour|hours)(?:\s*(\d+)\s*(?:m|min|mins|minute|minutes))?$`)
+	// "PT135M", "PT2H15M", "PT2H"
+	iso8601RX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?$`)
+	// a bare JSON integer, 135
+	integerRX = regexp.MustCompile(`^-?\d+$`)
+)
+
+// RuntimeInputPatterns returns the regular expressions that a runtime given as a string must match one of,
+// for documenting the API
+func RuntimeInputPatterns() []string {
+	return []string{minutesRX.String(), hoursRX.String(), iso8601RX.String()}
+}
+
 // implement a MarshalJSON() method on the Runtime type, to satisfy the json.Marshaler interface
 func (r Runtime) MarshalJSON() ([]byte, error) {
 	// generate a string containing the movie runtime value in the required format
-	jsonValue := fmt.Sprintf("%d mins", r)
-
 	// wrap it in double quotes with the strconv.Quote() function
-	quotedJSONValue := strconv.Quote(jsonValue)
+	quotedJSONValue := strconv.Quote(r.Format(RuntimeFormatMinutes))
 
 	return []byte(quotedJSONValue), nil
 }
 
+// Format returns the runtime as a string in the given format
+// an unknown format falls back to the default "<runtime> mins" representation
+func (r Runtime) Format(f RuntimeFormat) string {
+	hours, minutes := r/60, r%60
+
+	switch f {
+	case RuntimeFormatISO8601:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+	case RuntimeFormatHuman:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("%dm", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("%dh", hours)
+		default:
+			return fmt.Sprintf("%dh %dm", hours, minutes)
+		}
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
+
 // implement UnmarshalJSON() method on the Runtime type so that it satisfies the json.Unmarshaler interface
 // it must be a pointer so that we modify the underlying value itself instead of a copy
+// a runtime can be sent as a bare integer (135) or as a string in one of these forms:
+// "135 mins", "1 min", "2h 15m" or an ISO 8601 duration such as "PT2H15M"
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	// the expected incoming input for the runtime is a string ("<runtime> mins"), so we unquote it
-	unquotedJSONvalue, err := strconv.Unquote(string(jsonValue))
-	if err != nil {
-		return ErrInvalidRuntimeFormat
-	}
+	value := string(jsonValue)
 
-	// we split the string to isolate both parts
-	parts := strings.Split(unquotedJSONvalue, " ")
+	// by convention a JSON null leaves the value untouched, the validator then reports it as missing
+	if value == "null" {
+		return nil
+	}
 
-	// perform sanity checks on both parts
-	if len(parts) != 2 || parts[1] != "mins" {
-		return ErrInvalidRuntimeFormat
+	// a JSON string is unquoted, anything else must be a bare integer
+	if strings.HasPrefix(value, `"`) {
+		unquotedJSONValue, err := strconv.Unquote(value)
+		if err != nil {
+			return fmt.Errorf("%w: runtime must be a string or an integer", ErrInvalidRuntimeFormat)
+		}
+		value = unquotedJSONValue
+	} else if !integerRX.MatchString(value) {
+		return fmt.Errorf("%w: runtime must be a string or an integer", ErrInvalidRuntimeFormat)
 	}
 
-	// parse the part that contains the number into an int32 type
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+	runtime, err := ParseRuntime(value)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	// convert the int32 into a Runtime type and assign this to a receiver
 	// dereference the receiver in order to set the underlying value of the pointer
-	*r = Runtime(i)
+	*r = runtime
 
 	return nil
 }
+
+// ParseRuntime parses a runtime from any of the accepted input forms
+// negative runtimes and runtimes that do not fit in an int32 are rejected
+func ParseRuntime(value string) (Runtime, error) {
+	value = strings.TrimSpace(value)
+
+	var hours, minutes string
+
+	// we check each form in turn and isolate the hour and minute parts
+	if parts := minutesRX.FindStringSubmatch(value); parts != nil {
+		minutes = parts[1]
+	} else if parts := hoursRX.FindStringSubmatch(value); parts != nil {
+		hours, minutes = parts[1], parts[2]
+	} else if parts := iso8601RX.FindStringSubmatch(strings.ToUpper(value)); parts != nil && (parts[1] != "" || parts[2] != "") {
+		hours, minutes = parts[1], parts[2]
+	} else {
+		return 0, fmt.Errorf(`%w: %q is not a recognised runtime, use minutes ("135 mins"), hours and minutes ("2h 15m"), an ISO 8601 duration ("PT2H15M") or an integer`, ErrInvalidRuntimeFormat, value)
+	}
+
+	if strings.HasPrefix(hours, "-") || strings.HasPrefix(minutes, "-") {
+		return 0, fmt.Errorf("%w: runtime must not be negative", ErrInvalidRuntimeFormat)
+	}
+
+	total, err := toMinutes(hours, minutes)
+	if err != nil {
+		return 0, err
+	}
+
+	// convert the int64 into a Runtime type
+	return Runtime(total), nil
+}
+
+// toMinutes adds up the hour and minute parts, either of which may be empty
+// an error is returned if the total is too large to store
+func toMinutes(hours, minutes string) (int64, error) {
+	errTooLarge := fmt.Errorf("%w: runtime must not be more than %d minutes", ErrInvalidRuntimeFormat, math.MaxInt32)
+
+	var total int64
+
+	if hours != "" {
+		h, err := strconv.ParseInt(hours, 10, 32)
+		if err != nil || h > math.MaxInt32/60 {
+			return 0, errTooLarge
+		}
+		total = h * 60
+	}
+
+	if minutes != "" {
+		m, err := strconv.ParseInt(minutes, 10, 32)
+		if err != nil {
+			return 0, errTooLarge
+		}
+		total += m
+	}
+
+	if total > math.MaxInt32 {
+		return 0, errTooLarge
+	}
+
+	return total, nil
+}
@@ -0,0 +1,167 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Runtime
+	}{
+		// minutes
+		{"135", 135},
+		{"0", 0},
+		{"135 mins", 135},
+		{"1 min", 1},
+		{"135 minutes", 135},
+		{"1 minute", 1},
+		{"135m", 135},
+		{"135min", 135},
+		{"  135 mins  ", 135},
+		// hours and minutes
+		{"2h 15m", 135},
+		{"2h15m", 135},
+		{"2 hrs 15 mins", 135},
+		{"2 hours 15 minutes", 135},
+		{"1 hour 1 minute", 61},
+		{"2 hr", 120},
+		{"2h", 120},
+		{"0h 90m", 90},
+		// ISO 8601
+		{"PT135M", 135},
+		{"PT2H15M", 135},
+		{"PT2H", 120},
+		{"pt2h15m", 135},
+		// the largest runtime that fits
+		{strconv.Itoa(math.MaxInt32), math.MaxInt32},
+		{"PT35791394H7M", math.MaxInt32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRuntime(tt.value)
+			if err != nil {
+				t.Fatalf("ParseRuntime(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRuntime(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRuntimeRejects(t *testing.T) {
+	tests := []string{
+		"",
+		"mins",
+		"abc",
+		"135 secs",
+		"135 MINS",
+		"1.5h",
+		"2h -15m",
+		"PT",
+		"PT15S",
+		"P1D",
+		"-1",
+		"-5 mins",
+		"-2h 15m",
+		// too large for an int32
+		strconv.Itoa(math.MaxInt32 + 1),
+		"99999999999",
+		"35791395h",
+		"PT35791394H8M",
+		"PT35791395H",
+	}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			got, err := ParseRuntime(value)
+			if !errors.Is(err, ErrInvalidRuntimeFormat) {
+				t.Errorf("ParseRuntime(%q) = %d, %v, want ErrInvalidRuntimeFormat", value, got, err)
+			}
+		})
+	}
+}
+
+func TestRuntimeFormat(t *testing.T) {
+	tests := []struct {
+		runtime Runtime
+		minutes string
+		iso8601 string
+		human   string
+	}{
+		{0, "0 mins", "PT0M", "0m"},
+		{45, "45 mins", "PT45M", "45m"},
+		{60, "60 mins", "PT1H", "1h"},
+		{135, "135 mins", "PT2H15M", "2h 15m"},
+		{math.MaxInt32, "2147483647 mins", "PT35791394H7M", "35791394h 7m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.runtime)), func(t *testing.T) {
+			formats := map[RuntimeFormat]string{
+				RuntimeFormatMinutes: tt.minutes,
+				RuntimeFormatISO8601: tt.iso8601,
+				RuntimeFormatHuman:   tt.human,
+				"unknown":            tt.minutes,
+			}
+
+			for format, want := range formats {
+				got := tt.runtime.Format(format)
+				if got != want {
+					t.Errorf("Format(%s) = %q, want %q", format, got, want)
+				}
+
+				// every format can be read back in
+				parsed, err := ParseRuntime(got)
+				if err != nil || parsed != tt.runtime {
+					t.Errorf("ParseRuntime(%q) = %d, %v, want %d", got, parsed, err, tt.runtime)
+				}
+			}
+		})
+	}
+}
+
+func TestRuntimeJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want Runtime
+		err  bool
+	}{
+		{`135`, 135, false},
+		{`"135 mins"`, 135, false},
+		{`"PT2H15M"`, 135, false},
+		{`"2h 15m"`, 135, false},
+		{`null`, 0, false},
+		{`-5`, 0, true},
+		{`1.5`, 0, true},
+		{`true`, 0, true},
+		{`"2 fortnights"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var r Runtime
+			err := json.Unmarshal([]byte(tt.json), &r)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidRuntimeFormat) {
+					t.Errorf("Unmarshal(%s) error = %v, want ErrInvalidRuntimeFormat", tt.json, err)
+				}
+				return
+			}
+			if err != nil || r != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, %v, want %d", tt.json, r, err, tt.want)
+			}
+		})
+	}
+
+	b, err := json.Marshal(Runtime(135))
+	if err != nil || string(b) != `"135 mins"` {
+		t.Errorf("Marshal(135) = %s, %v, want \"135 mins\"", b, err)
+	}
+}
@@ -0,0 +1,456 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// custom errors returned when an owner already has a watchlist with the same name,
+// or a movie is added to a watchlist that already has it
+var (
+	ErrDuplicateWatchlist     = errors.New("duplicate watchlist")
+	ErrDuplicateWatchlistItem = errors.New("duplicate watchlist item")
+)
+
+// a Watchlist is a named, ordered list of movies kept by an owner
+// Items are only filled in when a single watchlist is fetched
+type Watchlist struct {
+	ID        int64            `json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Owner     string           `json:"owner" validate:"required,notblank,max=100"`
+	Name      string           `json:"name" validate:"required,notblank,max=100"`
+	ItemCount int32            `json:"item_count"`
+	Items     []*WatchlistItem `json:"items,omitempty"`
+	Version   int32            `json:"version"`
+}
+
+// a WatchlistItem is a movie on a watchlist
+// positions start at 1 and have no gaps, a Position of 0 when adding an item puts it at the end of the list
+// Title is the movie's title, filled in when items are read back
+type WatchlistItem struct {
+	ID          int64      `json:"id"`
+	WatchlistID int64      `json:"-"`
+	MovieID     int64      `json:"movie_id" validate:"required"`
+	Title       string     `json:"title,omitempty"`
+	Position    int32      `json:"position" validate:"min=0"`
+	Watched     bool       `json:"watched"`
+	WatchedAt   *time.Time `json:"watched_at,omitempty"`
+	AddedAt     time.Time  `json:"added_at"`
+}
+
+// ValidateWatchlist checks a watchlist against the rules in its validate tags
+func ValidateWatchlist(v *validator.Validator, watchlist *Watchlist) {
+	v.Struct(watchlist)
+}
+
+// ValidateWatchlistItem checks an item against the rules in its validate tags
+// a watched date only makes sense for a watched movie, and it must not be in the future
+func ValidateWatchlistItem(v *validator.Validator, item *WatchlistItem) {
+	v.Struct(item)
+	v.Check(item.Watched || item.WatchedAt == nil, "watched_at", "validation.watched_at_unwatched")
+	v.Check(item.WatchedAt == nil || !item.WatchedAt.After(time.Now()), "watched_at", "validation.not_future")
+}
+
+// methods for working with watchlists and their items
+// a WatchlistModel struct that wraps an sql.DB connection pool
+type WatchlistModel struct {
+	DB *sql.DB
+}
+
+// insert a new, empty watchlist
+// ErrDuplicateWatchlist is returned if the owner already has a watchlist with that name
+func (m WatchlistModel) Insert(watchlist *Watchlist) error {
+	query := `
+			INSERT INTO watchlists (owner, name)
+			VALUES ($1, $2)
+			RETURNING id, created_at, version`
+
+	err := m.DB.QueryRow(query, watchlist.Owner, watchlist.Name).Scan(&watchlist.ID, &watchlist.CreatedAt, &watchlist.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateWatchlist
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetching a watchlist, along with the number of items on it
+func (m WatchlistModel) Get(id int64) (*Watchlist, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+			SELECT id, created_at, owner, name, version,
+				(SELECT count(*) FROM watchlist_items WHERE watchlist_id = watchlists.id)
+			FROM watchlists
+			WHERE id = $1`
+
+	var watchlist Watchlist
+
+	err := m.DB.QueryRow(query, id).Scan(
+		&watchlist.ID,
+		&watchlist.CreatedAt,
+		&watchlist.Owner,
+		&watchlist.Name,
+		&watchlist.Version,
+		&watchlist.ItemCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &watchlist, nil
+}
+
+// GetAllForOwner returns a page of an owner's watchlists
+func (m WatchlistModel) GetAllForOwner(owner string, filters Filters) ([]*Watchlist, Metadata, error) {
+	// the sort column comes from the safelist, so it is safe to interpolate
+	query := fmt.Sprintf(`
+			SELECT count(*) OVER(), id, created_at, owner, name, version,
+				(SELECT count(*) FROM watchlist_items WHERE watchlist_id = watchlists.id)
+			FROM watchlists
+			WHERE owner = $1
+			ORDER BY %s %s, id ASC
+			LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.Query(query, owner, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	watchlists := []*Watchlist{}
+
+	for rows.Next() {
+		var watchlist Watchlist
+
+		err := rows.Scan(
+			&totalRecords,
+			&watchlist.ID,
+			&watchlist.CreatedAt,
+			&watchlist.Owner,
+			&watchlist.Name,
+			&watchlist.Version,
+			&watchlist.ItemCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		watchlists = append(watchlists, &watchlist)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return watchlists, metadata, nil
+}
+
+// update (rename) a watchlist
+// ErrDuplicateWatchlist is returned if the owner already has a watchlist with the new name
+func (m WatchlistModel) Update(watchlist *Watchlist) error {
+	query := `UPDATE watchlists
+			SET name = $1, version = version + 1
+			WHERE id = $2
+			RETURNING version`
+
+	err := m.DB.QueryRow(query, watchlist.Name, watchlist.ID).Scan(&watchlist.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateWatchlist
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// delete a watchlist, its items are removed along with it by the ON DELETE CASCADE on watchlist_items
+func (m WatchlistModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM watchlists
+			WHERE id = $1`
+
+	result, err := m.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetItems returns the items on a watchlist in order
+func (m WatchlistModel) GetItems(watchlistID int64) ([]*WatchlistItem, error) {
+	query := `
+			SELECT watchlist_items.id, watchlist_items.watchlist_id, watchlist_items.movie_id, movies.title,
+				watchlist_items.position, watchlist_items.watched, watchlist_items.watched_at, watchlist_items.added_at
+			FROM watchlist_items
+			INNER JOIN movies ON movies.id = watchlist_items.movie_id
+			WHERE watchlist_items.watchlist_id = $1
+			ORDER BY watchlist_items.position`
+
+	rows, err := m.DB.Query(query, watchlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// an empty, non-nil slice is written out as [] rather than null
+	items := []*WatchlistItem{}
+
+	for rows.Next() {
+		item, err := scanWatchlistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetItem fetches a single item from a watchlist
+func (m WatchlistModel) GetItem(watchlistID, itemID int64) (*WatchlistItem, error) {
+	if watchlistID < 1 || itemID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+			SELECT watchlist_items.id, watchlist_items.watchlist_id, watchlist_items.movie_id, movies.title,
+				watchlist_items.position, watchlist_items.watched, watchlist_items.watched_at, watchlist_items.added_at
+			FROM watchlist_items
+			INNER JOIN movies ON movies.id = watchlist_items.movie_id
+			WHERE watchlist_items.id = $1 AND watchlist_items.watchlist_id = $2`
+
+	item, err := scanWatchlistItem(m.DB.QueryRow(query, itemID, watchlistID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
+// scanWatchlistItem reads an item from either a single row or one of many rows
+func scanWatchlistItem(row interface{ Scan(dest ...any) error }) (*WatchlistItem, error) {
+	var item WatchlistItem
+
+	err := row.Scan(
+		&item.ID,
+		&item.WatchlistID,
+		&item.MovieID,
+		&item.Title,
+		&item.Position,
+		&item.Watched,
+		&item.WatchedAt,
+		&item.AddedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// InsertItem adds a movie to a watchlist at the item's position, moving the items at and after it down one place
+// a position of 0, or one past the end of the list, adds the movie to the end
+// ErrInvalidReference is returned if the movie does not exist, ErrDuplicateWatchlistItem if it is already on the list
+func (m WatchlistModel) InsertItem(item *WatchlistItem) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// rolling back after a commit is a no-op, so this only undoes the work if something failed
+	defer tx.Rollback()
+
+	// lock the watchlist so that concurrent changes to the same list cannot hand out the same position
+	count, err := lockWatchlist(tx, item.WatchlistID)
+	if err != nil {
+		return err
+	}
+
+	if item.Position < 1 || item.Position > count+1 {
+		item.Position = count + 1
+	}
+
+	_, err = tx.Exec(`UPDATE watchlist_items
+			SET position = position + 1
+			WHERE watchlist_id = $1 AND position >= $2`, item.WatchlistID, item.Position)
+	if err != nil {
+		return err
+	}
+
+	query := `
+			WITH inserted AS (
+				INSERT INTO watchlist_items (watchlist_id, movie_id, position, watched, watched_at)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING id, movie_id, added_at
+			)
+			SELECT inserted.id, inserted.added_at, movies.title
+			FROM inserted
+			INNER JOIN movies ON movies.id = inserted.movie_id`
+
+	args := []any{item.WatchlistID, item.MovieID, item.Position, item.Watched, item.WatchedAt}
+
+	err = tx.QueryRow(query, args...).Scan(&item.ID, &item.AddedAt, &item.Title)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrInvalidReference
+		case errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "watchlist_items_movie_unique":
+			return ErrDuplicateWatchlistItem
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateItem saves the watched state of an item and moves it to its position, shifting the items in between
+// positions past the end of the list move the item to the end
+func (m WatchlistModel) UpdateItem(item *WatchlistItem) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockWatchlist(tx, item.WatchlistID)
+	if err != nil {
+		return err
+	}
+
+	if item.Position < 1 || item.Position > count {
+		item.Position = count
+	}
+
+	var current int32
+	err = tx.QueryRow(`SELECT position FROM watchlist_items WHERE id = $1 AND watchlist_id = $2`,
+		item.ID, item.WatchlistID).Scan(&current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// move the item and close up the items between its old and new positions in one statement,
+	// the deferrable unique constraint on position is only checked once the statement has finished
+	query := `UPDATE watchlist_items
+			SET position = CASE
+				WHEN id = $2 THEN $4
+				WHEN $4 < $3 THEN position + 1
+				ELSE position - 1
+			END
+			WHERE watchlist_id = $1 AND (id = $2 OR position BETWEEN LEAST($3, $4) AND GREATEST($3, $4))`
+
+	_, err = tx.Exec(query, item.WatchlistID, item.ID, current, item.Position)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE watchlist_items
+			SET watched = $1, watched_at = $2
+			WHERE id = $3`, item.Watched, item.WatchedAt, item.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteItem removes an item from a watchlist
+// the gap it leaves is closed by a trigger on watchlist_items, which also handles items removed when a movie is deleted
+func (m WatchlistModel) DeleteItem(watchlistID, itemID int64) error {
+	if watchlistID < 1 || itemID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM watchlist_items
+			WHERE id = $1 AND watchlist_id = $2`
+
+	result, err := m.DB.Exec(query, itemID, watchlistID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// lockWatchlist locks a watchlist row for the rest of the transaction and returns the number of items on it
+func lockWatchlist(tx *sql.Tx, watchlistID int64) (int32, error) {
+	var count int32
+
+	err := tx.QueryRow(`
+			SELECT (SELECT count(*) FROM watchlist_items WHERE watchlist_id = watchlists.id)
+			FROM watchlists
+			WHERE id = $1
+			FOR UPDATE`, watchlistID).Scan(&count)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,528 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// the states of a webhook delivery
+// a pending delivery is retried until it succeeds or runs out of attempts, and then it is failed
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDeliveryStatuses lists every delivery state, for validating filters
+var WebhookDeliveryStatuses = []string{WebhookDeliveryPending, WebhookDeliverySucceeded, WebhookDeliveryFailed}
+
+// a Webhook is a subscription to the change feed, the events of the chosen types are POSTed to its URL
+// an empty EventTypes subscribes to every type
+// the Secret signs each delivery, it is only ever written out in the response to creating the webhook
+type Webhook struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	URL        string    `json:"url" validate:"required,url,max=2000"`
+	EventTypes []string  `json:"event_types" validate:"unique,dive,oneof=movie.created movie.updated movie.deleted"`
+	// the secret is never written out as part of the webhook, so it is checked by ValidateWebhook rather than a tag
+	Secret string `json:"-"`
+	// a webhook that fails persistently is disabled, and stays that way until it is enabled again
+	Enabled        bool       `json:"enabled"`
+	FailingSince   *time.Time `json:"failing_since,omitempty"`
+	DisabledAt     *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason string     `json:"disabled_reason,omitempty"`
+	Version        int32      `json:"version"`
+}
+
+// a WebhookDelivery is the sending of one event to one webhook, along with the log of its attempts
+// NextAttemptAt is only set while the delivery is pending, and CompletedAt once it is not
+type WebhookDelivery struct {
+	ID            int64             `json:"id"`
+	EventID       int64             `json:"event_id"`
+	EventType     string            `json:"event_type"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Status        string            `json:"status"`
+	AttemptCount  int32             `json:"attempt_count"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"`
+	CompletedAt   *time.Time        `json:"completed_at,omitempty"`
+	Attempts      []*WebhookAttempt `json:"attempts"`
+}
+
+// a WebhookAttempt is a single POST of a delivery
+// StatusCode is 0 and Error is set when no response came back
+type WebhookAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMS  int64     `json:"duration_ms"`
+}
+
+// Succeeded reports whether the receiver accepted the delivery, which it does with any 2xx response
+func (a *WebhookAttempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// a WebhookJob is a delivery that is due, with everything needed to send it
+type WebhookJob struct {
+	DeliveryID int64
+	WebhookID  int64
+	URL        string
+	Secret     string
+	// Attempt is the number of the attempt about to be made, starting at 1
+	Attempt int
+	Event   Event
+}
+
+// the limits on the length of a webhook's secret
+const (
+	WebhookSecretMinChars = 16
+	WebhookSecretMaxChars = 200
+)
+
+// ValidateWebhook checks a webhook against the rules in its validate tags, and its secret
+// Struct skips fields that are not in the JSON, so the secret has to be checked here
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	v.Struct(webhook)
+
+	v.Check(webhook.Secret != "", "secret", "validation.required")
+	v.Check(validator.MinRunes(webhook.Secret, WebhookSecretMinChars), "secret", "validation.min_chars", strconv.Itoa(WebhookSecretMinChars))
+	v.Check(validator.MaxRunes(webhook.Secret, WebhookSecretMaxChars), "secret", "validation.max_chars", strconv.Itoa(WebhookSecretMaxChars))
+}
+
+// methods for working with webhooks and their deliveries
+// a WebhookModel struct that wraps an sql.DB connection pool
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// insert a new webhook, it only gets the events that happen from now on
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+			INSERT INTO webhooks (url, event_types, secret, last_event_id)
+			VALUES ($1, $2, $3, (SELECT coalesce(max(id), 0) FROM outbox))
+			RETURNING id, created_at, enabled, version`
+
+	args := []any{webhook.URL, pq.Array(webhook.EventTypes), webhook.Secret}
+
+	return m.DB.QueryRow(query, args...).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.Enabled, &webhook.Version)
+}
+
+// fetching a webhook
+func (m WebhookModel) Get(id int64) (*Webhook, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+			SELECT id, created_at, url, event_types, secret, enabled, failing_since, disabled_at, disabled_reason, version
+			FROM webhooks
+			WHERE id = $1`
+
+	var webhook Webhook
+
+	err := m.DB.QueryRow(query, id).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.URL,
+		pq.Array(&webhook.EventTypes),
+		&webhook.Secret,
+		&webhook.Enabled,
+		&webhook.FailingSince,
+		&webhook.DisabledAt,
+		&webhook.DisabledReason,
+		&webhook.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+// GetAll returns a page of the webhooks
+func (m WebhookModel) GetAll(filters Filters) ([]*Webhook, Metadata, error) {
+	// the sort column comes from the safelist, so it is safe to interpolate
+	query := fmt.Sprintf(`
+			SELECT count(*) OVER(), id, created_at, url, event_types, enabled, failing_since, disabled_at, disabled_reason, version
+			FROM webhooks
+			ORDER BY %s %s, id ASC
+			LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.Query(query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&totalRecords,
+			&webhook.ID,
+			&webhook.CreatedAt,
+			&webhook.URL,
+			pq.Array(&webhook.EventTypes),
+			&webhook.Enabled,
+			&webhook.FailingSince,
+			&webhook.DisabledAt,
+			&webhook.DisabledReason,
+			&webhook.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return webhooks, metadata, nil
+}
+
+// update a webhook's settings, including whether it is enabled
+func (m WebhookModel) Update(webhook *Webhook) error {
+	query := `UPDATE webhooks
+			SET url = $1, event_types = $2, secret = $3, enabled = $4, failing_since = $5, disabled_at = $6, disabled_reason = $7,
+				version = version + 1
+			WHERE id = $8
+			RETURNING version`
+
+	args := []any{
+		webhook.URL, pq.Array(webhook.EventTypes), webhook.Secret, webhook.Enabled,
+		webhook.FailingSince, webhook.DisabledAt, webhook.DisabledReason, webhook.ID,
+	}
+
+	err := m.DB.QueryRow(query, args...).Scan(&webhook.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// delete a webhook, its deliveries and their attempts are removed along with it by the ON DELETE CASCADEs
+func (m WebhookModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `DELETE FROM webhooks
+			WHERE id = $1`
+
+	result, err := m.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetDeliveries returns a page of a webhook's deliveries, each with its attempts, optionally only those in one state
+func (m WebhookModel) GetDeliveries(webhookID int64, status string, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	// the sort column comes from the safelist, so it is safe to interpolate
+	query := fmt.Sprintf(`
+			SELECT count(*) OVER(), webhook_deliveries.id, webhook_deliveries.event_id, outbox.event_type,
+				webhook_deliveries.created_at, webhook_deliveries.status, webhook_deliveries.attempt_count,
+				webhook_deliveries.next_attempt_at, webhook_deliveries.completed_at
+			FROM webhook_deliveries
+			INNER JOIN outbox ON outbox.id = webhook_deliveries.event_id
+			WHERE webhook_deliveries.webhook_id = $1
+			AND ($2 = '' OR webhook_deliveries.status = $2)
+			ORDER BY webhook_deliveries.%s %s, webhook_deliveries.id %[2]s
+			LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := m.DB.Query(query, webhookID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+	byID := make(map[int64]*WebhookDelivery)
+
+	for rows.Next() {
+		delivery := WebhookDelivery{Attempts: []*WebhookAttempt{}}
+
+		err := rows.Scan(
+			&totalRecords,
+			&delivery.ID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.CreatedAt,
+			&delivery.Status,
+			&delivery.AttemptCount,
+			&delivery.NextAttemptAt,
+			&delivery.CompletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+		byID[delivery.ID] = &delivery
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// the attempts for the whole page are read in one go
+	ids := make([]int64, len(deliveries))
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+	}
+
+	query = `
+			SELECT delivery_id, attempted_at, status_code, error, duration_ms
+			FROM webhook_attempts
+			WHERE delivery_id = ANY($1)
+			ORDER BY id`
+
+	attemptRows, err := m.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var deliveryID int64
+		var attempt WebhookAttempt
+
+		err := attemptRows.Scan(&deliveryID, &attempt.AttemptedAt, &attempt.StatusCode, &attempt.Error, &attempt.DurationMS)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		byID[deliveryID].Attempts = append(byID[deliveryID].Attempts, &attempt)
+	}
+
+	if err = attemptRows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+// QueueDeliveries adds a pending delivery for each event in the change feed that an enabled webhook subscribes to,
+// and returns how many were added
+// each webhook keeps how far through the feed it has got, events that happen while a webhook is disabled are skipped
+//
+// events are committed in the order of their ids, so once the newest id has been read every event up to it is there,
+// and running this from more than one server at a time only tries to queue the same deliveries twice, which is ignored
+func (m WebhookModel) QueueDeliveries() (int64, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var last int64
+	err = tx.QueryRow(`SELECT coalesce(max(id), 0) FROM outbox`).Scan(&last)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+			INSERT INTO webhook_deliveries (webhook_id, event_id, next_attempt_at)
+			SELECT webhooks.id, outbox.id, NOW()
+			FROM webhooks
+			INNER JOIN outbox ON outbox.id > webhooks.last_event_id AND outbox.id <= $1
+			WHERE webhooks.enabled
+			AND (cardinality(webhooks.event_types) = 0 OR outbox.event_type = ANY(webhooks.event_types))
+			ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	result, err := tx.Exec(query, last)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE webhooks SET last_event_id = $1 WHERE last_event_id < $1`, last)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// ClaimDue returns up to limit pending deliveries which are due, to enabled webhooks, oldest first
+// each one is leased by pushing its next attempt back by the lease, so that no other worker picks it up in the meantime
+// and a delivery whose worker stopped before recording the attempt is tried again once the lease runs out
+func (m WebhookModel) ClaimDue(limit int, lease time.Duration) ([]*WebhookJob, error) {
+	query := `
+			UPDATE webhook_deliveries
+			SET next_attempt_at = NOW() + make_interval(secs => $2)
+			FROM webhooks, outbox
+			WHERE webhook_deliveries.id IN (
+				SELECT webhook_deliveries.id
+				FROM webhook_deliveries
+				INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+				WHERE webhook_deliveries.status = 'pending' AND webhook_deliveries.next_attempt_at <= NOW() AND webhooks.enabled
+				ORDER BY webhook_deliveries.next_attempt_at, webhook_deliveries.id
+				LIMIT $1
+				FOR UPDATE OF webhook_deliveries SKIP LOCKED
+			)
+			AND webhooks.id = webhook_deliveries.webhook_id
+			AND outbox.id = webhook_deliveries.event_id
+			RETURNING webhook_deliveries.id, webhooks.id, webhooks.url, webhooks.secret, webhook_deliveries.attempt_count,
+				outbox.id, outbox.occurred_at, outbox.event_type, outbox.resource_id, outbox.payload`
+
+	rows, err := m.DB.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*WebhookJob{}
+
+	for rows.Next() {
+		var job WebhookJob
+
+		err := rows.Scan(
+			&job.DeliveryID,
+			&job.WebhookID,
+			&job.URL,
+			&job.Secret,
+			&job.Attempt,
+			&job.Event.ID,
+			&job.Event.OccurredAt,
+			&job.Event.Type,
+			&job.Event.ResourceID,
+			&job.Event.Data,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// the count is of the attempts already made
+		job.Attempt++
+		jobs = append(jobs, &job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// RecordAttempt logs an attempt at a delivery and moves the delivery on: it has succeeded, it is retried at retryAt,
+// or it has failed for good when retryAt is the zero time
+// a webhook whose attempts have all failed for at least disableAfter is disabled, and true is returned when that happens
+// ErrRecordNotFound is returned if the webhook has been deleted in the meantime
+func (m WebhookModel) RecordAttempt(job *WebhookJob, attempt *WebhookAttempt, retryAt time.Time, disableAfter time.Duration) (bool, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var webhook Webhook
+	err = tx.QueryRow(`SELECT enabled, failing_since FROM webhooks WHERE id = $1 FOR UPDATE`, job.WebhookID).
+		Scan(&webhook.Enabled, &webhook.FailingSince)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrRecordNotFound
+		default:
+			return false, err
+		}
+	}
+
+	query := `
+			INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+			VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.Exec(query, job.DeliveryID, attempt.AttemptedAt, attempt.StatusCode, attempt.Error, attempt.DurationMS)
+	if err != nil {
+		return false, err
+	}
+
+	status := WebhookDeliveryPending
+	switch {
+	case attempt.Succeeded():
+		status = WebhookDeliverySucceeded
+	case retryAt.IsZero():
+		status = WebhookDeliveryFailed
+	}
+
+	query = `UPDATE webhook_deliveries
+			SET status = $1, attempt_count = attempt_count + 1,
+				next_attempt_at = CASE WHEN $1 = 'pending' THEN $2::timestamptz END,
+				completed_at = CASE WHEN $1 <> 'pending' THEN NOW() END
+			WHERE id = $3`
+
+	_, err = tx.Exec(query, status, nullTime(retryAt), job.DeliveryID)
+	if err != nil {
+		return false, err
+	}
+
+	// a success ends the run of failures, and a failure starts one or carries it on
+	disabled := false
+	if attempt.Succeeded() {
+		webhook.FailingSince = nil
+	} else {
+		if webhook.FailingSince == nil {
+			webhook.FailingSince = &attempt.AttemptedAt
+		}
+		disabled = webhook.Enabled && attempt.AttemptedAt.Sub(*webhook.FailingSince) >= disableAfter
+	}
+
+	query = `UPDATE webhooks
+			SET failing_since = $1,
+				enabled = enabled AND NOT $2,
+				disabled_at = CASE WHEN $2 THEN NOW() ELSE disabled_at END,
+				disabled_reason = CASE WHEN $2 THEN $3 ELSE disabled_reason END,
+				version = CASE WHEN $2 THEN version + 1 ELSE version END
+			WHERE id = $4`
+
+	reason := fmt.Sprintf("every delivery attempt failed for %s", disableAfter)
+
+	_, err = tx.Exec(query, webhook.FailingSince, disabled, reason, job.WebhookID)
+	if err != nil {
+		return false, err
+	}
+
+	return disabled, tx.Commit()
+}
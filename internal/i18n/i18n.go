@@ -0,0 +1,171 @@
+// Package i18n holds the translated messages returned by the API and picks the
+// locale to use for a request from its Accept-Language header.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is used when the client does not ask for a locale we support
+// it is also the fallback for any message missing from another catalogue
+const DefaultLocale = "en"
+
+// the message catalogues, one JSON file per locale mapping message keys to text
+//
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// catalogues maps each locale to its messages, loaded once at startup
+var catalogues = mustLoadCatalogues()
+
+// mustLoadCatalogues reads every embedded catalogue
+// the files are compiled into the binary, so a broken one is a programming error and we panic
+func mustLoadCatalogues() map[string]map[string]string {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[string]string, len(entries))
+
+	for _, entry := range entries {
+		content, err := localeFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(content, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalogue %s: %s", entry.Name(), err))
+		}
+
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+
+	if _, ok := loaded[DefaultLocale]; !ok {
+		panic("i18n: missing catalogue for the default locale")
+	}
+
+	return loaded
+}
+
+// Locales returns the supported locales in alphabetical order
+func Locales() []string {
+	locales := make([]string, 0, len(catalogues))
+	for locale := range catalogues {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	return locales
+}
+
+// IsKey reports whether the message is a key in the default catalogue, rather than literal text
+func IsKey(message string) bool {
+	_, ok := catalogues[DefaultLocale][message]
+	return ok
+}
+
+// a Localizer translates message keys into the text for a single locale
+// a nil *Localizer is valid and uses the default locale
+type Localizer struct {
+	locale   string
+	messages map[string]string
+}
+
+// New returns a Localizer for the locale, falling back to the default locale if it is not supported
+func New(locale string) *Localizer {
+	messages, ok := catalogues[locale]
+	if !ok {
+		locale, messages = DefaultLocale, catalogues[DefaultLocale]
+	}
+
+	return &Localizer{locale: locale, messages: messages}
+}
+
+// Negotiate picks the best supported locale from an Accept-Language header, e.g. "fr-CA,fr;q=0.9,en;q=0.5"
+// a region-specific tag matches its base language, so "fr-CA" gets the "fr" catalogue
+func Negotiate(acceptLanguage string) *Localizer {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: strings.ToLower(tag), q: q})
+		}
+	}
+
+	// the most preferred tags come first, ties keep the order the client sent them in
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	for _, c := range candidates {
+		if _, ok := catalogues[c.tag]; ok {
+			return New(c.tag)
+		}
+
+		base, _, _ := strings.Cut(c.tag, "-")
+		if _, ok := catalogues[base]; ok {
+			return New(base)
+		}
+	}
+
+	return New(DefaultLocale)
+}
+
+// Locale returns the locale the Localizer translates into, e.g. "fr"
+func (l *Localizer) Locale() string {
+	if l == nil {
+		return DefaultLocale
+	}
+
+	return l.locale
+}
+
+// T returns the text for a message key, formatted with any arguments
+// keys missing from the locale fall back to the default locale, and anything that is not a key is returned as-is,
+// so existing literal messages keep working
+func (l *Localizer) T(key string, args ...any) string {
+	text, ok := "", false
+	if l != nil {
+		text, ok = l.messages[key]
+	}
+	if !ok {
+		text, ok = catalogues[DefaultLocale][key]
+	}
+	if !ok {
+		text = key
+	}
+
+	// only format when there is a verb to fill, so literal messages never pick up %!(EXTRA ...) noise
+	if len(args) > 0 && strings.Contains(text, "%") {
+		text = fmt.Sprintf(text, args...)
+	}
+
+	return text
+}
@@ -0,0 +1,115 @@
+package i18n
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"DE", "de"},
+		{"es-MX", "es"},
+		{"fr-CA", "fr"},
+		{"fr-CA,fr;q=0.9,en;q=0.5", "fr"},
+		{"ja, de;q=0.5", "de"},
+		{"ja, zh-Hant", "en"},
+		// the highest quality wins, whatever the order
+		{"en;q=0.5, es;q=0.8, de;q=0.7", "es"},
+		{"de;q=0.8, fr", "fr"},
+		// ties keep the order the client sent them in
+		{"es;q=0.5, de;q=0.5", "es"},
+		// a q of 0 means the locale is not acceptable
+		{"fr;q=0, de;q=0.1", "de"},
+		{"fr;q=0", "en"},
+		// entries that cannot be parsed are skipped
+		{"fr;q=abc, es", "es"},
+		{" , es-419 ; q=0.4", "es"},
+		{"*", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := Negotiate(tt.header).Locale()
+			if got != tt.want {
+				t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		l    *Localizer
+		key  string
+		args []any
+		want string
+	}{
+		{New("en"), "validation.required", nil, "must be provided"},
+		{New("en"), "validation.max_chars", []any{"500"}, "must not be more than 500 characters long"},
+		// unsupported locales and a nil Localizer use the default locale
+		{New("xx"), "validation.required", nil, "must be provided"},
+		{nil, "validation.required", nil, "must be provided"},
+		// literal text is returned as it is, without formatting noise
+		{New("fr"), "must be a sensible year", []any{1}, "must be a sensible year"},
+		{New("fr"), "100% literal", nil, "100% literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.l.Locale()+" "+tt.key, func(t *testing.T) {
+			if got := tt.l.T(tt.key, tt.args...); got != tt.want {
+				t.Errorf("T(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	if got := New("fr").T("validation.required"); got == New("en").T("validation.required") {
+		t.Errorf("fr T(validation.required) = %q, want a French message", got)
+	}
+}
+
+// verbRX matches the fmt verbs in a message, e.g. %s, %d or %[2]s
+var verbRX = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+func TestCataloguesMatch(t *testing.T) {
+	if got := Locales(); !slices.Equal(got, []string{"de", "en", "es", "fr"}) {
+		t.Errorf("Locales() = %v, want [de en es fr]", got)
+	}
+
+	base := catalogues[DefaultLocale]
+	baseKeys := slices.Sorted(maps.Keys(base))
+
+	for locale, messages := range catalogues {
+		if locale == DefaultLocale {
+			continue
+		}
+
+		t.Run(locale, func(t *testing.T) {
+			for _, key := range baseKeys {
+				if _, ok := messages[key]; !ok {
+					t.Errorf("missing %s", key)
+				}
+			}
+
+			for key, text := range messages {
+				want, ok := base[key]
+				if !ok {
+					t.Errorf("%s is not in the %s catalogue", key, DefaultLocale)
+					continue
+				}
+
+				// every translation must take the same arguments, in the same order, as the default message
+				got, wantVerbs := verbRX.FindAllString(text, -1), verbRX.FindAllString(want, -1)
+				if !slices.Equal(got, wantVerbs) {
+					t.Errorf("%s has verbs %q, want %q", key, got, wantVerbs)
+				}
+			}
+		})
+	}
+}
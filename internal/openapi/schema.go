@@ -0,0 +1,324 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/TaskMasterErnest/greenlight/internal/validator"
+)
+
+// a Generator builds schemas for Go types, following the same rules as encoding/json
+// named struct types become components and are referred to with $ref, so each is only described once
+// the constraints in validate tags are carried over, e.g. max=100 on a string becomes maxLength: 100
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+	defined map[reflect.Type]*Schema
+}
+
+// NewGenerator returns a Generator with no components
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+		defined: make(map[reflect.Type]*Schema),
+	}
+}
+
+// Define sets the schema used for a type, for types which reflection gets wrong,
+// such as those with their own MarshalJSON method
+func (g *Generator) Define(t reflect.Type, s *Schema) {
+	g.defined[t] = s
+}
+
+// Add puts a schema in the components under the given name, for schemas which do not come from a Go type
+func (g *Generator) Add(name string, s *Schema) {
+	if _, taken := g.schemas[name]; taken {
+		panic(fmt.Sprintf("openapi: two schemas are named %s", name))
+	}
+
+	g.schemas[name] = s
+}
+
+// DefineComponent adds a schema to the components under the given name, and uses a reference to it for the type
+func (g *Generator) DefineComponent(t reflect.Type, name string, s *Schema) {
+	g.Add(name, s)
+	g.defined[t] = Ref(name)
+}
+
+// Schemas returns the component schemas built so far, keyed by name
+func (g *Generator) Schemas() map[string]*Schema {
+	return g.schemas
+}
+
+// Component returns the component schema for a type, building it if need be
+// it is used to refine a generated schema with constraints that are not in validate tags
+func (g *Generator) Component(t reflect.Type) *Schema {
+	g.Schema(t)
+	return g.schemas[g.names[t]]
+}
+
+// Input returns the schema for a request body type, which is a named struct
+// handlers decode into their own input structs and then check the model they build from them, so a field without
+// a validate tag takes the one from the model's field of the same JSON name, e.g. movie_id on data.WatchlistItem
+func (g *Generator) Input(t, model reflect.Type) *Schema {
+	if name, ok := g.names[t]; ok {
+		return Ref(name)
+	}
+
+	tags := make(map[string]string)
+	for i := range model.NumField() {
+		sf := model.Field(i)
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			tags[fieldName(sf)] = tag
+		}
+	}
+
+	return g.register(t, func() *Schema { return g.structSchema(t, tags) })
+}
+
+// Schema returns the schema for a type, which is a $ref for named structs
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	if s, ok := g.defined[t]; ok {
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.Schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// []byte is written out as base64
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(t.Elem())}
+	case reflect.Struct:
+		if t == reflect.TypeFor[time.Time]() {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t, nil)
+		}
+		return g.component(t)
+	default:
+		// interfaces can hold anything
+		return &Schema{}
+	}
+}
+
+// component registers a named struct type in the components, and returns a reference to it
+func (g *Generator) component(t reflect.Type) *Schema {
+	if name, ok := g.names[t]; ok {
+		return Ref(name)
+	}
+
+	return g.register(t, func() *Schema { return g.structSchema(t, nil) })
+}
+
+// register adds the schema built for a type to the components under the type's name
+func (g *Generator) register(t reflect.Type, build func() *Schema) *Schema {
+	name := componentName(t)
+	if _, taken := g.schemas[name]; taken {
+		panic(fmt.Sprintf("openapi: two types are named %s", name))
+	}
+
+	// the name is reserved before the fields are walked, so that a type which refers to itself ends up as a $ref
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *build()
+
+	return Ref(name)
+}
+
+// componentName is the type's name with its first letter in upper case, so movieInput is documented as MovieInput
+func componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// fieldName returns the name a field has in JSON
+func fieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+
+	return name
+}
+
+// structSchema describes the fields of a struct, with embedded structs flattened as encoding/json does
+// tags holds validate tags to use for fields which do not have their own, keyed by JSON name
+func (g *Generator) structSchema(t reflect.Type, tags map[string]string) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := g.structSchema(ft, tags)
+				for key, prop := range embedded.Properties {
+					s.Properties[key] = prop
+				}
+				s.Required = append(s.Required, embedded.Required...)
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok {
+			tag = tags[name]
+		}
+
+		prop := g.Schema(sf.Type)
+		if constrain(&prop, tag) {
+			s.Required = append(s.Required, name)
+		}
+
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// constrain adds the rules from a validate tag to a field's schema, and reports whether the field is required
+// the schema is copied first, as it may be shared with other fields, and a $ref is wrapped as the
+// referenced schema must not be changed
+func constrain(s **Schema, tag string) bool {
+	parsed := validator.ParseTag(tag)
+	if len(parsed.Rules) == 0 && len(parsed.ElemRules) == 0 {
+		return false
+	}
+
+	var schema *Schema
+	if (*s).Ref != "" {
+		schema = &Schema{OneOf: []*Schema{*s}}
+	} else {
+		copied := **s
+		schema = &copied
+	}
+	*s = schema
+
+	required := false
+	for _, rule := range parsed.Rules {
+		if rule.Name == "required" {
+			required = true
+		}
+		applyRule(schema, rule)
+	}
+
+	if schema.Items != nil && len(parsed.ElemRules) > 0 {
+		items := *schema.Items
+		for _, rule := range parsed.ElemRules {
+			applyRule(&items, rule)
+		}
+		schema.Items = &items
+	}
+
+	return required
+}
+
+// applyRule sets the keywords for a single validate rule
+// rules which cannot be expressed in a schema, such as comparisons with another field, are left out
+func applyRule(s *Schema, rule validator.TagRule) {
+	n, _ := strconv.ParseFloat(rule.Param, 64)
+
+	switch rule.Name {
+	case "required", "notblank":
+		// a required string cannot be empty
+		if s.Type == "string" && s.MinLength == nil {
+			s.MinLength = Int(1)
+		}
+		if rule.Name == "notblank" {
+			s.Pattern = `\S`
+		}
+	case "min", "max", "len":
+		setSize(s, rule.Name, n)
+	case "oneof":
+		for _, value := range strings.Fields(rule.Param) {
+			s.Enum = append(s.Enum, enumValue(s, value))
+		}
+	case "unique":
+		s.UniqueItems = true
+	case "email":
+		s.Format = "email"
+	case "url":
+		s.Format = "uri"
+	case "uuid":
+		s.Format = "uuid"
+	}
+}
+
+// setSize applies a size limit to the keyword that matches the type: the length of a string,
+// the number of items in an array or the value of a number
+func setSize(s *Schema, rule string, n float64) {
+	switch s.Type {
+	case "string":
+		if rule == "min" || rule == "len" {
+			s.MinLength = Int(int(n))
+		}
+		if rule == "max" || rule == "len" {
+			s.MaxLength = Int(int(n))
+		}
+	case "object":
+		// the size of a map is not described
+	case "array":
+		if rule == "min" || rule == "len" {
+			s.MinItems = Int(int(n))
+		}
+		if rule == "max" || rule == "len" {
+			s.MaxItems = Int(int(n))
+		}
+	default:
+		if rule == "min" || rule == "len" {
+			s.Minimum = Float(n)
+		}
+		if rule == "max" || rule == "len" {
+			s.Maximum = Float(n)
+		}
+	}
+}
+
+// enumValue converts a oneof value to the type of the schema, so that integer enums are written as numbers
+func enumValue(s *Schema, value string) any {
+	if s.Type == "integer" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type address struct {
+	City string `json:"city" validate:"required,max=50"`
+}
+
+type timestamps struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type person struct {
+	timestamps
+	Name     string            `json:"name" validate:"required,notblank,max=100"`
+	Age      int32             `json:"age,omitempty" validate:"omitempty,min=0,max=150"`
+	Role     string            `json:"role" validate:"oneof=actor director"`
+	Rating   int64             `json:"rating" validate:"oneof=1 2 3"`
+	Tags     []string          `json:"tags" validate:"unique,max=5,dive,min=2"`
+	Home     *address          `json:"home"`
+	Work     address           `json:"work" validate:"required"`
+	Photo    []byte            `json:"photo"`
+	Extra    map[string]string `json:"extra" validate:"max=3"`
+	Friends  []*person         `json:"friends"`
+	Ignored  string            `json:"-"`
+	Untagged bool
+	private  string
+}
+
+func TestSchema(t *testing.T) {
+	g := NewGenerator()
+
+	if got := g.Schema(reflect.TypeFor[person]()); got.Ref != "#/components/schemas/Person" {
+		t.Fatalf("Schema() = %+v, want a $ref to Person", got)
+	}
+
+	s := g.Schemas()["Person"]
+	if s == nil {
+		t.Fatal("Person is not in the components")
+	}
+
+	// json names are used, and fields left out of JSON are left out of the schema
+	for _, name := range []string{"created_at", "name", "age", "role", "rating", "tags", "home", "work", "photo", "extra", "friends", "Untagged"} {
+		if s.Properties[name] == nil {
+			t.Errorf("property %s is missing", name)
+		}
+	}
+	for _, name := range []string{"-", "Ignored", "private", "timestamps"} {
+		if s.Properties[name] != nil {
+			t.Errorf("property %s should not be described", name)
+		}
+	}
+
+	if !reflect.DeepEqual(s.Required, []string{"name", "work"}) {
+		t.Errorf("required = %v, want name and work", s.Required)
+	}
+
+	name := s.Properties["name"]
+	if name.Type != "string" || *name.MinLength != 1 || *name.MaxLength != 100 || name.Pattern != `\S` {
+		t.Errorf("name = %+v, want a non-blank string of 1 to 100 characters", name)
+	}
+
+	age := s.Properties["age"]
+	if age.Format != "int32" || *age.Minimum != 0 || *age.Maximum != 150 {
+		t.Errorf("age = %+v, want an int32 from 0 to 150", age)
+	}
+
+	if got := s.Properties["role"].Enum; !reflect.DeepEqual(got, []any{"actor", "director"}) {
+		t.Errorf("role enum = %v, want actor and director", got)
+	}
+	// integer enums are written as numbers
+	if got := s.Properties["rating"].Enum; !reflect.DeepEqual(got, []any{int64(1), int64(2), int64(3)}) {
+		t.Errorf("rating enum = %v, want 1, 2 and 3", got)
+	}
+
+	tags := s.Properties["tags"]
+	if tags.Type != "array" || !tags.UniqueItems || *tags.MaxItems != 5 || *tags.Items.MinLength != 2 {
+		t.Errorf("tags = %+v, items %+v, want up to 5 unique items of at least 2 characters", tags, tags.Items)
+	}
+
+	// a constraint on a $ref is put on a wrapper, so the component itself is left alone
+	if got := s.Properties["home"]; got.Ref != "#/components/schemas/Address" {
+		t.Errorf("home = %+v, want a $ref to Address", got)
+	}
+	if got := s.Properties["work"]; len(got.OneOf) != 1 || got.OneOf[0].Ref != "#/components/schemas/Address" {
+		t.Errorf("work = %+v, want a wrapped $ref to Address", got)
+	}
+	if got := g.Schemas()["Address"]; got.Properties["city"] == nil || *got.Properties["city"].MaxLength != 50 {
+		t.Errorf("Address = %+v, want its city", got)
+	}
+
+	if got := s.Properties["photo"]; got.Type != "string" || got.Format != "byte" {
+		t.Errorf("photo = %+v, want a base64 string", got)
+	}
+	if got := s.Properties["extra"]; got.Type != "object" || got.AdditionalProperties.(*Schema).Type != "string" || got.MinItems != nil || got.MaxItems != nil {
+		t.Errorf("extra = %+v, want a map of strings without a size", got)
+	}
+	if got := s.Properties["created_at"]; got.Type != "string" || got.Format != "date-time" {
+		t.Errorf("created_at = %+v, want a date-time", got)
+	}
+
+	// a type that refers to itself ends up as a $ref rather than looping
+	if got := s.Properties["friends"].Items; got.Ref != "#/components/schemas/Person" {
+		t.Errorf("friends items = %+v, want a $ref to Person", got)
+	}
+}
+
+type personInput struct {
+	Name *string `json:"name"`
+	Role *string `json:"role" validate:"max=10"`
+}
+
+func TestInput(t *testing.T) {
+	g := NewGenerator()
+
+	ref := g.Input(reflect.TypeFor[personInput](), reflect.TypeFor[person]())
+	if ref.Ref != "#/components/schemas/PersonInput" {
+		t.Fatalf("Input() = %+v, want a $ref to PersonInput", ref)
+	}
+
+	// a field without a validate tag takes the model's, and one with its own keeps it
+	s := g.Schemas()["PersonInput"]
+	if got := s.Properties["name"]; *got.MaxLength != 100 {
+		t.Errorf("name = %+v, want the model's constraints", got)
+	}
+	if got := s.Properties["role"]; *got.MaxLength != 10 || got.Enum != nil {
+		t.Errorf("role = %+v, want its own constraints", got)
+	}
+	if !reflect.DeepEqual(s.Required, []string{"name"}) {
+		t.Errorf("required = %v, want name", s.Required)
+	}
+
+	// the input is only described once
+	if again := g.Input(reflect.TypeFor[personInput](), reflect.TypeFor[person]()); again.Ref != ref.Ref {
+		t.Errorf("Input() = %+v the second time, want the same $ref", again)
+	}
+}
+
+func TestDefine(t *testing.T) {
+	g := NewGenerator()
+
+	g.Define(reflect.TypeFor[time.Duration](), &Schema{Type: "string"})
+	if got := g.Schema(reflect.TypeFor[time.Duration]()); got.Type != "string" {
+		t.Errorf("Schema(time.Duration) = %+v, want the defined schema", got)
+	}
+
+	g.DefineComponent(reflect.TypeFor[address](), "Place", &Schema{Type: "string"})
+	if got := g.Schema(reflect.TypeFor[*address]()); got.Ref != "#/components/schemas/Place" {
+		t.Errorf("Schema(*address) = %+v, want a $ref to Place", got)
+	}
+	if got := g.Schemas()["Place"]; got.Type != "string" {
+		t.Errorf("Place = %+v, want the defined schema", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("adding a second schema named Place did not panic")
+		}
+	}()
+	g.Add("Place", &Schema{})
+}
@@ -0,0 +1,463 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Field is passed to a rule and describes the value being checked
+type Field struct {
+	// Value is the field's value, with any pointers already dereferenced
+	Value reflect.Value
+	// Param is the text after the "=" in the tag, e.g. "500" for max=500
+	Param string
+	// Parent is the struct that the field belongs to, for rules that compare fields
+	Parent reflect.Value
+}
+
+// RuleFunc reports whether a field passes a rule
+type RuleFunc func(f Field) bool
+
+// a rule is a named check along with the message that is added when the check fails
+// the message is an i18n key and the arguments used to format its text
+type rule struct {
+	check   RuleFunc
+	message func(f Field) (string, []any)
+}
+
+// the registered rules, keyed by the name used in the validate tag
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]rule{
+		"required": {check: isNonZero, message: fixed("validation.required")},
+		"notblank": {check: func(f Field) bool { return NotBlank(f.Value.String()) }, message: fixed("validation.not_blank")},
+		"min":      {check: checkMin, message: sizeMessage("validation.min_value", "validation.min_chars", "validation.min_items")},
+		"max":      {check: checkMax, message: sizeMessage("validation.max_value", "validation.max_chars", "validation.max_items")},
+		"len":      {check: checkLen, message: sizeMessage("validation.len_value", "validation.len_chars", "validation.len_items")},
+		"oneof": {check: checkOneOf, message: func(f Field) (string, []any) {
+			return "validation.one_of", []any{strings.Join(strings.Fields(f.Param), ", ")}
+		}},
+		"unique": {check: checkUnique, message: fixed("validation.duplicates")},
+		"email":  {check: func(f Field) bool { return Matches(f.Value.String(), EmailRX) }, message: fixed("validation.email")},
+		"url":    {check: func(f Field) bool { return IsURL(f.Value.String()) }, message: fixed("validation.url")},
+		"uuid":   {check: func(f Field) bool { return IsUUID(f.Value.String()) }, message: fixed("validation.uuid")},
+		// cross-field rules, the parameter is the Go name of the other field
+		"eqfield":  {check: compareField(func(c int) bool { return c == 0 }), message: otherField("validation.eq_field")},
+		"nefield":  {check: compareField(func(c int) bool { return c != 0 }), message: otherField("validation.ne_field")},
+		"gtfield":  {check: compareField(func(c int) bool { return c > 0 }), message: otherField("validation.gt_field")},
+		"gtefield": {check: compareField(func(c int) bool { return c >= 0 }), message: otherField("validation.gte_field")},
+		"ltfield":  {check: compareField(func(c int) bool { return c < 0 }), message: otherField("validation.lt_field")},
+		"ltefield": {check: compareField(func(c int) bool { return c <= 0 }), message: otherField("validation.lte_field")},
+	}
+)
+
+// RegisterRule adds a custom rule that can be used in validate tags
+// the message is either an i18n key or literal text, and can contain a %s verb which is replaced with the rule's parameter
+// rules should be registered during program initialisation, before any struct using them is validated
+func RegisterRule(name string, check RuleFunc, message string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	if name == "omitempty" || name == "dive" {
+		panic(fmt.Sprintf("validator: %q is a reserved rule name", name))
+	}
+
+	rules[name] = rule{check: check, message: func(f Field) (string, []any) {
+		return message, []any{f.Param}
+	}}
+}
+
+// Struct checks every field of a struct against the rules in its validate tag
+// errors are keyed by the field's JSON name, and nested structs and slice elements get paths like "credits/0/name"
+//
+//	type Person struct {
+//		Name  string   `json:"name" validate:"required,notblank,max=200"`
+//		Roles []string `json:"roles" validate:"max=5,unique,dive,oneof=director actor writer"`
+//	}
+//
+// "omitempty" skips the rest of the rules when the value is empty, and "dive" applies
+// the rules after it to every element of a slice rather than the slice itself
+func (v *Validator) Struct(s any) {
+	value := reflect.ValueOf(s)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validator: Struct() called with a %s, not a struct", value.Kind()))
+	}
+
+	v.validateStruct(value, "")
+}
+
+// fieldMeta is the parsed form of a single struct field and its tag
+type fieldMeta struct {
+	index int
+	name  string
+	// rules applies to the field itself, elemRules to every element after a "dive"
+	rules     []boundRule
+	elemRules []boundRule
+	omitEmpty bool
+	elemOmit  bool
+}
+
+// a boundRule is a rule along with the parameter it was given in the tag
+type boundRule struct {
+	name  string
+	param string
+	rule  rule
+}
+
+// structCache holds the parsed fields for each struct type, so that tags are only parsed once per type
+var structCache sync.Map // map[reflect.Type][]fieldMeta
+
+// cachedFields returns the parsed fields of a struct type, parsing and caching them on first use
+func cachedFields(t reflect.Type) []fieldMeta {
+	if fields, ok := structCache.Load(t); ok {
+		return fields.([]fieldMeta)
+	}
+
+	fields := parseFields(t)
+	actual, _ := structCache.LoadOrStore(t, fields)
+
+	return actual.([]fieldMeta)
+}
+
+// parseFields reads the validate tag of every exported field
+// an unknown rule name is a programming error, so we panic like regexp.MustCompile() does
+func parseFields(t reflect.Type) []fieldMeta {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	var fields []fieldMeta
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		meta := fieldMeta{index: i, name: jsonName(sf)}
+		if meta.name == "-" {
+			continue
+		}
+
+		tag := ParseTag(sf.Tag.Get("validate"))
+		meta.omitEmpty, meta.elemOmit = tag.OmitEmpty, tag.ElemOmitEmpty
+
+		for _, tr := range tag.Rules {
+			meta.rules = append(meta.rules, bindRule(t, sf, tr))
+		}
+		for _, tr := range tag.ElemRules {
+			meta.elemRules = append(meta.elemRules, bindRule(t, sf, tr))
+		}
+
+		fields = append(fields, meta)
+	}
+
+	return fields
+}
+
+// bindRule looks up the rule named in a tag
+// the caller must hold rulesMu
+func bindRule(t reflect.Type, sf reflect.StructField, tr TagRule) boundRule {
+	r, ok := rules[tr.Name]
+	if !ok {
+		panic(fmt.Sprintf("validator: unknown rule %q on %s.%s", tr.Name, t.Name(), sf.Name))
+	}
+
+	return boundRule{name: tr.Name, param: tr.Param, rule: r}
+}
+
+// a TagRule is one rule from a validate tag, e.g. max=500 has the name "max" and the param "500"
+type TagRule struct {
+	Name  string
+	Param string
+}
+
+// a Tag is a parsed validate tag
+// Rules apply to the field itself and ElemRules to each element, i.e. the rules after "dive"
+type Tag struct {
+	Rules         []TagRule
+	ElemRules     []TagRule
+	OmitEmpty     bool
+	ElemOmitEmpty bool
+}
+
+// ParseTag splits a validate tag into its rules, without checking that the rules exist
+// it lets other packages, such as the API documentation, read the same constraints that Struct checks
+func ParseTag(tag string) Tag {
+	var parsed Tag
+
+	diving := false
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch name {
+		case "":
+			continue
+		case "omitempty":
+			if diving {
+				parsed.ElemOmitEmpty = true
+			} else {
+				parsed.OmitEmpty = true
+			}
+			continue
+		case "dive":
+			diving = true
+			continue
+		}
+
+		if diving {
+			parsed.ElemRules = append(parsed.ElemRules, TagRule{Name: name, Param: param})
+		} else {
+			parsed.Rules = append(parsed.Rules, TagRule{Name: name, Param: param})
+		}
+	}
+
+	return parsed
+}
+
+// jsonName returns the name a field has in JSON, which is the key its errors are reported under
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+
+	return name
+}
+
+// validateStruct checks the fields of a struct value, prefixing error keys with the path to the struct
+func (v *Validator) validateStruct(value reflect.Value, prefix string) {
+	for _, meta := range cachedFields(value.Type()) {
+		key := meta.name
+		if prefix != "" {
+			key = prefix + "/" + Path(meta.name)
+		}
+
+		field := value.Field(meta.index)
+		if !v.applyRules(field, value, key, meta.rules, meta.omitEmpty) {
+			continue
+		}
+
+		fieldValue := indirect(field)
+
+		// apply the rules after "dive" to each element of a slice
+		if len(meta.elemRules) > 0 && (fieldValue.Kind() == reflect.Slice || fieldValue.Kind() == reflect.Array) {
+			for i := range fieldValue.Len() {
+				v.applyRules(fieldValue.Index(i), value, key+"/"+strconv.Itoa(i), meta.elemRules, meta.elemOmit)
+			}
+		}
+
+		// recurse into nested structs, and slices of structs, so that their own tags are checked
+		switch {
+		case fieldValue.Kind() == reflect.Struct && fieldValue.Type() != reflect.TypeFor[time.Time]():
+			v.validateStruct(fieldValue, key)
+		case fieldValue.Kind() == reflect.Slice && indirectType(fieldValue.Type().Elem()).Kind() == reflect.Struct:
+			for i := range fieldValue.Len() {
+				if elem := indirect(fieldValue.Index(i)); elem.IsValid() && elem.Type() != reflect.TypeFor[time.Time]() {
+					v.validateStruct(elem, key+"/"+strconv.Itoa(i))
+				}
+			}
+		}
+	}
+}
+
+// applyRules runs the rules against a single value and adds an error for each that fails
+// it returns false if the value was skipped because of omitempty, or was a nil pointer
+func (v *Validator) applyRules(field, parent reflect.Value, key string, rules []boundRule, omitEmpty bool) bool {
+	if omitEmpty && !isNonZero(Field{Value: indirect(field)}) {
+		return false
+	}
+
+	value := indirect(field)
+
+	for _, br := range rules {
+		f := Field{Value: value, Param: br.param, Parent: parent}
+
+		// a nil pointer can only fail the required rule, every other rule needs a value to check
+		if !value.IsValid() {
+			if br.name == "required" {
+				message, args := br.rule.message(f)
+				v.AddError(key, message, args...)
+			}
+			continue
+		}
+
+		if !br.rule.check(f) {
+			message, args := br.rule.message(f)
+			v.AddError(key, message, args...)
+		}
+	}
+
+	return value.IsValid()
+}
+
+// indirect dereferences pointers, returning the zero Value for a nil pointer
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+
+	return value
+}
+
+// indirectType strips pointers from a type
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// fixed returns a message function for a message without a parameter
+func fixed(key string) func(Field) (string, []any) {
+	return func(Field) (string, []any) { return key, nil }
+}
+
+// sizeMessage picks the message for a size rule depending on whether it was applied to a number, string or collection
+func sizeMessage(number, text, collection string) func(Field) (string, []any) {
+	return func(f Field) (string, []any) {
+		switch f.Value.Kind() {
+		case reflect.String:
+			return text, []any{f.Param}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return collection, []any{f.Param}
+		default:
+			return number, []any{f.Param}
+		}
+	}
+}
+
+// otherField returns a message function naming the other field of a cross-field rule by its JSON name
+func otherField(key string) func(Field) (string, []any) {
+	return func(f Field) (string, []any) {
+		name := f.Param
+		if sf, ok := f.Parent.Type().FieldByName(f.Param); ok {
+			name = jsonName(sf)
+		}
+		return key, []any{name}
+	}
+}
+
+// isNonZero reports whether the value is set, i.e. not the zero value for its type
+func isNonZero(f Field) bool {
+	return f.Value.IsValid() && !f.Value.IsZero()
+}
+
+// size returns the number used by the min, max and len rules: the value of a number,
+// the number of characters in a string, or the number of items in a collection
+func size(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(len([]rune(value.String()))), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// compareSize applies a comparison between the size of the value and the rule's parameter
+func compareSize(f Field, ok func(size, limit float64) bool) bool {
+	limit, err := strconv.ParseFloat(f.Param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validator: invalid parameter %q", f.Param))
+	}
+
+	s, valid := size(f.Value)
+	return valid && ok(s, limit)
+}
+
+func checkMin(f Field) bool {
+	return compareSize(f, func(size, limit float64) bool { return size >= limit })
+}
+
+func checkMax(f Field) bool {
+	return compareSize(f, func(size, limit float64) bool { return size <= limit })
+}
+
+func checkLen(f Field) bool {
+	return compareSize(f, func(size, limit float64) bool { return size == limit })
+}
+
+// checkOneOf checks the value against the space separated list of permitted values in the parameter
+func checkOneOf(f Field) bool {
+	return PermittedValues(fmt.Sprint(f.Value.Interface()), strings.Fields(f.Param)...)
+}
+
+// checkUnique checks that every element of a slice is different, using their string form
+func checkUnique(f Field) bool {
+	if f.Value.Kind() != reflect.Slice && f.Value.Kind() != reflect.Array {
+		return true
+	}
+
+	values := make([]string, f.Value.Len())
+	for i := range values {
+		values[i] = fmt.Sprint(f.Value.Index(i).Interface())
+	}
+
+	return Unique(values)
+}
+
+// compareField returns a rule comparing the field to another field of the same struct
+func compareField(ok func(c int) bool) RuleFunc {
+	return func(f Field) bool {
+		other := indirect(f.Parent.FieldByName(f.Param))
+		if !other.IsValid() {
+			// nothing to compare against, e.g. an optional field that was not sent
+			return true
+		}
+
+		c, comparable := compareValues(f.Value, other)
+		return comparable && ok(c)
+	}
+}
+
+// compareValues compares two values of the same kind, returning -1, 0 or 1
+// the bool is false if the values cannot be compared
+func compareValues(a, b reflect.Value) (int, bool) {
+	if t, ok := a.Interface().(time.Time); ok {
+		if u, ok := b.Interface().(time.Time); ok {
+			return t.Compare(u), true
+		}
+		return 0, false
+	}
+
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), true
+	}
+
+	x, okA := size(a)
+	y, okB := size(b)
+	if !okA || !okB || a.Kind() != b.Kind() {
+		return 0, false
+	}
+
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
@@ -0,0 +1,342 @@
+package validator
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Tag
+	}{
+		{"", Tag{}},
+		{"required", Tag{Rules: []TagRule{{Name: "required"}}}},
+		{"required, max=500 ,notblank", Tag{Rules: []TagRule{{Name: "required"}, {Name: "max", Param: "500"}, {Name: "notblank"}}}},
+		{"omitempty,min=1", Tag{OmitEmpty: true, Rules: []TagRule{{Name: "min", Param: "1"}}}},
+		{"oneof=director actor writer", Tag{Rules: []TagRule{{Name: "oneof", Param: "director actor writer"}}}},
+		{
+			"max=5,unique,dive,omitempty,oneof=a b",
+			Tag{
+				Rules:         []TagRule{{Name: "max", Param: "5"}, {Name: "unique"}},
+				ElemRules:     []TagRule{{Name: "oneof", Param: "a b"}},
+				ElemOmitEmpty: true,
+			},
+		},
+		{"dive,required", Tag{ElemRules: []TagRule{{Name: "required"}}}},
+		{"required,,", Tag{Rules: []TagRule{{Name: "required"}}}},
+		// only the first "=" separates the name from the parameter
+		{"oneof=a=b", Tag{Rules: []TagRule{{Name: "oneof", Param: "a=b"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got := ParseTag(tt.tag)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTag(%q) = %+v, want %+v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+type testCredit struct {
+	Name string   `json:"name" validate:"required,notblank,max=10"`
+	Role string   `json:"role" validate:"oneof=director actor"`
+	Tags []string `json:"tags" validate:"omitempty,max=2,unique,dive,min=2"`
+}
+
+type testMovie struct {
+	Title    string            `json:"title" validate:"required,max=10"`
+	Year     int32             `json:"year" validate:"min=1888,max=2100"`
+	Rating   *float64          `json:"rating,omitempty" validate:"omitempty,min=1,max=10"`
+	Email    string            `json:"email" validate:"omitempty,email"`
+	Genres   []string          `json:"genres" validate:"required,min=1,unique,dive,notblank"`
+	Credits  []testCredit      `json:"credits"`
+	Director *testCredit       `json:"director"`
+	IDs      map[string]string `json:"a/b" validate:"max=1"`
+	Ignored  string            `json:"-" validate:"required"`
+	NoJSON   string            `validate:"len=3"`
+	internal string
+}
+
+func TestStruct(t *testing.T) {
+	valid := func() testMovie {
+		return testMovie{
+			Title:  "Casablanca",
+			Year:   1942,
+			Genres: []string{"drama"},
+			NoJSON: "abc",
+		}
+	}
+
+	rating := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name  string
+		edit  func(m *testMovie)
+		codes map[string][]string
+	}{
+		{name: "valid", edit: func(m *testMovie) {}},
+		{
+			name:  "missing required",
+			edit:  func(m *testMovie) { m.Title, m.Genres = "", nil },
+			codes: map[string][]string{"title": {"required"}, "genres": {"required", "min_items"}},
+		},
+		{
+			name:  "numbers out of range",
+			edit:  func(m *testMovie) { m.Year = 1800 },
+			codes: map[string][]string{"year": {"min_value"}},
+		},
+		{
+			name:  "characters are counted, not bytes",
+			edit:  func(m *testMovie) { m.Title = "Amélie ééé" },
+			codes: nil,
+		},
+		{
+			name:  "too long",
+			edit:  func(m *testMovie) { m.Title = "Casablanca!" },
+			codes: map[string][]string{"title": {"max_chars"}},
+		},
+		{
+			name:  "omitempty skips nil pointers and empty strings",
+			edit:  func(m *testMovie) { m.Rating, m.Email = nil, "" },
+			codes: nil,
+		},
+		{
+			name:  "omitempty checks values that are set",
+			edit:  func(m *testMovie) { m.Rating, m.Email = rating(11), "not an email" },
+			codes: map[string][]string{"rating": {"max_value"}, "email": {"email"}},
+		},
+		{
+			name:  "dive applies to each element",
+			edit:  func(m *testMovie) { m.Genres = []string{"drama", " ", "drama"} },
+			codes: map[string][]string{"genres": {"duplicates"}, "genres/1": {"not_blank"}},
+		},
+		{
+			name: "nested structs in slices",
+			edit: func(m *testMovie) {
+				m.Credits = []testCredit{
+					{Name: "Curtiz", Role: "director"},
+					{Name: "", Role: "producer", Tags: []string{"aa", "bb", "cc"}},
+				}
+			},
+			codes: map[string][]string{
+				"credits/1/name": {"required", "not_blank"},
+				"credits/1/role": {"one_of"},
+				"credits/1/tags": {"max_items"},
+			},
+		},
+		{
+			name:  "nested struct pointers",
+			edit:  func(m *testMovie) { m.Director = &testCredit{Name: "Curtiz", Role: "actor", Tags: []string{"x"}} },
+			codes: map[string][]string{"director/tags/0": {"min_chars"}},
+		},
+		{
+			name:  "maps and names that need escaping",
+			edit:  func(m *testMovie) { m.IDs = map[string]string{"imdb": "tt1", "tmdb": "1"} },
+			codes: map[string][]string{"a/b": {"max_items"}},
+		},
+		{
+			name:  "fields without a JSON name use the Go name",
+			edit:  func(m *testMovie) { m.NoJSON = "abcd" },
+			codes: map[string][]string{"NoJSON": {"len_chars"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := valid()
+			tt.edit(&m)
+
+			v := New()
+			v.Struct(&m)
+
+			want := tt.codes
+			if want == nil {
+				want = map[string][]string{}
+			}
+			if !maps.EqualFunc(v.Codes, want, slices.Equal) {
+				t.Errorf("codes = %v, want %v", v.Codes, want)
+			}
+		})
+	}
+}
+
+func TestStructNestedPathEscaping(t *testing.T) {
+	type inner struct {
+		Value string `json:"x~y" validate:"required"`
+	}
+	type outer struct {
+		Inner inner `json:"a/b"`
+	}
+
+	v := New()
+	v.Struct(outer{})
+
+	if _, ok := v.Errors["a/b/x~0y"]; !ok {
+		t.Errorf("errors = %v, want one at a/b/x~0y", v.Errors)
+	}
+}
+
+func TestStructCrossField(t *testing.T) {
+	type window struct {
+		Start    time.Time  `json:"start"`
+		End      time.Time  `json:"end" validate:"gtfield=Start"`
+		Min      int        `json:"min"`
+		Max      int        `json:"max" validate:"gtefield=Min"`
+		Password string     `json:"password"`
+		Confirm  string     `json:"confirm" validate:"eqfield=Password"`
+		Old      string     `json:"old"`
+		New      string     `json:"new" validate:"nefield=Old"`
+		Before   *time.Time `json:"before"`
+		After    time.Time  `json:"after" validate:"ltefield=Before"`
+		Low      int        `json:"low" validate:"ltfield=Max"`
+	}
+
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		w     window
+		codes map[string][]string
+	}{
+		{
+			name: "valid",
+			w:    window{Start: now, End: now.Add(time.Hour), Min: 1, Max: 1, Password: "x", Confirm: "x", Old: "a", New: "b", Low: 0},
+		},
+		{
+			name: "invalid",
+			w:    window{Start: now, End: now, Min: 2, Max: 1, Password: "x", Confirm: "y", Old: "a", New: "a", Low: 1},
+			codes: map[string][]string{
+				"end": {"gt_field"}, "max": {"gte_field"}, "confirm": {"eq_field"}, "new": {"ne_field"}, "low": {"lt_field"},
+			},
+		},
+		{
+			// a nil pointer on the other side has nothing to compare against
+			name: "missing other field",
+			w:    window{Start: now, End: now.Add(time.Hour), Max: 1, Old: "a", New: "b", After: now.Add(time.Hour)},
+		},
+		{
+			name:  "pointer on the other side",
+			w:     window{Start: now, End: now.Add(time.Hour), Max: 1, Old: "a", New: "b", Before: &now, After: now.Add(time.Hour)},
+			codes: map[string][]string{"after": {"lte_field"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			v.Struct(tt.w)
+
+			want := tt.codes
+			if want == nil {
+				want = map[string][]string{}
+			}
+			if !maps.EqualFunc(v.Codes, want, slices.Equal) {
+				t.Errorf("codes = %v, want %v", v.Codes, want)
+			}
+		})
+	}
+
+	// the message names the other field by its JSON name
+	v := New()
+	v.Struct(window{Start: now, End: now, Old: "a", New: "b"})
+	if got, want := v.Errors["end"], "must be greater than start"; got != want {
+		t.Errorf(`Errors["end"] = %q, want %q`, got, want)
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	RegisterRule("test_even", func(f Field) bool { return f.Value.Int()%2 == 0 }, "must be even")
+	RegisterRule("test_prefix", func(f Field) bool { return strings.HasPrefix(f.Value.String(), f.Param) }, "must start with %s")
+
+	type input struct {
+		Count int    `json:"count" validate:"test_even"`
+		ID    string `json:"id" validate:"test_prefix=tt"`
+	}
+
+	v := New()
+	v.Struct(input{Count: 2, ID: "tt0111161"})
+	if !v.Valid() {
+		t.Errorf("errors = %v, want none", v.Errors)
+	}
+
+	v = New()
+	v.Struct(input{Count: 3, ID: "nm0000007"})
+	want := map[string]string{"count": "must be even", "id": "must start with tt"}
+	if !maps.Equal(v.Errors, want) {
+		t.Errorf("errors = %v, want %v", v.Errors, want)
+	}
+
+	for _, name := range []string{"omitempty", "dive"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterRule(%q) did not panic", name)
+				}
+			}()
+			RegisterRule(name, func(Field) bool { return true }, "")
+		}()
+	}
+}
+
+func TestStructPanics(t *testing.T) {
+	type unknownRule struct {
+		Value string `validate:"no_such_rule"`
+	}
+
+	tests := map[string]any{
+		"unknown rule": unknownRule{},
+		"not a struct": "title",
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Struct() did not panic")
+				}
+			}()
+			New().Struct(value)
+		})
+	}
+
+	// a nil pointer has nothing to check
+	var m *testMovie
+	New().Struct(m)
+}
+
+func TestCachedFields(t *testing.T) {
+	type cached struct {
+		A string `json:"a" validate:"required"`
+		b string
+		C string `json:"-"`
+		D int    `json:"d,omitempty" validate:"omitempty,min=1"`
+	}
+
+	typ := reflect.TypeFor[cached]()
+	if _, ok := structCache.Load(typ); ok {
+		t.Fatal("type is cached before it is first validated")
+	}
+
+	New().Struct(cached{})
+
+	stored, ok := structCache.Load(typ)
+	if !ok {
+		t.Fatal("type is not cached after it is validated")
+	}
+
+	fields := stored.([]fieldMeta)
+	if len(fields) != 2 || fields[0].name != "a" || fields[1].name != "d" || !fields[1].omitEmpty {
+		t.Errorf("fields = %+v, want a and d, with omitempty on d", fields)
+	}
+
+	// later calls return the cached slice rather than parsing the tags again
+	if again := cachedFields(typ); &again[0] != &fields[0] {
+		t.Error("cachedFields() parsed the type again")
+	}
+}
@@ -1,23 +1,49 @@
 package validator
 
 import (
+	"cmp"
+	"fmt"
+	"net/url"
 	"regexp"
 	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TaskMasterErnest/greenlight/internal/i18n"
 )
 
 // declare regex for sanity checking the validity of email addresses
 var (
 	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+	// regex for the canonical 8-4-4-4-12 hexadecimal form of a UUID
+	UUIDRX = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
 )
 
 // define validator struct that contains a map of validation errors
+// Errors holds the first message for each field, which is what existing clients get back
+// FieldErrors holds every message for each field, in the order they were added
+// Codes holds a stable, untranslated code for each message in FieldErrors, for programmatic use
 type Validator struct {
-	Errors map[string]string
+	Errors      map[string]string
+	FieldErrors map[string][]string
+	Codes       map[string][]string
+	localizer   *i18n.Localizer
 }
 
-// New is a helper which creates a new Validator instance with an empty errors map
+// New is a helper which creates a new Validator instance with empty errors maps
+// messages are written in the default locale
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return NewWithLocalizer(nil)
+}
+
+// NewWithLocalizer creates a new Validator which translates its messages with the given Localizer
+func NewWithLocalizer(l *i18n.Localizer) *Validator {
+	return &Validator{
+		Errors:      make(map[string]string),
+		FieldErrors: make(map[string][]string),
+		Codes:       make(map[string][]string),
+		localizer:   l,
+	}
 }
 
 // the Valid method returns true if the errors map does not contain any entries
@@ -25,26 +51,65 @@ func (v *Validator) Valid() bool {
 	return len(v.Errors) == 0
 }
 
-// AddError method adds an error to the map (so long as no entry exists for the given key)
-func (v *Validator) AddError(key, message string) {
+// AddError method adds an error to the maps
+// the message is a key from the i18n catalogues (e.g. "validation.required") which is translated and formatted with args,
+// anything else is treated as literal text with the generic "invalid" code
+// the Errors map keeps only the first message for a key, FieldErrors collects all of them
+func (v *Validator) AddError(key, message string, args ...any) {
+	code := "invalid"
+	if i18n.IsKey(message) {
+		code = strings.TrimPrefix(message, "validation.")
+	}
+	text := v.localizer.T(message, args...)
+
 	// check if the value exists for the key
 	if _, exists := v.Errors[key]; !exists {
-		v.Errors[key] = message
+		v.Errors[key] = text
+	}
+
+	// the same message is only recorded once per key
+	if !slices.Contains(v.FieldErrors[key], text) {
+		v.FieldErrors[key] = append(v.FieldErrors[key], text)
+		v.Codes[key] = append(v.Codes[key], code)
 	}
 }
 
 // the Check method adds an error to the map, only if the validation check is not 'ok'
-func (v *Validator) Check(ok bool, key, message string) {
+func (v *Validator) Check(ok bool, key, message string, args ...any) {
 	if !ok {
-		v.AddError(key, message)
+		v.AddError(key, message, args...)
 	}
 }
 
+// Path joins the segments into a JSON-pointer-style key for nested fields, e.g. Path("genres", 2) is "genres/2"
+// "~" and "/" within a segment are escaped as "~0" and "~1", as in RFC 6901
+func Path(segments ...any) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		parts[i] = escaper.Replace(fmt.Sprint(segment))
+	}
+
+	return strings.Join(parts, "/")
+}
+
 // Generic function which returns true if a specific value is in a list of permitted values
 func PermittedValues[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
 }
 
+// All returns true if every value in the slice is one of the permitted values
+func All[T comparable](values []T, permittedValues ...T) bool {
+	for _, value := range values {
+		if !slices.Contains(permittedValues, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // the Matches method returns true if a string value matches a specific regexp pattern
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
@@ -60,3 +125,54 @@ func Unique[T comparable](values []T) bool {
 
 	return len(values) == len(uniqueValues)
 }
+
+// Duplicates returns the indexes of the values that repeat an earlier value in the slice
+// so that each offending element can be reported on its own path
+func Duplicates[T comparable](values []T) []int {
+	seen := make(map[T]bool)
+	var duplicates []int
+
+	for i, value := range values {
+		if seen[value] {
+			duplicates = append(duplicates, i)
+		}
+		seen[value] = true
+	}
+
+	return duplicates
+}
+
+// MinRunes returns true if the string contains at least n characters (runes, not bytes)
+func MinRunes(value string, n int) bool {
+	return utf8.RuneCountInString(value) >= n
+}
+
+// MaxRunes returns true if the string contains no more than n characters (runes, not bytes)
+func MaxRunes(value string, n int) bool {
+	return utf8.RuneCountInString(value) <= n
+}
+
+// InRange returns true if the value is between min and max, inclusive
+func InRange[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
+
+// NotBlank returns true if the string contains something other than whitespace
+func NotBlank(value string) bool {
+	return strings.TrimSpace(value) != ""
+}
+
+// IsURL returns true if the string is an absolute http or https URL with a host
+func IsURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// IsUUID returns true if the string is a UUID in its canonical textual form
+func IsUUID(value string) bool {
+	return Matches(value, UUIDRX)
+}
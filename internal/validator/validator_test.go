@@ -0,0 +1,115 @@
+package validator
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		segments []any
+		want     string
+	}{
+		{[]any{"title"}, "title"},
+		{[]any{"genres", 2}, "genres/2"},
+		{[]any{"credits", 0, "name"}, "credits/0/name"},
+		{[]any{"external_ids", "a/b"}, "external_ids/a~1b"},
+		{[]any{"external_ids", "a~b"}, "external_ids/a~0b"},
+		// "~" is escaped first, so "~1" in a segment does not turn into "/" when read back
+		{[]any{"external_ids", "~1"}, "external_ids/~01"},
+		{[]any{"external_ids", "/~"}, "external_ids/~1~0"},
+		{[]any{""}, ""},
+		{[]any{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := Path(tt.segments...)
+			if got != tt.want {
+				t.Errorf("Path(%v) = %q, want %q", tt.segments, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddError(t *testing.T) {
+	v := New()
+
+	v.AddError("title", "validation.required")
+	v.AddError("title", "validation.max_chars", "500")
+	// the same message is only recorded once
+	v.AddError("title", "validation.required")
+	v.AddError("year", "must be a sensible year")
+
+	if v.Valid() {
+		t.Fatal("Valid() = true, want false")
+	}
+
+	if got, want := v.Errors["title"], "must be provided"; got != want {
+		t.Errorf(`Errors["title"] = %q, want the first message %q`, got, want)
+	}
+
+	wantFieldErrors := []string{"must be provided", "must not be more than 500 characters long"}
+	if got := v.FieldErrors["title"]; !slices.Equal(got, wantFieldErrors) {
+		t.Errorf(`FieldErrors["title"] = %q, want %q`, got, wantFieldErrors)
+	}
+	if got, want := v.Codes["title"], []string{"required", "max_chars"}; !slices.Equal(got, want) {
+		t.Errorf(`Codes["title"] = %q, want %q`, got, want)
+	}
+
+	// literal text is kept as it is, with the generic code
+	if got := v.FieldErrors["year"]; !slices.Equal(got, []string{"must be a sensible year"}) {
+		t.Errorf(`FieldErrors["year"] = %q, want the literal message`, got)
+	}
+	if got := v.Codes["year"]; !slices.Equal(got, []string{"invalid"}) {
+		t.Errorf(`Codes["year"] = %q, want ["invalid"]`, got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	v := New()
+	v.Check(true, "title", "validation.required")
+	if !v.Valid() {
+		t.Fatalf("Valid() = false after a passing check, errors: %v", v.Errors)
+	}
+
+	v.Check(false, "title", "validation.required")
+	if v.Valid() {
+		t.Fatal("Valid() = true after a failing check")
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	if !Unique([]string{"a", "b"}) || Unique([]string{"a", "b", "a"}) {
+		t.Error("Unique() is wrong")
+	}
+	if got := Duplicates([]string{"a", "b", "a", "c", "b", "a"}); !slices.Equal(got, []int{2, 4, 5}) {
+		t.Errorf("Duplicates() = %v, want [2 4 5]", got)
+	}
+	if !All([]string{"a", "b"}, "a", "b", "c") || All([]string{"a", "d"}, "a", "b", "c") {
+		t.Error("All() is wrong")
+	}
+	if !MinRunes("héllo", 5) || MaxRunes("héllo", 4) {
+		t.Error("MinRunes() and MaxRunes() must count runes, not bytes")
+	}
+	if !InRange(5, 1, 10) || InRange(11, 1, 10) || !InRange(10, 1, 10) {
+		t.Error("InRange() is wrong")
+	}
+	if NotBlank(" \t\n") || !NotBlank(" x ") {
+		t.Error("NotBlank() is wrong")
+	}
+	for value, want := range map[string]bool{
+		"https://example.com/hook": true,
+		"http://localhost:8080":    true,
+		"ftp://example.com":        false,
+		"/relative":                false,
+		"https://":                 false,
+	} {
+		if got := IsURL(value); got != want {
+			t.Errorf("IsURL(%q) = %t, want %t", value, got, want)
+		}
+	}
+	if !IsUUID("123e4567-e89b-12d3-a456-426614174000") || IsUUID("123e4567e89b12d3a456426614174000") {
+		t.Error("IsUUID() is wrong")
+	}
+}
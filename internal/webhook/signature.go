@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the headers sent with every delivery
+const (
+	// SignatureHeader holds "t=<unix time>,v1=<hex HMAC-SHA256>", see Sign
+	SignatureHeader = "Greenlight-Signature"
+	EventHeader     = "Greenlight-Event"
+	DeliveryHeader  = "Greenlight-Delivery"
+)
+
+// errors returned by Verify
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpiredSignature = errors.New("webhook: signature timestamp is outside the tolerance")
+)
+
+// Sign returns the signature header for a body sent at time t
+// the HMAC-SHA256 is of the timestamp, a dot and the body, keyed with the webhook's secret, so a receiver that checks
+// the timestamp is recent cannot be sent an old delivery again
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac(secret, ts, body)))
+}
+
+// Verify checks a signature header made by Sign, for receivers
+// the timestamp must be within tolerance of now, a tolerance of 0 skips that check
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig, err := hex.DecodeString(value)
+			if err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 {
+		if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+			return ErrExpiredSignature
+		}
+	}
+
+	// a header can carry more than one v1 signature, any of which may match
+	expected := mac(secret, ts, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
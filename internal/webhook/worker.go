@@ -0,0 +1,206 @@
+// Package webhook delivers the events in the change feed to the webhooks subscribed to them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// a Store queues, hands out and records deliveries, it is implemented by data.WebhookModel
+type Store interface {
+	QueueDeliveries() (int64, error)
+	ClaimDue(limit int, lease time.Duration) ([]*data.WebhookJob, error)
+	RecordAttempt(job *data.WebhookJob, attempt *data.WebhookAttempt, retryAt time.Time, disableAfter time.Duration) (bool, error)
+}
+
+// a Policy says how failed deliveries are retried, and when a webhook that keeps failing is disabled
+type Policy struct {
+	// MaxAttempts is the most times a delivery is tried before it fails for good
+	MaxAttempts int
+	// the wait before each retry doubles from BackoffBase up to BackoffMax, with some jitter
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// DisableAfter is how long every attempt to a webhook must have been failing before it is disabled
+	DisableAfter time.Duration
+}
+
+// DefaultPolicy tries a delivery 12 times over about 15 hours, and disables a webhook after 3 days of failures
+var DefaultPolicy = Policy{
+	MaxAttempts:  12,
+	BackoffBase:  30 * time.Second,
+	BackoffMax:   6 * time.Hour,
+	DisableAfter: 72 * time.Hour,
+}
+
+// retryAt returns when to try again after the given attempt failed, or the zero time if it was the last one
+func (p Policy) retryAt(attempt int, now time.Time) time.Time {
+	if attempt >= p.MaxAttempts {
+		return time.Time{}
+	}
+
+	wait := p.BackoffMax
+	if shift := attempt - 1; shift < 32 && p.BackoffBase<<shift < p.BackoffMax {
+		wait = p.BackoffBase << shift
+	}
+
+	// half the wait is fixed and half is random, so that deliveries that failed together are spread out
+	wait = wait/2 + rand.N(wait/2+1)
+
+	return now.Add(wait)
+}
+
+// a Worker sends the deliveries that are due, it can run on any number of servers at once
+type Worker struct {
+	Store  Store
+	Client *http.Client
+	Logger *slog.Logger
+	Policy Policy
+	// Concurrency is the number of deliveries sent at the same time
+	Concurrency int
+	// PollInterval is how long to wait before looking for more work when there is none
+	PollInterval time.Duration
+	// UserAgent is sent with every delivery
+	UserAgent string
+}
+
+// NewHTTPClient returns a client for sending deliveries
+// redirects are not followed, a receiver has to answer at the URL it subscribed with
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run queues and sends deliveries until the context is cancelled
+// the deliveries in flight when it is cancelled are finished and recorded before Run returns
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		n, err := w.RunOnce(ctx)
+		if err != nil {
+			w.Logger.Error(err.Error())
+		}
+
+		// carry straight on while there is a backlog
+		if n == w.Concurrency && err == nil && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// RunOnce queues the deliveries for new events, and then sends a batch of those that are due and records the results
+// it returns the number of deliveries sent
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	_, err := w.Store.QueueDeliveries()
+	if err != nil {
+		return 0, fmt.Errorf("queueing webhook deliveries: %w", err)
+	}
+
+	// the lease has to outlast the request, or another worker could send the delivery again while it is in flight
+	jobs, err := w.Store.ClaimDue(w.Concurrency, 2*w.Client.Timeout+time.Minute)
+	if err != nil {
+		return 0, fmt.Errorf("claiming webhook deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a delivery is not cut off when the worker is stopped, the client's timeout still bounds it
+			w.process(context.WithoutCancel(ctx), job)
+		}()
+	}
+	wg.Wait()
+
+	return len(jobs), nil
+}
+
+// process sends a delivery and records how it went
+func (w *Worker) process(ctx context.Context, job *data.WebhookJob) {
+	attempt := w.Deliver(ctx, job)
+
+	var retryAt time.Time
+	if !attempt.Succeeded() {
+		retryAt = w.Policy.retryAt(job.Attempt, time.Now())
+	}
+
+	disabled, err := w.Store.RecordAttempt(job, attempt, retryAt, w.Policy.DisableAfter)
+	if err != nil {
+		// a webhook deleted while its delivery was in flight has nothing left to record against
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			w.Logger.Error(err.Error(), "webhook_id", job.WebhookID, "delivery_id", job.DeliveryID)
+		}
+		return
+	}
+
+	if !attempt.Succeeded() {
+		w.Logger.Warn("webhook delivery failed", "webhook_id", job.WebhookID, "delivery_id", job.DeliveryID,
+			"attempt", job.Attempt, "status", attempt.StatusCode, "error", attempt.Error, "gave_up", retryAt.IsZero())
+	}
+	if disabled {
+		w.Logger.Warn("disabled failing webhook", "webhook_id", job.WebhookID, "url", job.URL)
+	}
+}
+
+// Deliver POSTs the job's event to its webhook, signed with the webhook's secret
+// the body is the event as it appears in the change feed at /v1/events
+func (w *Worker) Deliver(ctx context.Context, job *data.WebhookJob) *data.WebhookAttempt {
+	start := time.Now()
+	attempt := &data.WebhookAttempt{AttemptedAt: start}
+
+	defer func() {
+		attempt.DurationMS = time.Since(start).Milliseconds()
+	}()
+
+	body, err := json.Marshal(job.Event)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", w.UserAgent)
+	req.Header.Set(EventHeader, job.Event.Type)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(job.DeliveryID, 10))
+	req.Header.Set(SignatureHeader, Sign(job.Secret, start, body))
+
+	res, err := w.Client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer res.Body.Close()
+
+	// the body is not kept, but reading some of it lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	attempt.StatusCode = res.StatusCode
+	return attempt
+}
@@ -0,0 +1,293 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TaskMasterErnest/greenlight/internal/data"
+)
+
+// fakeStore hands out a fixed set of jobs and keeps the attempts recorded against them
+type fakeStore struct {
+	mu       sync.Mutex
+	jobs     []*data.WebhookJob
+	attempts []*data.WebhookAttempt
+	retryAts []time.Time
+}
+
+func (s *fakeStore) QueueDeliveries() (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) ClaimDue(limit int, lease time.Duration) ([]*data.WebhookJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := s.jobs[:min(limit, len(s.jobs))]
+	s.jobs = s.jobs[len(jobs):]
+	return jobs, nil
+}
+
+func (s *fakeStore) RecordAttempt(job *data.WebhookJob, attempt *data.WebhookAttempt, retryAt time.Time, disableAfter time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts = append(s.attempts, attempt)
+	s.retryAts = append(s.retryAts, retryAt)
+	return false, nil
+}
+
+func newTestWorker(store Store, timeout time.Duration) *Worker {
+	return &Worker{
+		Store:        store,
+		Client:       NewHTTPClient(timeout),
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Policy:       DefaultPolicy,
+		Concurrency:  4,
+		PollInterval: time.Second,
+		UserAgent:    "greenlight-webhooks/test",
+	}
+}
+
+func newTestJob(url string) *data.WebhookJob {
+	return &data.WebhookJob{
+		DeliveryID: 7,
+		WebhookID:  3,
+		URL:        url,
+		Secret:     "s3cret",
+		Attempt:    1,
+		Event: data.Event{
+			ID:         42,
+			OccurredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Type:       data.EventMovieCreated,
+			ResourceID: 9,
+			Data:       json.RawMessage(`{"id":9,"title":"Casablanca"}`),
+		},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign("s3cret", now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		now       time.Time
+		want      error
+	}{
+		{"valid", "s3cret", header, body, 5 * time.Minute, now, nil},
+		{"valid within tolerance", "s3cret", header, body, 5 * time.Minute, now.Add(4 * time.Minute), nil},
+		{"valid with timestamp ahead", "s3cret", header, body, 5 * time.Minute, now.Add(-4 * time.Minute), nil},
+		{"no tolerance check", "s3cret", header, body, 0, now.Add(24 * time.Hour), nil},
+		{"expired", "s3cret", header, body, 5 * time.Minute, now.Add(6 * time.Minute), ErrExpiredSignature},
+		{"too far ahead", "s3cret", header, body, 5 * time.Minute, now.Add(-6 * time.Minute), ErrExpiredSignature},
+		{"bad secret", "other", header, body, 5 * time.Minute, now, ErrInvalidSignature},
+		{"changed body", "s3cret", header, []byte(`{"id":2}`), 5 * time.Minute, now, ErrInvalidSignature},
+		{"another v1 matches", "s3cret", header + ",v1=" + strings.Repeat("ab", 32), body, 0, now, nil},
+		{"matching v1 second", "s3cret", "t=1700000000,v1=" + strings.Repeat("ab", 32) + "," + strings.Split(header, ",")[1], body, 0, now, nil},
+		{"no matching v1", "s3cret", "t=1700000000,v1=" + strings.Repeat("ab", 32) + ",v1=zz", body, 0, now, ErrInvalidSignature},
+		{"no timestamp", "s3cret", strings.Split(header, ",")[1], body, 0, now, ErrInvalidSignature},
+		{"no signature", "s3cret", "t=1700000000", body, 0, now, ErrInvalidSignature},
+		{"empty", "s3cret", "", body, 0, now, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.tolerance, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var got struct {
+		header http.Header
+		body   []byte
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.header = r.Header.Clone()
+		got.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	job := newTestJob(server.URL)
+	attempt := newTestWorker(&fakeStore{}, time.Second).Deliver(context.Background(), job)
+
+	if !attempt.Succeeded() || attempt.StatusCode != http.StatusNoContent {
+		t.Fatalf("attempt = %+v, want a 204", attempt)
+	}
+
+	err := Verify(job.Secret, got.header.Get(SignatureHeader), got.body, time.Minute, time.Now())
+	if err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	var event data.Event
+	err = json.Unmarshal(got.body, &event)
+	if err != nil || event.ID != job.Event.ID {
+		t.Errorf("body = %s, want event %d", got.body, job.Event.ID)
+	}
+
+	checks := map[string]string{
+		"Content-Type": "application/json",
+		"User-Agent":   "greenlight-webhooks/test",
+		EventHeader:    job.Event.Type,
+		DeliveryHeader: strconv.FormatInt(job.DeliveryID, 10),
+	}
+	for name, want := range checks {
+		if got := got.header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDeliverFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		status    int
+		wantError bool
+	}{
+		{
+			name:    "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			status:  http.StatusInternalServerError,
+		},
+		{
+			name:    "client error",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusGone) },
+			status:  http.StatusGone,
+		},
+		{
+			name: "redirect is not followed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/moved" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				http.Redirect(w, r, "/moved", http.StatusTemporaryRedirect)
+			},
+			status: http.StatusTemporaryRedirect,
+		},
+		{
+			name: "timeout",
+			// answers after the client's timeout of 100ms
+			handler:   func(w http.ResponseWriter, r *http.Request) { time.Sleep(300 * time.Millisecond) },
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			attempt := newTestWorker(&fakeStore{}, 100*time.Millisecond).Deliver(context.Background(), newTestJob(server.URL))
+
+			if attempt.Succeeded() {
+				t.Fatalf("attempt = %+v, want a failure", attempt)
+			}
+			if attempt.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", attempt.StatusCode, tt.status)
+			}
+			if (attempt.Error != "") != tt.wantError {
+				t.Errorf("error = %q, want an error: %t", attempt.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestRunOnceRecordsAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(DeliveryHeader) == "1" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ok, failing := newTestJob(server.URL), newTestJob(server.URL)
+	ok.DeliveryID, failing.DeliveryID = 1, 2
+	store := &fakeStore{jobs: []*data.WebhookJob{ok, failing}}
+
+	n, err := newTestWorker(store, time.Second).RunOnce(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("RunOnce() = %d, %v, want 2, nil", n, err)
+	}
+
+	var succeeded, retried int
+	for i, attempt := range store.attempts {
+		if attempt.Succeeded() {
+			succeeded++
+			if !store.retryAts[i].IsZero() {
+				t.Errorf("retry at %v after a success", store.retryAts[i])
+			}
+		} else if !store.retryAts[i].IsZero() {
+			retried++
+		}
+	}
+	if succeeded != 1 || retried != 1 {
+		t.Errorf("succeeded %d and retried %d, want 1 and 1", succeeded, retried)
+	}
+}
+
+func TestRetryAt(t *testing.T) {
+	p := Policy{MaxAttempts: 12, BackoffBase: 30 * time.Second, BackoffMax: 6 * time.Hour}
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		attempt int
+		wait    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		{11, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			// half the wait is jitter, so any time from half the wait up to the whole of it will do
+			for range 50 {
+				wait := p.retryAt(tt.attempt, now).Sub(now)
+				if wait < tt.wait/2 || wait > tt.wait {
+					t.Fatalf("wait = %v, want between %v and %v", wait, tt.wait/2, tt.wait)
+				}
+			}
+		})
+	}
+
+	// the shift is capped, so a policy with a great many attempts does not overflow
+	huge := Policy{MaxAttempts: 1000, BackoffBase: 30 * time.Second, BackoffMax: 6 * time.Hour}
+	if wait := huge.retryAt(999, now).Sub(now); wait < 3*time.Hour || wait > 6*time.Hour {
+		t.Errorf("wait after attempt 999 = %v, want between 3h and 6h", wait)
+	}
+
+	for _, attempt := range []int{12, 13} {
+		if got := p.retryAt(attempt, now); !got.IsZero() {
+			t.Errorf("retryAt(%d) = %v, want the zero time", attempt, got)
+		}
+	}
+}
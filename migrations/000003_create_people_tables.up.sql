@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS people (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  name text NOT NULL,
+  birth_year integer,
+  biography text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS movie_credits (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  person_id bigint NOT NULL REFERENCES people ON DELETE CASCADE,
+  role text NOT NULL,
+  character_name text NOT NULL DEFAULT '',
+  billing_order integer NOT NULL DEFAULT 0,
+  CONSTRAINT movie_credits_role_check CHECK (role IN ('director', 'actor', 'writer')),
+  CONSTRAINT movie_credits_billing_order_check CHECK (billing_order >= 0),
+  CONSTRAINT movie_credits_unique UNIQUE (movie_id, person_id, role, character_name)
+);
+
+CREATE INDEX IF NOT EXISTS movie_credits_person_id_idx ON movie_credits (person_id);
\ No newline at end of file
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS genres (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  slug text NOT NULL,
+  name text NOT NULL,
+  CONSTRAINT genres_slug_unique UNIQUE (slug),
+  CONSTRAINT genres_slug_check CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$')
+);
+
+-- backfill the vocabulary from the genres already used by movies
+-- spellings that only differ by case or punctuation ("Sci-Fi", "sci-fi") share a slug, and the first one alphabetically becomes the display name
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (slug) slug, name
+FROM (
+  SELECT trim(both '-' from regexp_replace(lower(trim(genre)), '[^a-z0-9]+', '-', 'g')) AS slug, trim(genre) AS name
+  FROM movies, unnest(movies.genres) AS genre
+) AS existing
+WHERE slug <> ''
+ORDER BY slug, name
+ON CONFLICT (slug) DO NOTHING;
+
+-- rewrite each movie's genres to the display names, dropping any duplicates this creates but keeping the original order
+UPDATE movies
+SET genres = COALESCE((
+  SELECT array_agg(canonical.name ORDER BY canonical.position)
+  FROM (
+    SELECT genres.name, min(existing.position) AS position
+    FROM unnest(movies.genres) WITH ORDINALITY AS existing(genre, position)
+    INNER JOIN genres ON genres.slug = trim(both '-' from regexp_replace(lower(trim(existing.genre)), '[^a-z0-9]+', '-', 'g'))
+    GROUP BY genres.name
+  ) AS canonical
+), movies.genres);
\ No newline at end of file
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS reviews_refresh_movie_rating ON reviews;
+
+DROP FUNCTION IF EXISTS movies_refresh_rating();
+
+DROP TABLE IF EXISTS reviews;
+
+DROP INDEX IF EXISTS movies_average_rating_idx;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS average_rating;
\ No newline at end of file
@@ -0,0 +1,48 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS average_rating numeric(4, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reviews (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  reviewer text NOT NULL,
+  score integer NOT NULL,
+  body text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT reviews_score_check CHECK (score BETWEEN 1 AND 10),
+  CONSTRAINT reviews_movie_reviewer_unique UNIQUE (movie_id, reviewer)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_movie_id_created_at_idx ON reviews (movie_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS movies_average_rating_idx ON movies (average_rating);
+
+-- keep the aggregate rating on the movie in step with its reviews
+CREATE OR REPLACE FUNCTION movies_refresh_rating() RETURNS trigger AS $$
+DECLARE
+  affected_movie_id bigint;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    affected_movie_id := OLD.movie_id;
+  ELSE
+    affected_movie_id := NEW.movie_id;
+  END IF;
+
+  UPDATE movies
+  SET average_rating = COALESCE(stats.average, 0), rating_count = stats.total
+  FROM (
+    SELECT round(avg(score), 2) AS average, count(*) AS total
+    FROM reviews
+    WHERE movie_id = affected_movie_id
+  ) AS stats
+  WHERE movies.id = affected_movie_id;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reviews_refresh_movie_rating
+AFTER INSERT OR UPDATE OF score OR DELETE ON reviews
+FOR EACH ROW EXECUTE FUNCTION movies_refresh_rating();
\ No newline at end of file
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS watchlist_items_close_gap ON watchlist_items;
+
+DROP FUNCTION IF EXISTS watchlist_items_close_gap();
+
+DROP TABLE IF EXISTS watchlist_items;
+
+DROP TABLE IF EXISTS watchlists;
\ No newline at end of file
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS watchlists (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  owner text NOT NULL,
+  name text NOT NULL,
+  version integer NOT NULL DEFAULT 1,
+  CONSTRAINT watchlists_owner_name_unique UNIQUE (owner, name)
+);
+
+-- positions run from 1 with no gaps, the unique constraint is deferrable so that
+-- items can be shifted along in a single statement
+CREATE TABLE IF NOT EXISTS watchlist_items (
+  id bigserial PRIMARY KEY,
+  added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  watchlist_id bigint NOT NULL REFERENCES watchlists ON DELETE CASCADE,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  position integer NOT NULL,
+  watched boolean NOT NULL DEFAULT false,
+  watched_at timestamp(0) with time zone,
+  CONSTRAINT watchlist_items_position_check CHECK (position >= 1),
+  CONSTRAINT watchlist_items_watched_at_check CHECK (watched OR watched_at IS NULL),
+  CONSTRAINT watchlist_items_movie_unique UNIQUE (watchlist_id, movie_id),
+  CONSTRAINT watchlist_items_position_unique UNIQUE (watchlist_id, position) DEFERRABLE INITIALLY IMMEDIATE
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_items_movie_id_idx ON watchlist_items (movie_id);
+
+-- close the gap left by a removed item, whether it was removed directly or because its movie was deleted
+CREATE OR REPLACE FUNCTION watchlist_items_close_gap() RETURNS trigger AS $$
+BEGIN
+  UPDATE watchlist_items
+  SET position = position - 1
+  WHERE watchlist_id = OLD.watchlist_id AND position > OLD.position;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER watchlist_items_close_gap
+AFTER DELETE ON watchlist_items
+FOR EACH ROW EXECUTE FUNCTION watchlist_items_close_gap();
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS movie_posters (
+  movie_id bigint PRIMARY KEY REFERENCES movies ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  content_type text NOT NULL,
+  width integer NOT NULL,
+  height integer NOT NULL,
+  size bigint NOT NULL,
+  checksum text NOT NULL,
+  CONSTRAINT movie_posters_dimensions_check CHECK (width > 0 AND height > 0),
+  CONSTRAINT movie_posters_size_check CHECK (size > 0)
+);
\ No newline at end of file
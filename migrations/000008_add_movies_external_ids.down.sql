@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS movies_normalised_title_year_idx;
+
+DROP INDEX IF EXISTS movies_external_ids_tmdb_idx;
+
+DROP INDEX IF EXISTS movies_external_ids_imdb_idx;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_external_ids_object_check;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS external_ids;
\ No newline at end of file
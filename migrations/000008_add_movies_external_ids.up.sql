@@ -0,0 +1,12 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_ids jsonb NOT NULL DEFAULT '{}';
+
+ALTER TABLE movies ADD CONSTRAINT movies_external_ids_object_check CHECK (jsonb_typeof(external_ids) = 'object');
+
+-- one unique index per source, so the same IMDb or TMDb record cannot be imported twice
+CREATE UNIQUE INDEX IF NOT EXISTS movies_external_ids_imdb_idx ON movies ((external_ids->>'imdb')) WHERE external_ids ? 'imdb';
+
+CREATE UNIQUE INDEX IF NOT EXISTS movies_external_ids_tmdb_idx ON movies ((external_ids->>'tmdb')) WHERE external_ids ? 'tmdb';
+
+-- titles are compared ignoring case, spacing and punctuation when looking for duplicates
+-- this is not unique, as existing data may already have duplicates and remakes can share a title and year
+CREATE INDEX IF NOT EXISTS movies_normalised_title_year_idx ON movies ((regexp_replace(lower(title), '[^[:alnum:]]+', '', 'g')), year);
\ No newline at end of file
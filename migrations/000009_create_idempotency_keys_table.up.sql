@@ -0,0 +1,17 @@
+-- a row with a NULL status is a request that is still being handled, the lock is considered
+-- abandoned once locked_at is old enough and can then be taken over by a retry
+-- each claim on a key gets its own token, so that a request whose lock was taken over by a retry can no longer
+-- complete or release the key out from under the retry
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  key text PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  expires_at timestamp(0) with time zone NOT NULL,
+  locked_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  fingerprint text NOT NULL,
+  claim text NOT NULL,
+  status integer,
+  headers jsonb,
+  body bytea
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
\ No newline at end of file
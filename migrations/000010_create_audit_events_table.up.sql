@@ -0,0 +1,17 @@
+-- one row per change to a record, written in the same transaction as the change
+-- changes maps each field that changed to its old and new values, creates only have new values and deletes only old ones
+CREATE TABLE IF NOT EXISTS audit_events (
+  id bigserial PRIMARY KEY,
+  occurred_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  action text NOT NULL,
+  resource_type text NOT NULL,
+  resource_id bigint NOT NULL,
+  actor text NOT NULL,
+  client_ip text NOT NULL DEFAULT '',
+  request_id text NOT NULL DEFAULT '',
+  changes jsonb NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_resource_idx ON audit_events (resource_type, resource_id, occurred_at);
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor, occurred_at);
+CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON audit_events (occurred_at);
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- the change feed, one row per change to a movie written in the same transaction as the change
+-- the id is the cursor consumers page through, rows are never updated so the feed can be replayed from any point
+CREATE TABLE IF NOT EXISTS outbox (
+  id bigserial PRIMARY KEY,
+  occurred_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  event_type text NOT NULL,
+  resource_id bigint NOT NULL,
+  payload jsonb NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,43 @@
+-- webhook subscriptions, an empty event_types list subscribes to every event type
+-- last_event_id is how far through the outbox deliveries have been queued for the webhook, new webhooks start at the end
+-- failing_since is when the webhook's current run of failed attempts began, it is cleared by a successful delivery
+CREATE TABLE IF NOT EXISTS webhooks (
+  id bigserial PRIMARY KEY,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  url text NOT NULL,
+  event_types text[] NOT NULL DEFAULT '{}',
+  secret text NOT NULL,
+  enabled boolean NOT NULL DEFAULT true,
+  last_event_id bigint NOT NULL DEFAULT 0,
+  failing_since timestamp(0) with time zone,
+  disabled_at timestamp(0) with time zone,
+  disabled_reason text NOT NULL DEFAULT '',
+  version integer NOT NULL DEFAULT 1
+);
+
+-- one delivery per webhook and event, retried until it succeeds or runs out of attempts
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id bigserial PRIMARY KEY,
+  webhook_id bigint NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+  event_id bigint NOT NULL REFERENCES outbox,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  status text NOT NULL DEFAULT 'pending',
+  attempt_count integer NOT NULL DEFAULT 0,
+  next_attempt_at timestamp with time zone,
+  completed_at timestamp(0) with time zone,
+  UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- the log of every attempt at a delivery
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+  id bigserial PRIMARY KEY,
+  delivery_id bigint NOT NULL REFERENCES webhook_deliveries ON DELETE CASCADE,
+  attempted_at timestamp with time zone NOT NULL,
+  status_code integer NOT NULL DEFAULT 0,
+  error text NOT NULL DEFAULT '',
+  duration_ms integer NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_idx ON webhook_attempts (delivery_id);
\ No newline at end of file
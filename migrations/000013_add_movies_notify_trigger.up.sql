@@ -0,0 +1,21 @@
+-- tell the API's listeners that a movie has changed, the notification is sent when the change is committed
+-- the payload is only a hint, the listeners read what changed from the outbox
+-- rating changes from new reviews are left out, as they are not in the outbox either
+CREATE OR REPLACE FUNCTION notify_movie_change() RETURNS trigger AS $$
+DECLARE
+  movie_id bigint;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    movie_id := OLD.id;
+  ELSE
+    movie_id := NEW.id;
+  END IF;
+
+  PERFORM pg_notify('movie_changes', lower(TG_OP) || ':' || movie_id);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER movies_notify_change
+AFTER INSERT OR UPDATE OF title, year, runtime, genres, external_ids OR DELETE ON movies
+FOR EACH ROW EXECUTE FUNCTION notify_movie_change();
\ No newline at end of file
@@ -0,0 +1,260 @@
+// Package client is a Go client for the Greenlight API
+//
+//	c, err := client.New("https://greenlight.example.com")
+//	if err != nil {
+//		return err
+//	}
+//
+//	movie, err := c.GetMovie(ctx, 1)
+//	switch {
+//	case errors.Is(err, client.ErrNotFound):
+//		// no such movie
+//	case err != nil:
+//		return err
+//	}
+//
+// requests are retried with exponential backoff when the server is unavailable or the connection fails,
+// and POST requests are sent with an Idempotency-Key so that retrying them cannot create duplicates
+package client
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a Client makes requests to the API, it is safe for concurrent use
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+	language   string
+}
+
+// a RetryPolicy controls how failed requests are retried
+// the wait before each retry doubles from MinBackoff up to MaxBackoff, with some jitter,
+// unless the server asks for a particular wait with a Retry-After header
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent, including the first, 1 turns retries off
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryPolicy is used unless another is set with WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+// an Option changes the way a Client is set up
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used to make requests, e.g. for custom timeouts, transports or tracing
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithLanguage sets the Accept-Language header, which is the language the error messages are written in
+func WithLanguage(language string) Option {
+	return func(c *Client) {
+		c.language = language
+	}
+}
+
+// New returns a Client for the API at the base URL, e.g. "http://localhost:4567"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+		userAgent:  "greenlight-go-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+
+	return c, nil
+}
+
+// do sends a request and decodes the envelope of a successful response into dest, which may be nil
+// the body is encoded as JSON, and POST requests get an Idempotency-Key which stays the same across retries
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, dest any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("client: encoding request: %w", err)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var idempotencyKey string
+	if method == http.MethodPost {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("client: %w", err)
+		}
+
+		// problem details are asked for on top of JSON, as they carry the error code and every validation message
+		req.Header.Set("Accept", "application/json, application/problem+json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		if c.language != "" {
+			req.Header.Set("Accept-Language", c.language)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil {
+			err = decodeResponse(resp, dest)
+		}
+
+		if err == nil || attempt >= c.retry.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.backoff(attempt, err)):
+		}
+	}
+}
+
+// decodeResponse reads the envelope of a successful response into dest, or the error from any other response
+func decodeResponse(resp *http.Response, dest any) error {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("client: reading response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return newError(resp, body)
+	}
+
+	if dest == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(body, dest)
+	if err != nil {
+		return fmt.Errorf("client: decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// retryable reports whether a request that failed with err is worth sending again
+// connection problems and timeouts are, as are responses saying the server is busy or unavailable,
+// but context cancellation and errors in the request itself are not
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		case http.StatusConflict:
+			// the first attempt with this Idempotency-Key is still being handled
+			return apiErr.Code == "idempotency_key_in_progress"
+		default:
+			return false
+		}
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// backoff returns how long to wait before the next attempt, using the server's Retry-After if it sent one
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, c.retry.MaxBackoff)
+	}
+
+	wait := c.retry.MinBackoff << (attempt - 1)
+	if wait <= 0 || wait > c.retry.MaxBackoff {
+		wait = c.retry.MaxBackoff
+	}
+
+	// full jitter keeps clients that failed together from retrying together
+	return time.Duration(rand.Int64N(int64(wait) + 1))
+}
+
+// newIdempotencyKey returns a random key for a POST request
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,464 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fastRetries keeps the tests quick while still going through the backoff
+var fastRetries = RetryPolicy{MaxAttempts: 4, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// newTestClient starts a server with the handler and returns a client for it
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL+"/", append([]Option{WithRetryPolicy(fastRetries)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"localhost:4567", "ftp://example.com", "://bad"} {
+		if _, err := New(baseURL); err == nil {
+			t.Errorf("New(%q) error = nil, want an error", baseURL)
+		}
+	}
+
+	c, err := New("https://greenlight.example.com/api/", WithRetryPolicy(RetryPolicy{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.baseURL.String() != "https://greenlight.example.com/api" {
+		t.Errorf("base URL = %s, want the trailing slash removed", c.baseURL)
+	}
+	if c.retry.MaxAttempts != 1 {
+		t.Errorf("MaxAttempts = %d, want at least 1", c.retry.MaxAttempts)
+	}
+}
+
+func TestGetMovie(t *testing.T) {
+	var got *http.Request
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"movie": {"id": 1, "title": "Casablanca", "year": 1942, "runtime": "102 mins", "genres": ["drama"], "version": 3,
+			"credits": [{"id": 7, "person_id": 2, "name": "Michael Curtiz", "role": "director", "billing_order": 0}]}}`)
+	}, WithUserAgent("test-agent"), WithLanguage("fr"))
+
+	movie, err := c.GetMovie(context.Background(), 1, GetMovieOptions{IncludeCredits: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Method != http.MethodGet || got.URL.Path != "/v1/movies/1" || got.URL.Query().Get("include") != "credits" {
+		t.Errorf("request = %s %s, want GET /v1/movies/1?include=credits", got.Method, got.URL)
+	}
+	for header, want := range map[string]string{
+		"Accept":          "application/json, application/problem+json",
+		"User-Agent":      "test-agent",
+		"Accept-Language": "fr",
+		"Idempotency-Key": "",
+		"Content-Type":    "",
+	} {
+		if value := got.Header.Get(header); value != want {
+			t.Errorf("%s = %q, want %q", header, value, want)
+		}
+	}
+
+	want := &Movie{
+		ID: 1, Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama"}, Version: 3,
+		Credits: []*Credit{{ID: 7, PersonID: 2, Name: "Michael Curtiz", Role: "director"}},
+	}
+	if !reflect.DeepEqual(movie, want) {
+		t.Errorf("movie = %+v, want %+v", movie, want)
+	}
+}
+
+func TestListMovies(t *testing.T) {
+	var query url.Values
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		io.WriteString(w, `{"movies": [{"id": 2, "genres": [], "version": 1}], "metadata": {"current_page": 2, "page_size": 1, "first_page": 1, "last_page": 5, "total_records": 5}}`)
+	})
+
+	page, err := c.ListMovies(context.Background(), ListMoviesParams{Title: "moon", Genres: []string{"drama", "sci-fi"}, Sort: "-average_rating", Page: 2, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantQuery := url.Values{"title": {"moon"}, "genres": {"drama,sci-fi"}, "sort": {"-average_rating"}, "page": {"2"}, "page_size": {"1"}}
+	if !reflect.DeepEqual(query, wantQuery) {
+		t.Errorf("query = %v, want %v", query, wantQuery)
+	}
+	if len(page.Movies) != 1 || page.Movies[0].ID != 2 || page.Metadata.TotalRecords != 5 {
+		t.Errorf("page = %+v", page)
+	}
+
+	// zero values are left out
+	if _, err := c.ListMovies(context.Background(), ListMoviesParams{}); err != nil || len(query) != 0 {
+		t.Errorf("query = %v, %v, want none", query, err)
+	}
+}
+
+func TestRuntimeJSON(t *testing.T) {
+	for body, want := range map[string]Runtime{`102`: 102, `"102 mins"`: 102, `"1 min"`: 1} {
+		var r Runtime
+		if err := r.UnmarshalJSON([]byte(body)); err != nil || r != want {
+			t.Errorf("UnmarshalJSON(%s) = %d, %v, want %d", body, r, err, want)
+		}
+	}
+
+	for _, body := range []string{`"PT2H"`, `"mins"`, `true`, `"99999999999 mins"`} {
+		var r Runtime
+		if err := r.UnmarshalJSON([]byte(body)); err == nil {
+			t.Errorf("UnmarshalJSON(%s) error = nil, want an error", body)
+		}
+	}
+
+	if b, _ := Runtime(102).MarshalJSON(); string(b) != "102" {
+		t.Errorf("MarshalJSON() = %s, want 102", b)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		body    string
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:   "not found",
+			status: http.StatusNotFound,
+			body:   `{"error": "the requested resource could not be found"}`,
+			check: func(t *testing.T, err error) {
+				var nf *NotFoundError
+				if !errors.Is(err, ErrNotFound) || !errors.As(err, &nf) {
+					t.Fatalf("error = %v, want a *NotFoundError", err)
+				}
+				if nf.Message != "the requested resource could not be found" {
+					t.Errorf("Message = %q", nf.Message)
+				}
+			},
+		},
+		{
+			name:   "not found as a problem",
+			status: http.StatusNotFound,
+			body:   `{"type": "urn:greenlight:problem:not_found", "status": 404, "code": "not_found", "detail": "no such movie"}`,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) {
+					t.Fatalf("error = %v, want a *NotFoundError wrapping an *APIError", err)
+				}
+				if apiErr.Code != "not_found" || apiErr.Message != "no such movie" || apiErr.StatusCode != http.StatusNotFound {
+					t.Errorf("APIError = %+v", apiErr)
+				}
+			},
+		},
+		{
+			name:   "validation",
+			status: http.StatusUnprocessableEntity,
+			body:   `{"error": {"title": "must be provided", "year": "must be greater than 1888"}}`,
+			check: func(t *testing.T, err error) {
+				var ve *ValidationError
+				if !errors.Is(err, ErrValidation) || !errors.As(err, &ve) {
+					t.Fatalf("error = %v, want a *ValidationError", err)
+				}
+				want := map[string]string{"title": "must be provided", "year": "must be greater than 1888"}
+				if !reflect.DeepEqual(ve.Fields, want) {
+					t.Errorf("Fields = %v, want %v", ve.Fields, want)
+				}
+			},
+		},
+		{
+			name:   "validation as a problem",
+			status: http.StatusUnprocessableEntity,
+			body: `{"code": "failed_validation", "detail": "the request failed validation", "invalid_params": [
+				{"name": "title", "reason": "must be provided", "code": "required"},
+				{"name": "title", "reason": "must not be blank", "code": "not_blank"},
+				{"name": "year", "reason": "must be provided", "code": "required"}]}`,
+			check: func(t *testing.T, err error) {
+				var ve *ValidationError
+				if !errors.As(err, &ve) {
+					t.Fatalf("error = %v, want a *ValidationError", err)
+				}
+				// the first message for each field, as in the plain response
+				want := map[string]string{"title": "must be provided", "year": "must be provided"}
+				if !reflect.DeepEqual(ve.Fields, want) {
+					t.Errorf("Fields = %v, want %v", ve.Fields, want)
+				}
+				if len(ve.Params) != 3 || ve.Params[1].Code != "not_blank" {
+					t.Errorf("Params = %+v, want all three", ve.Params)
+				}
+				if ve.Code != "failed_validation" {
+					t.Errorf("Code = %q, want failed_validation", ve.Code)
+				}
+			},
+		},
+		{
+			name:   "unprocessable without fields",
+			status: http.StatusUnprocessableEntity,
+			body:   `{"code": "idempotency_key_reused", "detail": "the key was used with a different request"}`,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) || apiErr.Code != "idempotency_key_reused" {
+					t.Errorf("error = %v, want a plain *APIError", err)
+				}
+			},
+		},
+		{
+			name:    "conflict",
+			status:  http.StatusConflict,
+			headers: map[string]string{"Location": "/v1/movies/1"},
+			body:    `{"code": "duplicate_movie", "detail": "the movie is already in the catalogue"}`,
+			check: func(t *testing.T, err error) {
+				var ce *ConflictError
+				if !errors.Is(err, ErrConflict) || !errors.As(err, &ce) {
+					t.Fatalf("error = %v, want a *ConflictError", err)
+				}
+				if ce.Location != "/v1/movies/1" || ce.Code != "duplicate_movie" {
+					t.Errorf("ConflictError = %+v, %+v", ce, ce.APIError)
+				}
+			},
+		},
+		{
+			name:   "body that is not JSON",
+			status: http.StatusBadRequest,
+			body:   `<html>bad request</html>`,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.Message != "bad request" || apiErr.Code != "" {
+					t.Errorf("error = %v, want an *APIError with the status text", err)
+				}
+				if got := err.Error(); got != "greenlight: 400: bad request" {
+					t.Errorf("Error() = %q", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.headers {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+
+			_, err := c.CreateMovie(context.Background(), MovieInput{Title: "Casablanca"})
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("wrapped: %w", context.DeadlineExceeded), false},
+		{"connection", &url.Error{Op: "Get", URL: "http://localhost", Err: errors.New("connection refused")}, true},
+		{"timeout in a url error", &url.Error{Op: "Get", URL: "http://localhost", Err: context.DeadlineExceeded}, false},
+		{"decoding", errors.New("client: decoding response: unexpected EOF"), false},
+		{"too many requests", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"bad gateway", &APIError{StatusCode: http.StatusBadGateway}, true},
+		{"unavailable", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"gateway timeout", &APIError{StatusCode: http.StatusGatewayTimeout}, true},
+		{"server error", &APIError{StatusCode: http.StatusInternalServerError}, false},
+		{"in progress", &ConflictError{APIError: &APIError{StatusCode: http.StatusConflict, Code: "idempotency_key_in_progress"}}, true},
+		{"duplicate", &ConflictError{APIError: &APIError{StatusCode: http.StatusConflict, Code: "duplicate_movie"}}, false},
+		{"not found", &NotFoundError{APIError: &APIError{StatusCode: http.StatusNotFound}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{retry: RetryPolicy{MaxAttempts: 10, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}}
+	busy := &APIError{StatusCode: http.StatusServiceUnavailable}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		// a shift this large overflows, which must not turn into a negative or zero cap
+		{70, time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 50 {
+			got := c.backoff(tt.attempt, busy)
+			if got < 0 || got > tt.max {
+				t.Fatalf("backoff(%d) = %s, want between 0 and %s", tt.attempt, got, tt.max)
+			}
+		}
+	}
+
+	// the server's Retry-After is used as it is, up to the maximum
+	if got := c.backoff(1, &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 500 * time.Millisecond}); got != 500*time.Millisecond {
+		t.Errorf("backoff() with Retry-After 500ms = %s", got)
+	}
+	if got := c.backoff(1, &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}); got != time.Second {
+		t.Errorf("backoff() with Retry-After 1h = %s, want the 1s maximum", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                              0,
+		"0":                             0,
+		"3":                             3 * time.Second,
+		"120":                           2 * time.Minute,
+		"-1":                            0,
+		"1.5":                           0,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0,
+	}
+
+	for value, want := range tests {
+		if got := parseRetryAfter(value); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  bool
+		attempts int
+	}{
+		{"succeeds after failures", []int{503, 502, 201}, false, 3},
+		{"gives up after max attempts", []int{503, 503, 503, 503, 201}, true, 4},
+		{"waits for a request in progress", []int{409, 201}, false, 2},
+		{"does not retry validation errors", []int{422, 201}, true, 1},
+		{"does not retry server errors", []int{500, 201}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				keys   []string
+				bodies []string
+			)
+
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				mu.Lock()
+				keys = append(keys, r.Header.Get("Idempotency-Key"))
+				bodies = append(bodies, string(body))
+				status := tt.statuses[len(keys)-1]
+				mu.Unlock()
+
+				w.WriteHeader(status)
+				switch status {
+				case http.StatusConflict:
+					io.WriteString(w, `{"code": "idempotency_key_in_progress", "detail": "in progress"}`)
+				case http.StatusUnprocessableEntity:
+					io.WriteString(w, `{"error": {"title": "must be provided"}}`)
+				case http.StatusCreated:
+					io.WriteString(w, `{"movie": {"id": 1, "title": "Casablanca", "genres": ["drama"], "version": 1}}`)
+				}
+			})
+
+			movie, err := c.CreateMovie(context.Background(), MovieInput{Title: "Casablanca", Year: 1942, Runtime: 102, Genres: []string{"drama"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateMovie() = %v, %v, want an error: %t", movie, err, tt.wantErr)
+			}
+			if len(keys) != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", len(keys), tt.attempts)
+			}
+
+			// every attempt is the same request, with the same key
+			if len(keys[0]) != 32 {
+				t.Errorf("Idempotency-Key = %q, want 32 hex characters", keys[0])
+			}
+			for i := range keys {
+				if keys[i] != keys[0] || bodies[i] != bodies[0] {
+					t.Errorf("attempt %d sent key %q and body %s, want %q and %s", i+1, keys[i], bodies[i], keys[0], bodies[0])
+				}
+			}
+			if !strings.Contains(bodies[0], `"runtime":102`) {
+				t.Errorf("body = %s, want the runtime as a number", bodies[0])
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyPerRequest(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		mu.Unlock()
+		io.WriteString(w, `{"movie": {"id": 1, "genres": [], "version": 1}}`)
+	})
+
+	ctx := context.Background()
+	c.CreateMovie(ctx, MovieInput{Title: "Casablanca"})
+	c.CreateMovie(ctx, MovieInput{Title: "Casablanca"})
+	c.UpdateMovie(ctx, 1, MovieInput{Title: "Casablanca"})
+
+	if keys[0] == "" || keys[0] == keys[1] {
+		t.Errorf("keys = %q, want a new key for each call", keys[:2])
+	}
+	if keys[2] != "" {
+		t.Errorf("PUT sent Idempotency-Key %q, want none", keys[2])
+	}
+}
+
+func TestRetryStopsWhenContextIsCancelled(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 10, MinBackoff: time.Hour, MaxBackoff: time.Hour}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.DeleteMovie(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DeleteMovie() error = %v, want context.DeadlineExceeded", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("the backoff was not cut short by the context")
+	}
+}
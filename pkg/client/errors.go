@@ -0,0 +1,144 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the sentinel errors that the typed errors match with errors.Is
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrValidation = errors.New("failed validation")
+	ErrConflict   = errors.New("conflict")
+)
+
+// an APIError is an error response from the API
+// the more specific NotFoundError, ValidationError and ConflictError all wrap one,
+// so errors.As with an *APIError works for every response
+type APIError struct {
+	StatusCode int
+	// Code is the stable error code, e.g. "duplicate_movie", it is empty if the server did not send one
+	Code string
+	// Message is the error message in the language asked for with WithLanguage
+	Message string
+	// RetryAfter is the wait the server asked for before the request is tried again, if any
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("greenlight: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("greenlight: %d: %s", e.StatusCode, e.Message)
+}
+
+// a NotFoundError is returned when the record does not exist
+type NotFoundError struct {
+	*APIError
+}
+
+func (e *NotFoundError) Unwrap() error { return e.APIError }
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// a ValidationError is returned when the request fails validation
+type ValidationError struct {
+	*APIError
+	// Fields holds the first message for each field that failed, as in the API's plain error responses
+	Fields map[string]string
+	// Params holds every message for every field, along with its code
+	Params []InvalidParam
+}
+
+// an InvalidParam is a single failed check on a field
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Code   string `json:"code"`
+}
+
+func (e *ValidationError) Unwrap() error { return e.APIError }
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+// a ConflictError is returned when the request clashes with the stored data, e.g. a movie that is already in the catalogue
+type ConflictError struct {
+	*APIError
+	// Location is the path of the existing record, if the server gave one
+	Location string
+}
+
+func (e *ConflictError) Unwrap() error { return e.APIError }
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// errorBody holds either shape of error response, problem details or the {"error": ...} envelope
+type errorBody struct {
+	// problem details
+	Code          string         `json:"code"`
+	Detail        string         `json:"detail"`
+	InvalidParams []InvalidParam `json:"invalid_params"`
+	// envelope, the error is a message or a map of field names to messages
+	Error json.RawMessage `json:"error"`
+}
+
+// newError builds the typed error for a response with an error status
+func newError(resp *http.Response, body []byte) error {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var fields map[string]string
+	var params []InvalidParam
+
+	var eb errorBody
+	if json.Unmarshal(body, &eb) == nil {
+		apiErr.Code = eb.Code
+		apiErr.Message = eb.Detail
+		params = eb.InvalidParams
+
+		if len(eb.Error) > 0 {
+			var message string
+			if json.Unmarshal(eb.Error, &message) == nil {
+				apiErr.Message = message
+			} else {
+				_ = json.Unmarshal(eb.Error, &fields)
+			}
+		}
+	}
+
+	// keep the first message per field when the problem details list them all
+	if fields == nil && len(params) > 0 {
+		fields = make(map[string]string, len(params))
+		for _, p := range params {
+			if _, ok := fields[p.Name]; !ok {
+				fields[p.Name] = p.Reason
+			}
+		}
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = strings.ToLower(http.StatusText(resp.StatusCode))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return &NotFoundError{APIError: apiErr}
+	case http.StatusUnprocessableEntity:
+		if fields == nil {
+			// e.g. an Idempotency-Key reused with a different request
+			return apiErr
+		}
+		return &ValidationError{APIError: apiErr, Fields: fields, Params: params}
+	case http.StatusConflict:
+		return &ConflictError{APIError: apiErr, Location: resp.Header.Get("Location")}
+	default:
+		return apiErr
+	}
+}
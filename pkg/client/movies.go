@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a Movie is a movie in the catalogue, it has the same JSON shape as the API's data.Movie
+type Movie struct {
+	ID          int64             `json:"id"`
+	Title       string            `json:"title,omitempty"`
+	Year        int32             `json:"year,omitempty"`
+	Runtime     Runtime           `json:"runtime,omitempty"`
+	Genres      []string          `json:"genres"`
+	Version     int32             `json:"version"`
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+	// the ratings are worked out from the movie's reviews
+	AverageRating float64 `json:"average_rating"`
+	RatingCount   int32   `json:"rating_count"`
+	// Poster is nil if no poster has been uploaded
+	Poster *Poster `json:"poster,omitempty"`
+	// Credits are only filled in by GetMovie with IncludeCredits
+	Credits []*Credit `json:"credits,omitempty"`
+}
+
+type Poster struct {
+	ContentType string            `json:"content_type"`
+	Width       int32             `json:"width"`
+	Height      int32             `json:"height"`
+	Size        int64             `json:"size"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+}
+
+type Credit struct {
+	ID           int64  `json:"id"`
+	PersonID     int64  `json:"person_id"`
+	Name         string `json:"name,omitempty"`
+	Role         string `json:"role"`
+	Character    string `json:"character,omitempty"`
+	BillingOrder int32  `json:"billing_order"`
+}
+
+// a MovieInput holds the fields sent to create or update a movie
+type MovieInput struct {
+	Title   string   `json:"title"`
+	Year    int32    `json:"year"`
+	Runtime Runtime  `json:"runtime"`
+	Genres  []string `json:"genres"`
+	// ExternalIDs are left as they are on update when nil
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+}
+
+// Runtime is a movie's runtime in minutes
+// it is sent to the API as an integer and read back from the "<runtime> mins" string the API writes
+type Runtime int32
+
+var runtimeRX = regexp.MustCompile(`^(\d+) mins?$`)
+
+func (r Runtime) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(r), 10)), nil
+}
+
+func (r *Runtime) UnmarshalJSON(b []byte) error {
+	var minutes int32
+	if json.Unmarshal(b, &minutes) == nil {
+		*r = Runtime(minutes)
+		return nil
+	}
+
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("client: invalid runtime %s", b)
+	}
+
+	matches := runtimeRX.FindStringSubmatch(s)
+	if matches == nil {
+		return fmt.Errorf("client: invalid runtime %q", s)
+	}
+
+	n, err := strconv.ParseInt(matches[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("client: invalid runtime %q", s)
+	}
+
+	*r = Runtime(n)
+	return nil
+}
+
+func (r Runtime) String() string {
+	return fmt.Sprintf("%d mins", r)
+}
+
+// ListMoviesParams filters, sorts and pages the movies returned by ListMovies
+// zero values are left out, so the API's defaults apply
+type ListMoviesParams struct {
+	Title  string
+	Genres []string
+	// Sort is a field name, with a leading "-" for descending order, e.g. "-year"
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+func (p ListMoviesParams) query() url.Values {
+	qs := url.Values{}
+	if p.Title != "" {
+		qs.Set("title", p.Title)
+	}
+	if len(p.Genres) > 0 {
+		qs.Set("genres", strings.Join(p.Genres, ","))
+	}
+	if p.Sort != "" {
+		qs.Set("sort", p.Sort)
+	}
+	if p.Page > 0 {
+		qs.Set("page", strconv.Itoa(p.Page))
+	}
+	if p.PageSize > 0 {
+		qs.Set("page_size", strconv.Itoa(p.PageSize))
+	}
+
+	return qs
+}
+
+// Metadata describes the page of results returned by ListMovies, it is empty when there are no results
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+// a MoviePage is one page of movies from ListMovies
+type MoviePage struct {
+	Movies   []*Movie `json:"movies"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// ListMovies returns a page of the movies matching the params
+func (c *Client) ListMovies(ctx context.Context, params ListMoviesParams) (*MoviePage, error) {
+	var page MoviePage
+	err := c.do(ctx, http.MethodGet, "/v1/movies", params.query(), nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// GetMovieOptions adds related records to the movie returned by GetMovie
+type GetMovieOptions struct {
+	IncludeCredits bool
+}
+
+// GetMovie returns the movie with the ID, or a *NotFoundError if there is none
+func (c *Client) GetMovie(ctx context.Context, id int64, opts ...GetMovieOptions) (*Movie, error) {
+	qs := url.Values{}
+	for _, opt := range opts {
+		if opt.IncludeCredits {
+			qs.Set("include", "credits")
+		}
+	}
+
+	return c.movie(ctx, http.MethodGet, fmt.Sprintf("/v1/movies/%d", id), qs, nil)
+}
+
+// LookupMovie returns the movie with an ID in another database, e.g. LookupMovie(ctx, "imdb", "tt0111161")
+func (c *Client) LookupMovie(ctx context.Context, source, externalID string) (*Movie, error) {
+	qs := url.Values{}
+	qs.Set(source, externalID)
+
+	return c.movie(ctx, http.MethodGet, "/v1/movies/lookup", qs, nil)
+}
+
+// CreateMovie adds a movie to the catalogue and returns it
+// a *ValidationError is returned if the input is not valid, and a *ConflictError if the movie is already there
+func (c *Client) CreateMovie(ctx context.Context, input MovieInput) (*Movie, error) {
+	return c.movie(ctx, http.MethodPost, "/v1/movies", nil, input)
+}
+
+// UpdateMovie replaces the fields of the movie with the ID and returns the updated movie
+func (c *Client) UpdateMovie(ctx context.Context, id int64, input MovieInput) (*Movie, error) {
+	return c.movie(ctx, http.MethodPut, fmt.Sprintf("/v1/movies/%d", id), nil, input)
+}
+
+// DeleteMovie removes the movie with the ID, or returns a *NotFoundError if there is none
+func (c *Client) DeleteMovie(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/movies/%d", id), nil, nil, nil)
+}
+
+// movie sends a request which responds with a single movie in the envelope
+func (c *Client) movie(ctx context.Context, method, path string, query url.Values, body any) (*Movie, error) {
+	var env struct {
+		Movie *Movie `json:"movie"`
+	}
+
+	err := c.do(ctx, method, path, query, body, &env)
+	if err != nil {
+		return nil, err
+	}
+
+	return env.Movie, nil
+}
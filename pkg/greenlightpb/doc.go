@@ -0,0 +1,13 @@
+// Package greenlightpb is the Go code generated from proto/greenlight/v1/movies.proto, for clients of the gRPC API
+//
+//	conn, err := grpc.NewClient("greenlight.internal:4001", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//
+//	movie, err := greenlightpb.NewMovieServiceClient(conn).Get(ctx, &greenlightpb.GetMovieRequest{Id: 1})
+//	if status.Code(err) == codes.NotFound {
+//		// no such movie
+//	}
+package greenlightpb
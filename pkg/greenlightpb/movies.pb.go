@@ -0,0 +1,937 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: greenlight/v1/movies.proto
+
+package greenlightpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MovieEvent_Type int32
+
+const (
+	MovieEvent_TYPE_UNSPECIFIED MovieEvent_Type = 0
+	MovieEvent_TYPE_CREATED     MovieEvent_Type = 1
+	MovieEvent_TYPE_UPDATED     MovieEvent_Type = 2
+	MovieEvent_TYPE_DELETED     MovieEvent_Type = 3
+)
+
+// Enum value maps for MovieEvent_Type.
+var (
+	MovieEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_CREATED",
+		2: "TYPE_UPDATED",
+		3: "TYPE_DELETED",
+	}
+	MovieEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_CREATED":     1,
+		"TYPE_UPDATED":     2,
+		"TYPE_DELETED":     3,
+	}
+)
+
+func (x MovieEvent_Type) Enum() *MovieEvent_Type {
+	p := new(MovieEvent_Type)
+	*p = x
+	return p
+}
+
+func (x MovieEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MovieEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_greenlight_v1_movies_proto_enumTypes[0].Descriptor()
+}
+
+func (MovieEvent_Type) Type() protoreflect.EnumType {
+	return &file_greenlight_v1_movies_proto_enumTypes[0]
+}
+
+func (x MovieEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MovieEvent_Type.Descriptor instead.
+func (MovieEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{10, 0}
+}
+
+type Movie struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title          string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Year           int32                  `protobuf:"varint,3,opt,name=year,proto3" json:"year,omitempty"`
+	RuntimeMinutes int32                  `protobuf:"varint,4,opt,name=runtime_minutes,json=runtimeMinutes,proto3" json:"runtime_minutes,omitempty"`
+	Genres         []string               `protobuf:"bytes,5,rep,name=genres,proto3" json:"genres,omitempty"`
+	// the movie's IDs in other databases by source, e.g. {"imdb": "tt0111161"}
+	ExternalIds   map[string]string `protobuf:"bytes,6,rep,name=external_ids,json=externalIds,proto3" json:"external_ids,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	AverageRating float64           `protobuf:"fixed64,7,opt,name=average_rating,json=averageRating,proto3" json:"average_rating,omitempty"`
+	RatingCount   int32             `protobuf:"varint,8,opt,name=rating_count,json=ratingCount,proto3" json:"rating_count,omitempty"`
+	Version       int32             `protobuf:"varint,9,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Movie) Reset() {
+	*x = Movie{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Movie) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Movie) ProtoMessage() {}
+
+func (x *Movie) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Movie.ProtoReflect.Descriptor instead.
+func (*Movie) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Movie) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Movie) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Movie) GetYear() int32 {
+	if x != nil {
+		return x.Year
+	}
+	return 0
+}
+
+func (x *Movie) GetRuntimeMinutes() int32 {
+	if x != nil {
+		return x.RuntimeMinutes
+	}
+	return 0
+}
+
+func (x *Movie) GetGenres() []string {
+	if x != nil {
+		return x.Genres
+	}
+	return nil
+}
+
+func (x *Movie) GetExternalIds() map[string]string {
+	if x != nil {
+		return x.ExternalIds
+	}
+	return nil
+}
+
+func (x *Movie) GetAverageRating() float64 {
+	if x != nil {
+		return x.AverageRating
+	}
+	return 0
+}
+
+func (x *Movie) GetRatingCount() int32 {
+	if x != nil {
+		return x.RatingCount
+	}
+	return 0
+}
+
+func (x *Movie) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type GetMovieRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMovieRequest) Reset() {
+	*x = GetMovieRequest{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMovieRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMovieRequest) ProtoMessage() {}
+
+func (x *GetMovieRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMovieRequest.ProtoReflect.Descriptor instead.
+func (*GetMovieRequest) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetMovieRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListMoviesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// words that must all appear in the title
+	Title string `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	// genres that the movies must all have
+	Genres []string `protobuf:"bytes,2,rep,name=genres,proto3" json:"genres,omitempty"`
+	// the page to return, from 1, and its size, from 1 to 100, which default to 1 and 20
+	Page     int32 `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize int32 `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// a field to sort by, with a - prefix for descending order, which defaults to id
+	Sort          string `protobuf:"bytes,5,opt,name=sort,proto3" json:"sort,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMoviesRequest) Reset() {
+	*x = ListMoviesRequest{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMoviesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMoviesRequest) ProtoMessage() {}
+
+func (x *ListMoviesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMoviesRequest.ProtoReflect.Descriptor instead.
+func (*ListMoviesRequest) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListMoviesRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *ListMoviesRequest) GetGenres() []string {
+	if x != nil {
+		return x.Genres
+	}
+	return nil
+}
+
+func (x *ListMoviesRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListMoviesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListMoviesRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+type ListMoviesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Movies        []*Movie               `protobuf:"bytes,1,rep,name=movies,proto3" json:"movies,omitempty"`
+	Metadata      *Metadata              `protobuf:"bytes,2,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMoviesResponse) Reset() {
+	*x = ListMoviesResponse{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMoviesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMoviesResponse) ProtoMessage() {}
+
+func (x *ListMoviesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMoviesResponse.ProtoReflect.Descriptor instead.
+func (*ListMoviesResponse) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListMoviesResponse) GetMovies() []*Movie {
+	if x != nil {
+		return x.Movies
+	}
+	return nil
+}
+
+func (x *ListMoviesResponse) GetMetadata() *Metadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// Metadata holds the pagination details of a page of records, which are all 0 when there are no records.
+type Metadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CurrentPage   int32                  `protobuf:"varint,1,opt,name=current_page,json=currentPage,proto3" json:"current_page,omitempty"`
+	PageSize      int32                  `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	FirstPage     int32                  `protobuf:"varint,3,opt,name=first_page,json=firstPage,proto3" json:"first_page,omitempty"`
+	LastPage      int32                  `protobuf:"varint,4,opt,name=last_page,json=lastPage,proto3" json:"last_page,omitempty"`
+	TotalRecords  int32                  `protobuf:"varint,5,opt,name=total_records,json=totalRecords,proto3" json:"total_records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metadata) Reset() {
+	*x = Metadata{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metadata) ProtoMessage() {}
+
+func (x *Metadata) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metadata.ProtoReflect.Descriptor instead.
+func (*Metadata) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Metadata) GetCurrentPage() int32 {
+	if x != nil {
+		return x.CurrentPage
+	}
+	return 0
+}
+
+func (x *Metadata) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *Metadata) GetFirstPage() int32 {
+	if x != nil {
+		return x.FirstPage
+	}
+	return 0
+}
+
+func (x *Metadata) GetLastPage() int32 {
+	if x != nil {
+		return x.LastPage
+	}
+	return 0
+}
+
+func (x *Metadata) GetTotalRecords() int32 {
+	if x != nil {
+		return x.TotalRecords
+	}
+	return 0
+}
+
+type CreateMovieRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Title          string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Year           int32                  `protobuf:"varint,2,opt,name=year,proto3" json:"year,omitempty"`
+	RuntimeMinutes int32                  `protobuf:"varint,3,opt,name=runtime_minutes,json=runtimeMinutes,proto3" json:"runtime_minutes,omitempty"`
+	Genres         []string               `protobuf:"bytes,4,rep,name=genres,proto3" json:"genres,omitempty"`
+	ExternalIds    map[string]string      `protobuf:"bytes,5,rep,name=external_ids,json=externalIds,proto3" json:"external_ids,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CreateMovieRequest) Reset() {
+	*x = CreateMovieRequest{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateMovieRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateMovieRequest) ProtoMessage() {}
+
+func (x *CreateMovieRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateMovieRequest.ProtoReflect.Descriptor instead.
+func (*CreateMovieRequest) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateMovieRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateMovieRequest) GetYear() int32 {
+	if x != nil {
+		return x.Year
+	}
+	return 0
+}
+
+func (x *CreateMovieRequest) GetRuntimeMinutes() int32 {
+	if x != nil {
+		return x.RuntimeMinutes
+	}
+	return 0
+}
+
+func (x *CreateMovieRequest) GetGenres() []string {
+	if x != nil {
+		return x.Genres
+	}
+	return nil
+}
+
+func (x *CreateMovieRequest) GetExternalIds() map[string]string {
+	if x != nil {
+		return x.ExternalIds
+	}
+	return nil
+}
+
+type UpdateMovieRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the movie to change, identified by its id
+	Movie *Movie `protobuf:"bytes,1,opt,name=movie,proto3" json:"movie,omitempty"`
+	// the fields to change, any of title, year, runtime_minutes, genres and external_ids
+	// without a mask, every field is replaced except the external IDs, which are only replaced when some are given
+	UpdateMask    *fieldmaskpb.FieldMask `protobuf:"bytes,2,opt,name=update_mask,json=updateMask,proto3" json:"update_mask,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMovieRequest) Reset() {
+	*x = UpdateMovieRequest{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMovieRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMovieRequest) ProtoMessage() {}
+
+func (x *UpdateMovieRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMovieRequest.ProtoReflect.Descriptor instead.
+func (*UpdateMovieRequest) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UpdateMovieRequest) GetMovie() *Movie {
+	if x != nil {
+		return x.Movie
+	}
+	return nil
+}
+
+func (x *UpdateMovieRequest) GetUpdateMask() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.UpdateMask
+	}
+	return nil
+}
+
+type DeleteMovieRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMovieRequest) Reset() {
+	*x = DeleteMovieRequest{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMovieRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMovieRequest) ProtoMessage() {}
+
+func (x *DeleteMovieRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMovieRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMovieRequest) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteMovieRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type DeleteMovieResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMovieResponse) Reset() {
+	*x = DeleteMovieResponse{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMovieResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMovieResponse) ProtoMessage() {}
+
+func (x *DeleteMovieResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMovieResponse.ProtoReflect.Descriptor instead.
+func (*DeleteMovieResponse) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{8}
+}
+
+type WatchMoviesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// only changes to movies with all of these genres
+	Genres []string `protobuf:"bytes,1,rep,name=genres,proto3" json:"genres,omitempty"`
+	// resume after the event with this id, to get the changes missed while disconnected
+	// without it, the stream starts with the next change
+	AfterId       *int64 `protobuf:"varint,2,opt,name=after_id,json=afterId,proto3,oneof" json:"after_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchMoviesRequest) Reset() {
+	*x = WatchMoviesRequest{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMoviesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMoviesRequest) ProtoMessage() {}
+
+func (x *WatchMoviesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchMoviesRequest.ProtoReflect.Descriptor instead.
+func (*WatchMoviesRequest) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WatchMoviesRequest) GetGenres() []string {
+	if x != nil {
+		return x.Genres
+	}
+	return nil
+}
+
+func (x *WatchMoviesRequest) GetAfterId() int64 {
+	if x != nil && x.AfterId != nil {
+		return *x.AfterId
+	}
+	return 0
+}
+
+type MovieEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the change feed cursor, to resume the stream from with after_id
+	Id         int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	OccurredAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	Type       MovieEvent_Type        `protobuf:"varint,3,opt,name=type,proto3,enum=greenlight.v1.MovieEvent_Type" json:"type,omitempty"`
+	// the movie as it was after the change, or when it was deleted
+	Movie         *Movie `protobuf:"bytes,4,opt,name=movie,proto3" json:"movie,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MovieEvent) Reset() {
+	*x = MovieEvent{}
+	mi := &file_greenlight_v1_movies_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MovieEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MovieEvent) ProtoMessage() {}
+
+func (x *MovieEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_greenlight_v1_movies_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MovieEvent.ProtoReflect.Descriptor instead.
+func (*MovieEvent) Descriptor() ([]byte, []int) {
+	return file_greenlight_v1_movies_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MovieEvent) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *MovieEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *MovieEvent) GetType() MovieEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return MovieEvent_TYPE_UNSPECIFIED
+}
+
+func (x *MovieEvent) GetMovie() *Movie {
+	if x != nil {
+		return x.Movie
+	}
+	return nil
+}
+
+var File_greenlight_v1_movies_proto protoreflect.FileDescriptor
+
+const file_greenlight_v1_movies_proto_rawDesc = "" +
+	"\n" +
+	"\x1agreenlight/v1/movies.proto\x12\rgreenlight.v1\x1a google/protobuf/field_mask.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf0\x02\n" +
+	"\x05Movie\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x12\n" +
+	"\x04year\x18\x03 \x01(\x05R\x04year\x12'\n" +
+	"\x0fruntime_minutes\x18\x04 \x01(\x05R\x0eruntimeMinutes\x12\x16\n" +
+	"\x06genres\x18\x05 \x03(\tR\x06genres\x12H\n" +
+	"\fexternal_ids\x18\x06 \x03(\v2%.greenlight.v1.Movie.ExternalIdsEntryR\vexternalIds\x12%\n" +
+	"\x0eaverage_rating\x18\a \x01(\x01R\raverageRating\x12!\n" +
+	"\frating_count\x18\b \x01(\x05R\vratingCount\x12\x18\n" +
+	"\aversion\x18\t \x01(\x05R\aversion\x1a>\n" +
+	"\x10ExternalIdsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"!\n" +
+	"\x0fGetMovieRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\x86\x01\n" +
+	"\x11ListMoviesRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x16\n" +
+	"\x06genres\x18\x02 \x03(\tR\x06genres\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x12\n" +
+	"\x04sort\x18\x05 \x01(\tR\x04sort\"w\n" +
+	"\x12ListMoviesResponse\x12,\n" +
+	"\x06movies\x18\x01 \x03(\v2\x14.greenlight.v1.MovieR\x06movies\x123\n" +
+	"\bmetadata\x18\x02 \x01(\v2\x17.greenlight.v1.MetadataR\bmetadata\"\xab\x01\n" +
+	"\bMetadata\x12!\n" +
+	"\fcurrent_page\x18\x01 \x01(\x05R\vcurrentPage\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"first_page\x18\x03 \x01(\x05R\tfirstPage\x12\x1b\n" +
+	"\tlast_page\x18\x04 \x01(\x05R\blastPage\x12#\n" +
+	"\rtotal_records\x18\x05 \x01(\x05R\ftotalRecords\"\x96\x02\n" +
+	"\x12CreateMovieRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x12\n" +
+	"\x04year\x18\x02 \x01(\x05R\x04year\x12'\n" +
+	"\x0fruntime_minutes\x18\x03 \x01(\x05R\x0eruntimeMinutes\x12\x16\n" +
+	"\x06genres\x18\x04 \x03(\tR\x06genres\x12U\n" +
+	"\fexternal_ids\x18\x05 \x03(\v22.greenlight.v1.CreateMovieRequest.ExternalIdsEntryR\vexternalIds\x1a>\n" +
+	"\x10ExternalIdsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"}\n" +
+	"\x12UpdateMovieRequest\x12*\n" +
+	"\x05movie\x18\x01 \x01(\v2\x14.greenlight.v1.MovieR\x05movie\x12;\n" +
+	"\vupdate_mask\x18\x02 \x01(\v2\x1a.google.protobuf.FieldMaskR\n" +
+	"updateMask\"$\n" +
+	"\x12DeleteMovieRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\x15\n" +
+	"\x13DeleteMovieResponse\"Y\n" +
+	"\x12WatchMoviesRequest\x12\x16\n" +
+	"\x06genres\x18\x01 \x03(\tR\x06genres\x12\x1e\n" +
+	"\bafter_id\x18\x02 \x01(\x03H\x00R\aafterId\x88\x01\x01B\v\n" +
+	"\t_after_id\"\x8d\x02\n" +
+	"\n" +
+	"MovieEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12;\n" +
+	"\voccurred_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x122\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x1e.greenlight.v1.MovieEvent.TypeR\x04type\x12*\n" +
+	"\x05movie\x18\x04 \x01(\v2\x14.greenlight.v1.MovieR\x05movie\"R\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x10\n" +
+	"\fTYPE_CREATED\x10\x01\x12\x10\n" +
+	"\fTYPE_UPDATED\x10\x02\x12\x10\n" +
+	"\fTYPE_DELETED\x10\x032\xb8\x03\n" +
+	"\fMovieService\x12;\n" +
+	"\x03Get\x12\x1e.greenlight.v1.GetMovieRequest\x1a\x14.greenlight.v1.Movie\x12K\n" +
+	"\x04List\x12 .greenlight.v1.ListMoviesRequest\x1a!.greenlight.v1.ListMoviesResponse\x12A\n" +
+	"\x06Create\x12!.greenlight.v1.CreateMovieRequest\x1a\x14.greenlight.v1.Movie\x12A\n" +
+	"\x06Update\x12!.greenlight.v1.UpdateMovieRequest\x1a\x14.greenlight.v1.Movie\x12O\n" +
+	"\x06Delete\x12!.greenlight.v1.DeleteMovieRequest\x1a\".greenlight.v1.DeleteMovieResponse\x12G\n" +
+	"\x05Watch\x12!.greenlight.v1.WatchMoviesRequest\x1a\x19.greenlight.v1.MovieEvent0\x01B9Z7github.com/TaskMasterErnest/greenlight/pkg/greenlightpbb\x06proto3"
+
+var (
+	file_greenlight_v1_movies_proto_rawDescOnce sync.Once
+	file_greenlight_v1_movies_proto_rawDescData []byte
+)
+
+func file_greenlight_v1_movies_proto_rawDescGZIP() []byte {
+	file_greenlight_v1_movies_proto_rawDescOnce.Do(func() {
+		file_greenlight_v1_movies_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_greenlight_v1_movies_proto_rawDesc), len(file_greenlight_v1_movies_proto_rawDesc)))
+	})
+	return file_greenlight_v1_movies_proto_rawDescData
+}
+
+var file_greenlight_v1_movies_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_greenlight_v1_movies_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_greenlight_v1_movies_proto_goTypes = []any{
+	(MovieEvent_Type)(0),          // 0: greenlight.v1.MovieEvent.Type
+	(*Movie)(nil),                 // 1: greenlight.v1.Movie
+	(*GetMovieRequest)(nil),       // 2: greenlight.v1.GetMovieRequest
+	(*ListMoviesRequest)(nil),     // 3: greenlight.v1.ListMoviesRequest
+	(*ListMoviesResponse)(nil),    // 4: greenlight.v1.ListMoviesResponse
+	(*Metadata)(nil),              // 5: greenlight.v1.Metadata
+	(*CreateMovieRequest)(nil),    // 6: greenlight.v1.CreateMovieRequest
+	(*UpdateMovieRequest)(nil),    // 7: greenlight.v1.UpdateMovieRequest
+	(*DeleteMovieRequest)(nil),    // 8: greenlight.v1.DeleteMovieRequest
+	(*DeleteMovieResponse)(nil),   // 9: greenlight.v1.DeleteMovieResponse
+	(*WatchMoviesRequest)(nil),    // 10: greenlight.v1.WatchMoviesRequest
+	(*MovieEvent)(nil),            // 11: greenlight.v1.MovieEvent
+	nil,                           // 12: greenlight.v1.Movie.ExternalIdsEntry
+	nil,                           // 13: greenlight.v1.CreateMovieRequest.ExternalIdsEntry
+	(*fieldmaskpb.FieldMask)(nil), // 14: google.protobuf.FieldMask
+	(*timestamppb.Timestamp)(nil), // 15: google.protobuf.Timestamp
+}
+var file_greenlight_v1_movies_proto_depIdxs = []int32{
+	12, // 0: greenlight.v1.Movie.external_ids:type_name -> greenlight.v1.Movie.ExternalIdsEntry
+	1,  // 1: greenlight.v1.ListMoviesResponse.movies:type_name -> greenlight.v1.Movie
+	5,  // 2: greenlight.v1.ListMoviesResponse.metadata:type_name -> greenlight.v1.Metadata
+	13, // 3: greenlight.v1.CreateMovieRequest.external_ids:type_name -> greenlight.v1.CreateMovieRequest.ExternalIdsEntry
+	1,  // 4: greenlight.v1.UpdateMovieRequest.movie:type_name -> greenlight.v1.Movie
+	14, // 5: greenlight.v1.UpdateMovieRequest.update_mask:type_name -> google.protobuf.FieldMask
+	15, // 6: greenlight.v1.MovieEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	0,  // 7: greenlight.v1.MovieEvent.type:type_name -> greenlight.v1.MovieEvent.Type
+	1,  // 8: greenlight.v1.MovieEvent.movie:type_name -> greenlight.v1.Movie
+	2,  // 9: greenlight.v1.MovieService.Get:input_type -> greenlight.v1.GetMovieRequest
+	3,  // 10: greenlight.v1.MovieService.List:input_type -> greenlight.v1.ListMoviesRequest
+	6,  // 11: greenlight.v1.MovieService.Create:input_type -> greenlight.v1.CreateMovieRequest
+	7,  // 12: greenlight.v1.MovieService.Update:input_type -> greenlight.v1.UpdateMovieRequest
+	8,  // 13: greenlight.v1.MovieService.Delete:input_type -> greenlight.v1.DeleteMovieRequest
+	10, // 14: greenlight.v1.MovieService.Watch:input_type -> greenlight.v1.WatchMoviesRequest
+	1,  // 15: greenlight.v1.MovieService.Get:output_type -> greenlight.v1.Movie
+	4,  // 16: greenlight.v1.MovieService.List:output_type -> greenlight.v1.ListMoviesResponse
+	1,  // 17: greenlight.v1.MovieService.Create:output_type -> greenlight.v1.Movie
+	1,  // 18: greenlight.v1.MovieService.Update:output_type -> greenlight.v1.Movie
+	9,  // 19: greenlight.v1.MovieService.Delete:output_type -> greenlight.v1.DeleteMovieResponse
+	11, // 20: greenlight.v1.MovieService.Watch:output_type -> greenlight.v1.MovieEvent
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_greenlight_v1_movies_proto_init() }
+func file_greenlight_v1_movies_proto_init() {
+	if File_greenlight_v1_movies_proto != nil {
+		return
+	}
+	file_greenlight_v1_movies_proto_msgTypes[9].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_greenlight_v1_movies_proto_rawDesc), len(file_greenlight_v1_movies_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_greenlight_v1_movies_proto_goTypes,
+		DependencyIndexes: file_greenlight_v1_movies_proto_depIdxs,
+		EnumInfos:         file_greenlight_v1_movies_proto_enumTypes,
+		MessageInfos:      file_greenlight_v1_movies_proto_msgTypes,
+	}.Build()
+	File_greenlight_v1_movies_proto = out.File
+	file_greenlight_v1_movies_proto_goTypes = nil
+	file_greenlight_v1_movies_proto_depIdxs = nil
+}
@@ -0,0 +1,345 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: greenlight/v1/movies.proto
+
+package greenlightpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MovieService_Get_FullMethodName    = "/greenlight.v1.MovieService/Get"
+	MovieService_List_FullMethodName   = "/greenlight.v1.MovieService/List"
+	MovieService_Create_FullMethodName = "/greenlight.v1.MovieService/Create"
+	MovieService_Update_FullMethodName = "/greenlight.v1.MovieService/Update"
+	MovieService_Delete_FullMethodName = "/greenlight.v1.MovieService/Delete"
+	MovieService_Watch_FullMethodName  = "/greenlight.v1.MovieService/Watch"
+)
+
+// MovieServiceClient is the client API for MovieService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MovieService is the movies API over gRPC, served on -grpc-port alongside the REST API.
+// Movies are checked with the same rules as the REST API, and the changes made here are recorded in the audit log
+// and the change feed in the same way.
+//
+// Errors are sent with the usual status codes: NOT_FOUND for a movie that does not exist, INVALID_ARGUMENT with a
+// google.rpc.BadRequest detail listing the fields that failed validation, ALREADY_EXISTS with a google.rpc.ResourceInfo
+// detail naming the movie that a new or changed one duplicates, and INTERNAL for anything else.
+// The messages are in the language asked for in the accept-language metadata.
+type MovieServiceClient interface {
+	// Get returns a movie by its ID.
+	Get(ctx context.Context, in *GetMovieRequest, opts ...grpc.CallOption) (*Movie, error)
+	// List returns a page of movies, like GET /v1/movies.
+	List(ctx context.Context, in *ListMoviesRequest, opts ...grpc.CallOption) (*ListMoviesResponse, error)
+	// Create adds a movie, like POST /v1/movies.
+	Create(ctx context.Context, in *CreateMovieRequest, opts ...grpc.CallOption) (*Movie, error)
+	// Update changes the fields of a movie named in the update mask, or replaces it like PUT /v1/movies/{id} without one.
+	Update(ctx context.Context, in *UpdateMovieRequest, opts ...grpc.CallOption) (*Movie, error)
+	// Delete removes a movie, along with its poster.
+	Delete(ctx context.Context, in *DeleteMovieRequest, opts ...grpc.CallOption) (*DeleteMovieResponse, error)
+	// Watch sends movie changes as they happen, like GET /v1/movies/stream.
+	Watch(ctx context.Context, in *WatchMoviesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MovieEvent], error)
+}
+
+type movieServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMovieServiceClient(cc grpc.ClientConnInterface) MovieServiceClient {
+	return &movieServiceClient{cc}
+}
+
+func (c *movieServiceClient) Get(ctx context.Context, in *GetMovieRequest, opts ...grpc.CallOption) (*Movie, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Movie)
+	err := c.cc.Invoke(ctx, MovieService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *movieServiceClient) List(ctx context.Context, in *ListMoviesRequest, opts ...grpc.CallOption) (*ListMoviesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMoviesResponse)
+	err := c.cc.Invoke(ctx, MovieService_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *movieServiceClient) Create(ctx context.Context, in *CreateMovieRequest, opts ...grpc.CallOption) (*Movie, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Movie)
+	err := c.cc.Invoke(ctx, MovieService_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *movieServiceClient) Update(ctx context.Context, in *UpdateMovieRequest, opts ...grpc.CallOption) (*Movie, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Movie)
+	err := c.cc.Invoke(ctx, MovieService_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *movieServiceClient) Delete(ctx context.Context, in *DeleteMovieRequest, opts ...grpc.CallOption) (*DeleteMovieResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteMovieResponse)
+	err := c.cc.Invoke(ctx, MovieService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *movieServiceClient) Watch(ctx context.Context, in *WatchMoviesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[MovieEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MovieService_ServiceDesc.Streams[0], MovieService_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchMoviesRequest, MovieEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MovieService_WatchClient = grpc.ServerStreamingClient[MovieEvent]
+
+// MovieServiceServer is the server API for MovieService service.
+// All implementations must embed UnimplementedMovieServiceServer
+// for forward compatibility.
+//
+// MovieService is the movies API over gRPC, served on -grpc-port alongside the REST API.
+// Movies are checked with the same rules as the REST API, and the changes made here are recorded in the audit log
+// and the change feed in the same way.
+//
+// Errors are sent with the usual status codes: NOT_FOUND for a movie that does not exist, INVALID_ARGUMENT with a
+// google.rpc.BadRequest detail listing the fields that failed validation, ALREADY_EXISTS with a google.rpc.ResourceInfo
+// detail naming the movie that a new or changed one duplicates, and INTERNAL for anything else.
+// The messages are in the language asked for in the accept-language metadata.
+type MovieServiceServer interface {
+	// Get returns a movie by its ID.
+	Get(context.Context, *GetMovieRequest) (*Movie, error)
+	// List returns a page of movies, like GET /v1/movies.
+	List(context.Context, *ListMoviesRequest) (*ListMoviesResponse, error)
+	// Create adds a movie, like POST /v1/movies.
+	Create(context.Context, *CreateMovieRequest) (*Movie, error)
+	// Update changes the fields of a movie named in the update mask, or replaces it like PUT /v1/movies/{id} without one.
+	Update(context.Context, *UpdateMovieRequest) (*Movie, error)
+	// Delete removes a movie, along with its poster.
+	Delete(context.Context, *DeleteMovieRequest) (*DeleteMovieResponse, error)
+	// Watch sends movie changes as they happen, like GET /v1/movies/stream.
+	Watch(*WatchMoviesRequest, grpc.ServerStreamingServer[MovieEvent]) error
+	mustEmbedUnimplementedMovieServiceServer()
+}
+
+// UnimplementedMovieServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMovieServiceServer struct{}
+
+func (UnimplementedMovieServiceServer) Get(context.Context, *GetMovieRequest) (*Movie, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedMovieServiceServer) List(context.Context, *ListMoviesRequest) (*ListMoviesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedMovieServiceServer) Create(context.Context, *CreateMovieRequest) (*Movie, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedMovieServiceServer) Update(context.Context, *UpdateMovieRequest) (*Movie, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedMovieServiceServer) Delete(context.Context, *DeleteMovieRequest) (*DeleteMovieResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedMovieServiceServer) Watch(*WatchMoviesRequest, grpc.ServerStreamingServer[MovieEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedMovieServiceServer) mustEmbedUnimplementedMovieServiceServer() {}
+func (UnimplementedMovieServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeMovieServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MovieServiceServer will
+// result in compilation errors.
+type UnsafeMovieServiceServer interface {
+	mustEmbedUnimplementedMovieServiceServer()
+}
+
+func RegisterMovieServiceServer(s grpc.ServiceRegistrar, srv MovieServiceServer) {
+	// If the following call pancis, it indicates UnimplementedMovieServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MovieService_ServiceDesc, srv)
+}
+
+func _MovieService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMovieRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MovieServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MovieService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MovieServiceServer).Get(ctx, req.(*GetMovieRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MovieService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMoviesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MovieServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MovieService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MovieServiceServer).List(ctx, req.(*ListMoviesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MovieService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateMovieRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MovieServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MovieService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MovieServiceServer).Create(ctx, req.(*CreateMovieRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MovieService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateMovieRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MovieServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MovieService_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MovieServiceServer).Update(ctx, req.(*UpdateMovieRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MovieService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteMovieRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MovieServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MovieService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MovieServiceServer).Delete(ctx, req.(*DeleteMovieRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MovieService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchMoviesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MovieServiceServer).Watch(m, &grpc.GenericServerStream[WatchMoviesRequest, MovieEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MovieService_WatchServer = grpc.ServerStreamingServer[MovieEvent]
+
+// MovieService_ServiceDesc is the grpc.ServiceDesc for MovieService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MovieService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "greenlight.v1.MovieService",
+	HandlerType: (*MovieServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _MovieService_Get_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _MovieService_List_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _MovieService_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _MovieService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _MovieService_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _MovieService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "greenlight/v1/movies.proto",
+}